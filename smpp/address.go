@@ -0,0 +1,263 @@
+// Copyright 2015 go-smpp authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package smpp
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/florentchauveau/go-smpp/smpp/pdu/pdufield"
+	"github.com/florentchauveau/go-smpp/smpp/pdu/pdutext"
+)
+
+// AddressError is returned by Submit when StrictValidation is enabled
+// and an address of the short message is invalid.
+type AddressError struct {
+	Field  pdufield.Name // Field holding the address, e.g. destination_addr.
+	Addr   string        // The offending address.
+	Reason string
+}
+
+// Error implements the Error interface.
+func (e *AddressError) Error() string {
+	return fmt.Sprintf("invalid %s %q: %s", e.Field, e.Addr, e.Reason)
+}
+
+// ErrNumberOfMessages is returned by Submit when StrictValidation is
+// enabled and the NumberOfMessages of the short message is over 99.
+var ErrNumberOfMessages = errors.New("number_of_messages out of range 0-99")
+
+// EncodingError is returned by Submit when StrictValidation is enabled
+// and the text of the short message has characters its codec can not
+// encode, which would otherwise be sent garbled.
+type EncodingError struct {
+	DataCoding pdutext.DataCoding // Data coding of the codec.
+	Runes      []rune             // Characters that can not be encoded, each once.
+}
+
+// Error implements the Error interface.
+func (e *EncodingError) Error() string {
+	return fmt.Sprintf("text not encodable with data_coding %#02x: %q", uint8(e.DataCoding), string(e.Runes))
+}
+
+// validateAddr checks the length of an address and its TON/NPI combination.
+func validateAddr(field pdufield.Name, addr string, ton, npi uint8) error {
+	invalid := func(format string, args ...any) error {
+		return &AddressError{Field: field, Addr: addr, Reason: fmt.Sprintf(format, args...)}
+	}
+	if len(addr) > pdufield.MaxAddrLen {
+		return invalid("longer than %d octets", pdufield.MaxAddrLen)
+	}
+	if ton > pdufield.TONAbbreviated {
+		return invalid("unknown ton %#x", ton)
+	}
+	switch npi {
+	case pdufield.NPIUnknown, pdufield.NPIISDN, pdufield.NPIData,
+		pdufield.NPITelex, pdufield.NPILandMob, pdufield.NPINational,
+		pdufield.NPIPrivate, pdufield.NPIERMES, pdufield.NPIInternet,
+		pdufield.NPIWAP:
+	default:
+		return invalid("unknown npi %#x", npi)
+	}
+	switch ton {
+	case pdufield.TONAlphanumeric:
+		if npi != pdufield.NPIUnknown {
+			return invalid("alphanumeric ton requires unknown npi, have %#x", npi)
+		}
+	case pdufield.TONInternational, pdufield.TONNational, pdufield.TONSubscriberNumber:
+		if npi != pdufield.NPIISDN {
+			break
+		}
+		for _, c := range addr {
+			if c < '0' || c > '9' {
+				return invalid("ton %#x with isdn npi requires digits only", ton)
+			}
+		}
+	}
+	return nil
+}
+
+// maxDLNameLen is the maximum length of a distribution list name,
+// without the null terminator.
+const maxDLNameLen = 20
+
+// validateShortMessage checks the addresses, number_of_messages and
+// text of sm, as done by Submit when StrictValidation is enabled. The
+// addresses of Dsts are checked with their own TON/NPI, and the names
+// of DLs for their length.
+func validateShortMessage(sm *ShortMessage, multi bool) error {
+	if sm.NumberOfMessages != nil && *sm.NumberOfMessages > 99 {
+		return ErrNumberOfMessages
+	}
+	if runes := pdutext.Unencodable(sm.Text); len(runes) > 0 {
+		return &EncodingError{DataCoding: sm.Text.Type(), Runes: runes}
+	}
+	err := validateAddr(pdufield.SourceAddr, sm.Src, sm.SourceAddrTON, sm.SourceAddrNPI)
+	if err != nil {
+		return err
+	}
+	if !multi && sm.Dst == "" {
+		return &AddressError{Field: pdufield.DestinationAddr, Reason: "empty destination"}
+	}
+	if sm.Dst != "" {
+		err = validateAddr(pdufield.DestinationAddr, sm.Dst, sm.DestAddrTON, sm.DestAddrNPI)
+		if err != nil {
+			return err
+		}
+	}
+	for _, dst := range sm.DstList {
+		err = validateAddr(pdufield.DestinationList, dst, sm.DestAddrTON, sm.DestAddrNPI)
+		if err != nil {
+			return err
+		}
+	}
+	for _, d := range sm.Dsts {
+		err = validateAddr(pdufield.DestinationList, d.Addr, d.TON, d.NPI)
+		if err != nil {
+			return err
+		}
+	}
+	for _, dl := range sm.DLs {
+		if len(dl) > maxDLNameLen {
+			return &AddressError{
+				Field:  pdufield.DestinationList,
+				Addr:   dl,
+				Reason: fmt.Sprintf("distribution list name longer than %d octets", maxDLNameLen),
+			}
+		}
+	}
+	return nil
+}
+
+// maxE164Digits is the maximum number of digits of an E.164 number.
+const maxE164Digits = 15
+
+// NormalizeE164 strips whitespace and a leading '+' from addr and
+// checks that the rest is up to 15 digits. It reports whether addr was
+// in international format, with the leading '+'. An *AddressError for
+// destination_addr is returned if addr is invalid.
+func NormalizeE164(addr string) (digits string, international bool, err error) {
+	digits = strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) {
+			return -1
+		}
+		return r
+	}, addr)
+	digits, international = strings.CutPrefix(digits, "+")
+	invalid := func(reason string) error {
+		return &AddressError{Field: pdufield.DestinationAddr, Addr: addr, Reason: reason}
+	}
+	if digits == "" {
+		return "", false, invalid("no digits")
+	}
+	if len(digits) > maxE164Digits {
+		return "", false, invalid(fmt.Sprintf("longer than %d digits", maxE164Digits))
+	}
+	for _, c := range digits {
+		if c < '0' || c > '9' {
+			return "", false, invalid("not a number")
+		}
+	}
+	return digits, international, nil
+}
+
+// maxShortCodeDigits is the maximum number of digits of a short code.
+const maxShortCodeDigits = 8
+
+// SourceTON infers the TON and NPI of the source address src, as done
+// by Submit when AutoSourceTON is enabled:
+//
+//   - a number with a leading '+' is international with the ISDN NPI,
+//     the '+' being stripped from the returned addr
+//   - a number of up to 8 digits is a network specific short code
+//   - a longer number has the unknown TON and the ISDN NPI, as it may be
+//     in national format, e.g. "0612345678"
+//   - anything else, e.g. "BANK", is alphanumeric with the unknown NPI
+func SourceTON(src string) (addr string, ton, npi uint8) {
+	digits, intl := strings.CutPrefix(src, "+")
+	numeric := digits != "" && !strings.ContainsFunc(digits, func(r rune) bool {
+		return r < '0' || r > '9'
+	})
+	switch {
+	case !numeric:
+		return src, pdufield.TONAlphanumeric, pdufield.NPIUnknown
+	case !intl && len(digits) <= maxShortCodeDigits:
+		return digits, pdufield.TONNetworkSpecific, pdufield.NPIUnknown
+	case !intl:
+		return digits, pdufield.TONUnknown, pdufield.NPIISDN
+	}
+	return digits, pdufield.TONInternational, pdufield.NPIISDN
+}
+
+// autoSourceTON sets the source TON and NPI of sm in place with
+// SourceTON, unless either is set already, as done by Submit on a copy
+// of the message when AutoSourceTON is enabled.
+func autoSourceTON(sm *ShortMessage) {
+	if sm.Src == "" || sm.SourceAddrTON != pdufield.TONUnknown || sm.SourceAddrNPI != pdufield.NPIUnknown {
+		return
+	}
+	sm.Src, sm.SourceAddrTON, sm.SourceAddrNPI = SourceTON(sm.Src)
+}
+
+// normalizeShortMessage normalizes the destination addresses of sm in
+// place with NormalizeE164, as done by Submit on a copy of the message
+// when NormalizeE164 is enabled. Addresses in international format get
+// the international TON and ISDN NPI. Dst and DstList share DestAddrTON
+// and DestAddrNPI, so an *AddressError is returned if they mix
+// international and national formats.
+func normalizeShortMessage(sm *ShortMessage) error {
+	var intlDsts, nationalDsts int
+	if sm.Dst != "" {
+		dst, intl, err := NormalizeE164(sm.Dst)
+		if err != nil {
+			return err
+		}
+		sm.Dst = dst
+		if intl {
+			intlDsts++
+		} else {
+			nationalDsts++
+		}
+	}
+	for i, d := range sm.DstList {
+		dst, intl, err := NormalizeE164(d)
+		if err != nil {
+			err.(*AddressError).Field = pdufield.DestinationList
+			return err
+		}
+		sm.DstList[i] = dst
+		if intl {
+			intlDsts++
+		} else {
+			nationalDsts++
+		}
+		if intlDsts > 0 && nationalDsts > 0 {
+			return &AddressError{
+				Field:  pdufield.DestinationList,
+				Addr:   d,
+				Reason: "international and national numbers mixed, use Dsts",
+			}
+		}
+	}
+	if intlDsts > 0 {
+		sm.DestAddrTON = pdufield.TONInternational
+		sm.DestAddrNPI = pdufield.NPIISDN
+	}
+	for i, d := range sm.Dsts {
+		dst, intl, err := NormalizeE164(d.Addr)
+		if err != nil {
+			err.(*AddressError).Field = pdufield.DestinationList
+			return err
+		}
+		sm.Dsts[i].Addr = dst
+		if intl {
+			sm.Dsts[i].TON = pdufield.TONInternational
+			sm.Dsts[i].NPI = pdufield.NPIISDN
+		}
+	}
+	return nil
+}
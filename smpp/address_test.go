@@ -0,0 +1,278 @@
+// Copyright 2015 go-smpp authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package smpp
+
+import (
+	"errors"
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/florentchauveau/go-smpp/smpp/pdu/pdufield"
+	"github.com/florentchauveau/go-smpp/smpp/pdu/pdutext"
+)
+
+func TestSubmitStrictValidation(t *testing.T) {
+	tx := &Transmitter{StrictValidation: true}
+	test := []struct {
+		sm    *ShortMessage
+		field pdufield.Name
+	}{
+		{&ShortMessage{Src: "root", Dst: "123456789012345678901"}, pdufield.DestinationAddr},
+		{&ShortMessage{Src: "root", Dst: ""}, pdufield.DestinationAddr},
+		{&ShortMessage{Src: "Sender", SourceAddrTON: pdufield.TONAlphanumeric, SourceAddrNPI: pdufield.NPIISDN, Dst: "123"}, pdufield.SourceAddr},
+		{&ShortMessage{Src: "root", Dst: "+123", DestAddrTON: pdufield.TONInternational, DestAddrNPI: pdufield.NPIISDN}, pdufield.DestinationAddr},
+		{&ShortMessage{Src: "root", Dsts: []Destination{{Addr: "123"}, {Addr: "+123", TON: pdufield.TONInternational, NPI: pdufield.NPIISDN}}}, pdufield.DestinationList},
+		{&ShortMessage{Src: "root", Dsts: []Destination{{Addr: "123", TON: 0x07}}}, pdufield.DestinationList},
+		{&ShortMessage{Src: "root", Dsts: []Destination{{Addr: "Sender", TON: pdufield.TONAlphanumeric, NPI: pdufield.NPIISDN}}}, pdufield.DestinationList},
+		{&ShortMessage{Src: "root", DLs: []string{"list", "a distribution list name"}}, pdufield.DestinationList},
+	}
+	for _, tc := range test {
+		tc.sm.Text = pdutext.Raw("Lorem ipsum")
+		_, err := tx.Submit(tc.sm)
+		var ae *AddressError
+		if !errors.As(err, &ae) {
+			t.Fatalf("unexpected error for %+v: want *AddressError, have %v", tc.sm, err)
+		}
+		if ae.Field != tc.field {
+			t.Fatalf("unexpected field: want %q, have %q", tc.field, ae.Field)
+		}
+	}
+	// A valid message goes through validation and fails on bind state.
+	_, err := tx.Submit(&ShortMessage{
+		Src:           "Sender",
+		SourceAddrTON: pdufield.TONAlphanumeric,
+		Dst:           "123",
+		DestAddrTON:   pdufield.TONInternational,
+		DestAddrNPI:   pdufield.NPIISDN,
+		Text:          pdutext.Raw("Lorem ipsum"),
+	})
+	if err != ErrNotBound {
+		t.Fatalf("unexpected error: want %v, have %v", ErrNotBound, err)
+	}
+	_, err = tx.Submit(&ShortMessage{
+		Src:  "root",
+		Dsts: []Destination{{Addr: "123", TON: pdufield.TONInternational, NPI: pdufield.NPIISDN}},
+		DLs:  []string{"12345678901234567890"},
+		Text: pdutext.Raw("Lorem ipsum"),
+	})
+	if err != ErrNotBound {
+		t.Fatalf("unexpected error: want %v, have %v", ErrNotBound, err)
+	}
+}
+
+func TestSubmitEncodingError(t *testing.T) {
+	tx := &Transmitter{StrictValidation: true}
+	sm := &ShortMessage{Src: "root", Dst: "123", Text: pdutext.GSM7("Привет, 世界 €")}
+	_, err := tx.Submit(sm)
+	var ee *EncodingError
+	if !errors.As(err, &ee) {
+		t.Fatalf("unexpected error: want *EncodingError, have %v", err)
+	}
+	if want := "Привет世界"; string(ee.Runes) != want || ee.DataCoding != pdutext.DefaultType {
+		t.Fatalf("unexpected encoding error: want %q, have %q (%#02x)", want, string(ee.Runes), ee.DataCoding)
+	}
+	if _, err := tx.SubmitLongMsg(sm); !errors.As(err, &ee) {
+		t.Fatalf("unexpected long message error: want *EncodingError, have %v", err)
+	}
+	// Not validated without StrictValidation.
+	tx.StrictValidation = false
+	if _, err := tx.Submit(sm); err != ErrNotBound {
+		t.Fatalf("unexpected error: want %v, have %v", ErrNotBound, err)
+	}
+}
+
+func TestNormalizeE164(t *testing.T) {
+	test := []struct {
+		addr   string
+		digits string
+		intl   bool
+		valid  bool
+	}{
+		{"+33 6 12 34 56 78", "33612345678", true, true},
+		{"+15551234567", "15551234567", true, true},
+		{"0612345678", "0612345678", false, true},
+		{" 06 12\t34 ", "061234", false, true},
+		{"+", "", false, false},
+		{"", "", false, false},
+		{"+33-612", "", false, false},
+		{"06abc", "", false, false},
+		{"++33612", "", false, false},
+		{"+1234567890123456", "", false, false},
+	}
+	for _, tc := range test {
+		digits, intl, err := NormalizeE164(tc.addr)
+		if !tc.valid {
+			var ae *AddressError
+			if !errors.As(err, &ae) {
+				t.Fatalf("unexpected error for %q: want *AddressError, have %v", tc.addr, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("unexpected error for %q: %v", tc.addr, err)
+		}
+		if digits != tc.digits || intl != tc.intl {
+			t.Fatalf("unexpected result for %q: want %q %t, have %q %t", tc.addr, tc.digits, tc.intl, digits, intl)
+		}
+	}
+}
+
+func TestSubmitNormalizeE164(t *testing.T) {
+	tx := &Transmitter{NormalizeE164: true}
+	sm, err := tx.normalize(&ShortMessage{Src: "root", Dst: "+33 6 12 34 56 78", Text: pdutext.Raw("Lorem ipsum")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sm.Dst != "33612345678" {
+		t.Fatalf("unexpected dst: want %q, have %q", "33612345678", sm.Dst)
+	}
+	if sm.DestAddrTON != pdufield.TONInternational || sm.DestAddrNPI != pdufield.NPIISDN {
+		t.Fatalf("unexpected ton/npi: want %#x/%#x, have %#x/%#x",
+			pdufield.TONInternational, pdufield.NPIISDN, sm.DestAddrTON, sm.DestAddrNPI)
+	}
+	sm, err = tx.normalize(&ShortMessage{Src: "root", Dst: "06 12 34 56 78", Text: pdutext.Raw("Lorem ipsum")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sm.Dst != "0612345678" || sm.DestAddrTON != 0 {
+		t.Fatalf("unexpected national dst: %q ton %#x", sm.Dst, sm.DestAddrTON)
+	}
+	var ae *AddressError
+	_, err = tx.Submit(&ShortMessage{Src: "root", Dst: "call me", Text: pdutext.Raw("Lorem ipsum")})
+	if !errors.As(err, &ae) {
+		t.Fatalf("unexpected error: want *AddressError, have %v", err)
+	}
+}
+
+func TestSubmitNormalizeE164DstList(t *testing.T) {
+	tx := &Transmitter{NormalizeE164: true}
+	sm, err := tx.normalize(&ShortMessage{
+		Src:     "root",
+		DstList: []string{"+33 6 12 34 56 78", "+44 7700 900123"},
+		Text:    pdutext.Raw("Lorem ipsum"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"33612345678", "447700900123"}
+	if !slices.Equal(sm.DstList, want) {
+		t.Fatalf("unexpected dst list: want %q, have %q", want, sm.DstList)
+	}
+	if sm.DestAddrTON != pdufield.TONInternational || sm.DestAddrNPI != pdufield.NPIISDN {
+		t.Fatalf("unexpected ton/npi: want %#x/%#x, have %#x/%#x",
+			pdufield.TONInternational, pdufield.NPIISDN, sm.DestAddrTON, sm.DestAddrNPI)
+	}
+	for _, dstList := range [][]string{
+		{"+33612345678", "call me"},
+		{"+33612345678", "0612345678"},
+	} {
+		sm := &ShortMessage{Src: "root", DstList: dstList, Text: pdutext.Raw("Lorem ipsum")}
+		_, err := tx.Submit(sm)
+		var ae *AddressError
+		if !errors.As(err, &ae) || ae.Field != pdufield.DestinationList {
+			t.Fatalf("unexpected error for %q: want *AddressError for %s, have %v", dstList, pdufield.DestinationList, err)
+		}
+	}
+}
+
+func TestSubmitNormalizeE164Unchanged(t *testing.T) {
+	tx := &Transmitter{NormalizeE164: true}
+	newMsg := func(dsts ...Destination) *ShortMessage {
+		return &ShortMessage{
+			Src:     "root",
+			Dst:     "+33 6 12 34 56 78",
+			DstList: []string{"+44 7700 900123"},
+			Dsts:    dsts,
+			Text:    pdutext.Raw("Lorem ipsum"),
+		}
+	}
+	sm := newMsg(Destination{Addr: "+1 202 555 0123"})
+	if _, err := tx.Submit(sm); err != ErrNotBound {
+		t.Fatalf("unexpected error: want %v, have %v", ErrNotBound, err)
+	}
+	if want := newMsg(Destination{Addr: "+1 202 555 0123"}); !reflect.DeepEqual(sm, want) {
+		t.Fatalf("message modified: want %+v, have %+v", want, sm)
+	}
+	sm = newMsg(Destination{Addr: "call me"})
+	_, err := tx.Submit(sm)
+	var ae *AddressError
+	if !errors.As(err, &ae) || ae.Field != pdufield.DestinationList {
+		t.Fatalf("unexpected error: want *AddressError for %s, have %v", pdufield.DestinationList, err)
+	}
+	if want := newMsg(Destination{Addr: "call me"}); !reflect.DeepEqual(sm, want) {
+		t.Fatalf("message modified: want %+v, have %+v", want, sm)
+	}
+}
+
+func TestSourceTON(t *testing.T) {
+	test := []struct {
+		src      string
+		addr     string
+		ton, npi uint8
+	}{
+		{"BANK", "BANK", pdufield.TONAlphanumeric, pdufield.NPIUnknown},
+		{"Bank 24", "Bank 24", pdufield.TONAlphanumeric, pdufield.NPIUnknown},
+		{"+", "+", pdufield.TONAlphanumeric, pdufield.NPIUnknown},
+		{"36000", "36000", pdufield.TONNetworkSpecific, pdufield.NPIUnknown},
+		{"12345678", "12345678", pdufield.TONNetworkSpecific, pdufield.NPIUnknown},
+		{"33612345678", "33612345678", pdufield.TONUnknown, pdufield.NPIISDN},
+		{"0612345678", "0612345678", pdufield.TONUnknown, pdufield.NPIISDN},
+		{"+33612345678", "33612345678", pdufield.TONInternational, pdufield.NPIISDN},
+		{"+1234", "1234", pdufield.TONInternational, pdufield.NPIISDN},
+	}
+	for _, tc := range test {
+		addr, ton, npi := SourceTON(tc.src)
+		if addr != tc.addr || ton != tc.ton || npi != tc.npi {
+			t.Fatalf("unexpected result for %q: want %q %#x/%#x, have %q %#x/%#x",
+				tc.src, tc.addr, tc.ton, tc.npi, addr, ton, npi)
+		}
+	}
+}
+
+func TestSubmitAutoSourceTON(t *testing.T) {
+	tx := &Transmitter{AutoSourceTON: true}
+	test := []struct {
+		sm       *ShortMessage
+		src      string
+		ton, npi uint8
+	}{
+		{&ShortMessage{Src: "BANK"}, "BANK", pdufield.TONAlphanumeric, pdufield.NPIUnknown},
+		{&ShortMessage{Src: "36000"}, "36000", pdufield.TONNetworkSpecific, pdufield.NPIUnknown},
+		{&ShortMessage{Src: "+33612345678"}, "33612345678", pdufield.TONInternational, pdufield.NPIISDN},
+		{&ShortMessage{Src: "0612345678"}, "0612345678", pdufield.TONUnknown, pdufield.NPIISDN},
+		// explicitly set
+		{&ShortMessage{Src: "BANK", SourceAddrNPI: pdufield.NPIPrivate}, "BANK", pdufield.TONUnknown, pdufield.NPIPrivate},
+		{&ShortMessage{Src: "36000", SourceAddrTON: pdufield.TONAbbreviated}, "36000", pdufield.TONAbbreviated, pdufield.NPIUnknown},
+	}
+	for _, tc := range test {
+		tc.sm.Dst = "foobar"
+		tc.sm.Text = pdutext.Raw("Lorem ipsum")
+		sm, err := tx.normalize(tc.sm)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sm.Src != tc.src || sm.SourceAddrTON != tc.ton || sm.SourceAddrNPI != tc.npi {
+			t.Fatalf("unexpected source: want %q %#x/%#x, have %q %#x/%#x",
+				tc.src, tc.ton, tc.npi, sm.Src, sm.SourceAddrTON, sm.SourceAddrNPI)
+		}
+	}
+	// A message reused with another sender gets the TON of the new one.
+	sm := &ShortMessage{Src: "+33612345678", Dst: "foobar", Text: pdutext.Raw("Lorem ipsum")}
+	if _, err := tx.Submit(sm); err != ErrNotBound {
+		t.Fatalf("unexpected error: want %v, have %v", ErrNotBound, err)
+	}
+	if sm.Src != "+33612345678" || sm.SourceAddrTON != pdufield.TONUnknown || sm.SourceAddrNPI != pdufield.NPIUnknown {
+		t.Fatalf("message modified: %q %#x/%#x", sm.Src, sm.SourceAddrTON, sm.SourceAddrNPI)
+	}
+	sm.Src = "BANK"
+	msg, err := tx.normalize(sm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.SourceAddrTON != pdufield.TONAlphanumeric {
+		t.Fatalf("unexpected ton: want %#x, have %#x", pdufield.TONAlphanumeric, msg.SourceAddrTON)
+	}
+}
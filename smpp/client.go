@@ -7,38 +7,52 @@ package smpp
 import (
 	"context"
 	"crypto/tls"
+	"fmt"
 	"io"
 	"math"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/florentchauveau/go-smpp/smpp/pdu"
 	"github.com/florentchauveau/go-smpp/smpp/pdu/pdufield"
+	"github.com/florentchauveau/go-smpp/smpp/pdu/pdutlv"
 )
 
 // ConnStatus is an abstract interface for a connection status change.
 type ConnStatus interface {
 	Status() ConnStatusID
 	Error() error
+	// Addr returns the server address the status refers to.
+	Addr() string
 }
 
 type connStatus struct {
-	s   ConnStatusID
-	err error
+	s    ConnStatusID
+	err  error
+	addr string
 }
 
 func (c *connStatus) Status() ConnStatusID { return c.s }
 func (c *connStatus) Error() error         { return c.err }
+func (c *connStatus) Addr() string         { return c.addr }
 
 // ConnStatusID represents a connection status change.
 type ConnStatusID uint8
 
 // Supported connection statuses.
+//
+// Connecting and Binding are transient states, only reported by the
+// State method of clients and not sent on the Bind channel. Closed is
+// sent once Close is called, before the channel is closed.
 const (
 	Connected ConnStatusID = iota + 1
 	Disconnected
 	ConnectionFailed
 	BindFailed
+	Connecting
+	Binding
+	Closed
 )
 
 var connStatusText = map[ConnStatusID]string{
@@ -46,6 +60,9 @@ var connStatusText = map[ConnStatusID]string{
 	Disconnected:     "Disconnected",
 	ConnectionFailed: "Connection failed",
 	BindFailed:       "Bind failed",
+	Connecting:       "Connecting",
+	Binding:          "Binding",
+	Closed:           "Closed",
 }
 
 // String implements the Stringer interface.
@@ -74,6 +91,11 @@ type ClientConn interface {
 // single context.Context per client connection, passed to Wait
 // prior to sending short messages.
 //
+// Wait is called once per PDU written, so a long message sent with
+// SubmitLongMsg consumes one event per part, or one in total if
+// RateLimitMessages is set. Enquire links and unbind are not rate
+// limited.
+//
 // Suitable for use with package golang.org/x/time/rate.
 type RateLimiter interface {
 	// Wait blocks until the limiter permits an event to happen.
@@ -83,17 +105,35 @@ type RateLimiter interface {
 // client provides a persistent client connection.
 type client struct {
 	Addr               string
+	Addrs              []string
 	TLS                *tls.Config
+	Dialer             Dialer
 	Status             chan ConnStatus
 	BindFunc           func(c Conn) error
 	EnquireLink        time.Duration
 	EnquireLinkTimeout time.Duration
 	RespTimeout        time.Duration
+	ReadTimeout        time.Duration
+	WriteTimeout       time.Duration
+	DecodeOptions      pdufield.DecodeOptions
+	OnWrite            WriteFunc
+	Receipts           *ReceiptTracker
+	OnUnmatched        func(p pdu.Body)
 	BindInterval       time.Duration
+	BindTimeout        time.Duration
+	ConnectTimeout     time.Duration
 	WindowSize         uint
+	MaxInFlight        uint
+	FailOnMaxInFlight  bool
+	SeqStart           uint32
 	RateLimiter        RateLimiter
 
 	// internal stuff.
+	sem   chan struct{} // MaxInFlight slots
+	seq   *pdu.Sequence // SeqStart sequence, or nil
+	state atomic.Uint32
+	peer  atomic.Uint32 // sc_interface_version | peerVersionSet
+	sysID atomic.Value  // system_id of the bind response
 	inbox chan pdu.Body
 	conn  *connSwitch
 	stop  chan struct{}
@@ -106,11 +146,18 @@ type client struct {
 
 func (c *client) init() {
 	c.conn = &connSwitch{}
+	c.state.Store(uint32(Disconnected))
 	c.stop = make(chan struct{})
+	if c.MaxInFlight > 0 {
+		c.sem = make(chan struct{}, c.MaxInFlight)
+	}
+	if c.SeqStart > 0 {
+		c.seq = pdu.NewSequence(c.SeqStart)
+	}
 	if c.RateLimiter != nil {
 		c.lmctx = context.Background()
 	}
-	if c.EnquireLink < 10*time.Second {
+	if c.EnquireLink >= 0 && c.EnquireLink < 10*time.Second {
 		c.EnquireLink = 10 * time.Second
 	}
 
@@ -127,34 +174,32 @@ func (c *client) Bind() {
 	for !c.closed() {
 		eli := make(chan struct{})
 		c.inbox = make(chan pdu.Body)
-		conn, err := Dial(c.Addr, c.TLS)
-		if err != nil {
-			c.notify(&connStatus{
-				s:   ConnectionFailed,
-				err: err,
-			})
+		addr, status := c.connect()
+		if status != nil {
+			c.notify(status)
 			goto retry
 		}
-		c.conn.Set(conn)
-		if err = c.BindFunc(c.conn); err != nil {
-			c.notify(&connStatus{s: BindFailed, err: err})
-			goto retry
+		if c.EnquireLink > 0 {
+			go c.enquireLink(eli)
 		}
-		go c.enquireLink(eli)
-		c.notify(&connStatus{s: Connected})
+		c.notify(&connStatus{s: Connected, addr: addr})
 		delay = 1
 	Loop:
 		for {
 			p, err := c.conn.Read()
 			if err != nil {
-				c.notify(&connStatus{
-					s:   Disconnected,
-					err: err,
-				})
+				if !c.closed() {
+					c.notify(&connStatus{
+						s:    Disconnected,
+						err:  err,
+						addr: addr,
+					})
+				}
 				break
 			}
 			switch p.Header().ID {
 			case pdu.EnquireLinkID:
+				// Always answered, even without outbound keepalive.
 				pResp := pdu.NewEnquireLinkRespSeq(p.Header().Seq)
 				err := c.conn.Write(pResp)
 				if err != nil {
@@ -162,6 +207,21 @@ func (c *client) Bind() {
 				}
 			case pdu.EnquireLinkRespID:
 				c.updateEliTime()
+			case pdu.UnbindID:
+				if c.closed() {
+					// Crossed our own unbind, pass it to Close.
+					c.inbox <- p
+					break
+				}
+				// The server ends the session, acknowledge and
+				// bind again after the retry interval.
+				_ = c.conn.Write(pdu.NewUnbindRespSeq(p.Header().Seq))
+				c.notify(&connStatus{
+					s:    Disconnected,
+					err:  ErrUnbound,
+					addr: addr,
+				})
+				break Loop
 			default:
 				c.inbox <- p
 			}
@@ -177,9 +237,63 @@ func (c *client) Bind() {
 		}
 		c.trysleep(delayDuration)
 	}
+	c.notify(&connStatus{s: Closed})
 	close(c.Status)
 }
 
+// connect dials and binds to the client addresses in order, until
+// one succeeds. It returns that address, or the status of the last
+// failure.
+func (c *client) connect() (string, *connStatus) {
+	var addrs []string
+	if c.Addr != "" || len(c.Addrs) == 0 {
+		addrs = append(addrs, c.Addr)
+	}
+	addrs = append(addrs, c.Addrs...)
+	var status *connStatus
+	for _, addr := range addrs {
+		c.setState(Connecting)
+		conn, err := dial(addr, c.TLS, connOptions{
+			dialer:       c.Dialer,
+			dialTimeout:  c.ConnectTimeout,
+			readTimeout:  c.ReadTimeout,
+			writeTimeout: c.WriteTimeout,
+			decode:       c.DecodeOptions,
+			onWrite:      c.OnWrite,
+		})
+		if err != nil {
+			status = &connStatus{
+				s:    ConnectionFailed,
+				err:  err,
+				addr: addr,
+			}
+			continue
+		}
+		c.conn.Set(conn)
+		c.setState(Binding)
+		if err = c.bind(conn); err != nil {
+			status = &connStatus{s: BindFailed, err: err, addr: addr}
+			continue
+		}
+		return addr, nil
+	}
+	return "", status
+}
+
+// bind calls BindFunc, closing conn if the bind response is not
+// received within BindTimeout.
+func (c *client) bind(conn Conn) error {
+	if c.BindTimeout <= 0 {
+		return c.BindFunc(c.conn)
+	}
+	timer := time.AfterFunc(c.BindTimeout, func() { conn.Close() })
+	err := c.BindFunc(c.conn)
+	if !timer.Stop() {
+		return ErrBindTimeout
+	}
+	return err
+}
+
 func (c *client) enquireLink(stop chan struct{}) {
 	// for the first check set time as Now()
 	c.updateEliTime()
@@ -215,12 +329,56 @@ func (c *client) updateEliTime() {
 }
 
 func (c *client) notify(ev ConnStatus) {
+	c.setState(ev.Status())
 	select {
 	case c.Status <- ev:
 	default:
 	}
 }
 
+func (c *client) setState(s ConnStatusID) {
+	if c.closed() {
+		s = Closed
+	}
+	c.state.Store(uint32(s))
+}
+
+// State returns the current connection state.
+func (c *client) State() ConnStatusID {
+	return ConnStatusID(c.state.Load())
+}
+
+const peerVersionSet = 0x100
+
+// setPeer records the system_id and sc_interface_version of the bind
+// response p.
+func (c *client) setPeer(p pdu.Body) {
+	var id string
+	if f := p.Fields()[pdufield.SystemID]; f != nil {
+		id = f.String()
+	}
+	c.sysID.Store(id)
+	v, ok := p.TLVFields().Uint8(pdutlv.TagScInterfaceVersion)
+	if !ok {
+		c.peer.Store(0)
+		return
+	}
+	c.peer.Store(uint32(v) | peerVersionSet)
+}
+
+// PeerInterfaceVersion returns the sc_interface_version of the last
+// bind response, if any.
+func (c *client) PeerInterfaceVersion() (uint8, bool) {
+	v := c.peer.Load()
+	return uint8(v), v&peerVersionSet != 0
+}
+
+// PeerSystemID returns the system_id of the last bind response.
+func (c *client) PeerSystemID() string {
+	id, _ := c.sysID.Load().(string)
+	return id
+}
+
 // Read reads PDU binary data off the wire and returns it.
 func (c *client) Read() (pdu.Body, error) {
 	select {
@@ -243,6 +401,7 @@ func (c *client) Write(w pdu.Body) error {
 func (c *client) Close() error {
 	c.once.Do(func() {
 		close(c.stop)
+		c.setState(Closed)
 		if err := c.conn.Write(pdu.NewUnbind()); err == nil {
 			select {
 			case <-c.inbox: // TODO: validate UnbindResp
@@ -281,10 +440,16 @@ func (c *client) respTimeout() <-chan time.Time {
 	return time.After(c.RespTimeout)
 }
 
-// bind attempts to bind the connection.
-func bind(c Conn, p pdu.Body) (pdu.Body, error) {
+// bind attempts to bind the connection. The TLVs are sent after the
+// address_range of the bind PDU.
+func bind(c Conn, p pdu.Body, tlvs pdutlv.Fields) (pdu.Body, error) {
 	f := p.Fields()
 	_ = f.Set(pdufield.InterfaceVersion, 0x34)
+	for tag, v := range tlvs {
+		if err := p.TLVFields().Set(tag, v); err != nil {
+			return nil, fmt.Errorf("bind TLV %s: %v", tag.Hex(), err)
+		}
+	}
 	err := c.Write(p)
 	if err != nil {
 		return nil, err
@@ -295,7 +460,24 @@ func bind(c Conn, p pdu.Body) (pdu.Body, error) {
 	}
 	h := resp.Header()
 	if h.Status != 0 {
-		return nil, h.Status
+		return nil, &BindError{ID: h.ID, Status: h.Status}
 	}
 	return resp, nil
 }
+
+// BindError is reported by the BindFailed connection status when the
+// server rejects the bind with a non-zero command status.
+type BindError struct {
+	ID     pdu.ID     // ID of the bind response.
+	Status pdu.Status // Command status, e.g. pdu.StatusInvPaswd.
+}
+
+// Error implements the Error interface.
+func (e *BindError) Error() string {
+	return fmt.Sprintf("%s: %s (%s)", e.ID, e.Status.Name(), e.Status)
+}
+
+// Unwrap returns the command status.
+func (e *BindError) Unwrap() error {
+	return e.Status
+}
@@ -0,0 +1,135 @@
+// Copyright 2015 go-smpp authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package smpp
+
+import (
+	"testing"
+	"time"
+
+	"github.com/florentchauveau/go-smpp/smpp/pdu"
+	"github.com/florentchauveau/go-smpp/smpp/smpptest"
+)
+
+func TestConnStatusString(t *testing.T) {
+	for s, want := range map[ConnStatusID]string{
+		Connected:        "Connected",
+		Disconnected:     "Disconnected",
+		ConnectionFailed: "Connection failed",
+		BindFailed:       "Bind failed",
+		Connecting:       "Connecting",
+		Binding:          "Binding",
+		Closed:           "Closed",
+	} {
+		if have := s.String(); have != want {
+			t.Fatalf("unexpected string for %d: want %q, have %q", s, want, have)
+		}
+	}
+	if Connected != 1 {
+		t.Fatalf("unexpected value for Connected: want 1, have %d", Connected)
+	}
+}
+
+func TestState(t *testing.T) {
+	s := smpptest.NewServer()
+	defer s.Close()
+	tx := &Transmitter{
+		Addr:   s.Addr(),
+		User:   smpptest.DefaultUser,
+		Passwd: smpptest.DefaultPasswd,
+	}
+	if st := tx.State(); st != Disconnected {
+		t.Fatalf("unexpected state: want %s, have %s", Disconnected, st)
+	}
+	connc := tx.Bind()
+	if conn := <-connc; conn.Status() != Connected {
+		t.Fatal(conn.Error())
+	}
+	if st := tx.State(); st != Connected {
+		t.Fatalf("unexpected state: want %s, have %s", Connected, st)
+	}
+	tx.Close()
+	if st := tx.State(); st != Closed {
+		t.Fatalf("unexpected state: want %s, have %s", Closed, st)
+	}
+	if conn := <-connc; conn.Status() != Closed {
+		t.Fatalf("unexpected status: want %s, have %s", Closed, conn.Status())
+	}
+}
+
+func TestServerUnbind(t *testing.T) {
+	unbindResp := make(chan pdu.Body, 1)
+	s := smpptest.NewUnstartedServer()
+	s.Handler = func(c smpptest.Conn, p pdu.Body) {
+		switch p.Header().ID {
+		case pdu.UnbindRespID:
+			unbindResp <- p
+		case pdu.UnbindID:
+			_ = c.Write(pdu.NewUnbindRespSeq(p.Header().Seq))
+		}
+	}
+	s.Start()
+	defer s.Close()
+	rx := &Receiver{
+		Addr:         s.Addr(),
+		User:         smpptest.DefaultUser,
+		Passwd:       smpptest.DefaultPasswd,
+		BindInterval: 10 * time.Millisecond,
+	}
+	defer rx.Close()
+	status := rx.Bind()
+	if conn := <-status; conn.Status() != Connected {
+		t.Fatal(conn.Error())
+	}
+	unbind := pdu.NewUnbind()
+	s.BroadcastMessage(unbind)
+	select {
+	case p := <-unbindResp:
+		if p.Header().Seq != unbind.Header().Seq {
+			t.Fatalf("unexpected unbind_resp seq: want %d, have %d", unbind.Header().Seq, p.Header().Seq)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no unbind_resp")
+	}
+	conn := <-status
+	if conn.Status() != Disconnected || conn.Error() != ErrUnbound {
+		t.Fatalf("unexpected status: want %s (%v), have %s (%v)", Disconnected, ErrUnbound, conn.Status(), conn.Error())
+	}
+	if conn := <-status; conn.Status() != Connected {
+		t.Fatalf("unexpected status: want %s, have %s", Connected, conn.Status())
+	}
+}
+
+func TestAnswerEnquireLink(t *testing.T) {
+	resps := make(chan pdu.Body, 1)
+	s := smpptest.NewUnstartedServer()
+	s.Handlers = map[pdu.ID]smpptest.HandlerFunc{
+		pdu.EnquireLinkRespID: func(c smpptest.Conn, p pdu.Body) { resps <- p },
+	}
+	s.Start()
+	defer s.Close()
+	tx := &Transmitter{
+		Addr:        s.Addr(),
+		User:        smpptest.DefaultUser,
+		Passwd:      smpptest.DefaultPasswd,
+		EnquireLink: -1, // no outbound keepalive
+	}
+	defer tx.Close()
+	conn := <-tx.Bind()
+	switch conn.Status() {
+	case Connected:
+	default:
+		t.Fatal(conn.Error())
+	}
+	p := pdu.NewEnquireLink()
+	s.BroadcastMessage(p)
+	select {
+	case r := <-resps:
+		if r.Header().Seq != p.Header().Seq {
+			t.Fatalf("unexpected enquire_link_resp seq: want %d, have %d", p.Header().Seq, r.Header().Seq)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for enquire_link_resp")
+	}
+}
@@ -9,11 +9,12 @@ import (
 	"bytes"
 	"crypto/tls"
 	"errors"
-	"io"
 	"net"
 	"sync"
+	"time"
 
 	"github.com/florentchauveau/go-smpp/smpp/pdu"
+	"github.com/florentchauveau/go-smpp/smpp/pdu/pdufield"
 )
 
 var (
@@ -26,6 +27,14 @@ var (
 
 	// ErrTimeout is returned when we've reached timeout while waiting for response.
 	ErrTimeout = errors.New("timeout waiting for response")
+
+	// ErrUnbound is reported with the Disconnected status when the
+	// server ends the session with an unbind.
+	ErrUnbound = errors.New("unbound by server")
+
+	// ErrBindTimeout is reported when the bind response is not received
+	// within the client BindTimeout.
+	ErrBindTimeout = errors.New("timeout waiting for bind response")
 )
 
 // Conn is an SMPP connection.
@@ -56,10 +65,44 @@ type Closer interface {
 // Dial dials to the SMPP server and returns a Conn, or error.
 // TLS is only used if provided.
 func Dial(addr string, TLS *tls.Config) (Conn, error) {
+	return dial(addr, TLS, connOptions{})
+}
+
+// Dialer opens the network connections of clients, e.g. to connect
+// through a proxy, or to an in-memory net.Pipe in tests. *net.Dialer
+// implements Dialer.
+type Dialer interface {
+	// Dial connects to addr on the named network, "tcp".
+	Dial(network, addr string) (net.Conn, error)
+}
+
+// WriteFunc is called with every PDU written to a connection and the
+// exact bytes that were sent on the wire, header included.
+type WriteFunc func(p pdu.Body, wire []byte)
+
+// connOptions configures the connections created by clients.
+type connOptions struct {
+	dialer      Dialer // ignores dialTimeout if set
+	dialTimeout time.Duration
+
+	// deadlines are reset before every PDU, if set.
+	readTimeout  time.Duration
+	writeTimeout time.Duration
+
+	decode  pdufield.DecodeOptions
+	onWrite WriteFunc
+}
+
+// dial is like Dial, with the given connection options.
+func dial(addr string, TLS *tls.Config, opts connOptions) (Conn, error) {
 	if addr == "" {
 		addr = "localhost:2775"
 	}
-	fd, err := net.Dial("tcp", addr)
+	d := opts.dialer
+	if d == nil {
+		d = &net.Dialer{Timeout: opts.dialTimeout}
+	}
+	fd, err := d.Dial("tcp", addr)
 	if err != nil {
 		return nil, err
 	}
@@ -67,9 +110,10 @@ func Dial(addr string, TLS *tls.Config) (Conn, error) {
 		fd = tls.Client(fd, TLS)
 	}
 	c := &conn{
-		rwc: fd,
-		r:   bufio.NewReader(fd),
-		w:   bufio.NewWriter(fd),
+		rwc:         fd,
+		r:           bufio.NewReader(fd),
+		w:           bufio.NewWriter(fd),
+		connOptions: opts,
 	}
 	return c, nil
 }
@@ -80,25 +124,53 @@ type conn struct {
 	rwc net.Conn
 	r   *bufio.Reader
 	w   *bufio.Writer
+	connOptions
 }
 
 // Read implements the Conn interface.
+//
+// The header and body are read in full as declared by command_length,
+// so a PDU spanning several TCP segments is reassembled.
 func (c *conn) Read() (pdu.Body, error) {
-	return pdu.Decode(c.r)
+	if c.readTimeout > 0 {
+		if err := c.rwc.SetReadDeadline(time.Now().Add(c.readTimeout)); err != nil {
+			return nil, err
+		}
+	}
+	return pdu.DecodeWithOptions(c.r, c.decode)
 }
 
 // Write implements the Conn interface.
+//
+// If a write timeout is set and expires, the connection is closed
+// because the PDU may have been partially written.
 func (c *conn) Write(w pdu.Body) error {
+	if c.writeTimeout > 0 {
+		if err := c.rwc.SetWriteDeadline(time.Now().Add(c.writeTimeout)); err != nil {
+			return err
+		}
+	}
+	var err error
 	var b bytes.Buffer
-	err := w.SerializeTo(&b)
-	if err != nil {
-		return err
+	if c.onWrite != nil {
+		if err = w.SerializeTo(&b); err == nil {
+			_, err = c.w.Write(b.Bytes())
+		}
+	} else {
+		// PDUs are written in a single call, nothing is buffered
+		// if serialization fails.
+		err = w.SerializeTo(c.w)
 	}
-	_, err = io.Copy(c.w, &b)
-	if err != nil {
-		return err
+	if err == nil {
+		err = c.w.Flush()
+	}
+	if ne, ok := err.(net.Error); ok && ne.Timeout() {
+		c.rwc.Close()
 	}
-	return c.w.Flush()
+	if err == nil && c.onWrite != nil {
+		c.onWrite(w, b.Bytes())
+	}
+	return err
 }
 
 // Close implements the Conn interface.
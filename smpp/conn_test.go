@@ -5,10 +5,16 @@
 package smpp
 
 import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"net"
 	"testing"
 
 	"github.com/florentchauveau/go-smpp/smpp/pdu"
 	"github.com/florentchauveau/go-smpp/smpp/pdu/pdufield"
+	"github.com/florentchauveau/go-smpp/smpp/pdu/pdutext"
 	"github.com/florentchauveau/go-smpp/smpp/smpptest"
 )
 
@@ -32,3 +38,137 @@ func TestConn(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestConnPartialReads(t *testing.T) {
+	sm := pdu.NewDeliverSM()
+	_ = sm.Fields().Set(pdufield.SourceAddr, "root")
+	_ = sm.Fields().Set(pdufield.ShortMessage, pdutext.Raw("Lorem ipsum dolor sit amet"))
+	want := []pdu.Body{sm, pdu.NewEnquireLink(), sm}
+	var b bytes.Buffer
+	var n int // start of the last PDU
+	for _, p := range want {
+		n = b.Len()
+		if err := p.SerializeTo(&b); err != nil {
+			t.Fatal(err)
+		}
+	}
+	frames := b.Bytes()
+	c1, c2 := net.Pipe()
+	go func() {
+		// Split within the length, the header and the body, and
+		// end a write within the next PDU.
+		for _, cut := range [][2]int{{0, 2}, {2, 9}, {9, 20}, {20, 40}, {40, n + 3}, {n + 3, len(frames) - 1}} {
+			if _, err := c1.Write(frames[cut[0]:cut[1]]); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+		_, _ = c1.Write(frames[len(frames)-1:])
+		c1.Close()
+	}()
+	c := &conn{rwc: c2, r: bufio.NewReader(c2), w: bufio.NewWriter(c2)}
+	defer c.Close()
+	for _, w := range want {
+		p, err := c.Read()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.Header().ID != w.Header().ID || p.Header().Seq != w.Header().Seq {
+			t.Fatalf("unexpected PDU: want %s seq %d, have %s seq %d",
+				w.Header().ID, w.Header().Seq, p.Header().ID, p.Header().Seq)
+		}
+		if p.Header().ID != pdu.DeliverSMID {
+			continue
+		}
+		if text := p.Fields()[pdufield.ShortMessage].String(); text != "Lorem ipsum dolor sit amet" {
+			t.Fatalf("unexpected short message: %q", text)
+		}
+	}
+	if _, err := c.Read(); err != io.EOF {
+		t.Fatalf("unexpected read after close: want %v, have %v", io.EOF, err)
+	}
+}
+
+func TestConnTruncatedRead(t *testing.T) {
+	var b bytes.Buffer
+	if err := pdu.NewEnquireLink().SerializeTo(&b); err != nil {
+		t.Fatal(err)
+	}
+	c1, c2 := net.Pipe()
+	go func() {
+		_, _ = c1.Write(b.Bytes()[:10])
+		c1.Close()
+	}()
+	c := &conn{rwc: c2, r: bufio.NewReader(c2), w: bufio.NewWriter(c2)}
+	defer c.Close()
+	if _, err := c.Read(); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("unexpected error: want %v, have %v", io.ErrUnexpectedEOF, err)
+	}
+}
+
+// pipeDialer is a Dialer returning one end of an in-memory pipe.
+type pipeDialer struct {
+	conn net.Conn
+}
+
+func (d pipeDialer) Dial(network, addr string) (net.Conn, error) {
+	return d.conn, nil
+}
+
+func TestDialer(t *testing.T) {
+	cli, srv := net.Pipe()
+	defer srv.Close()
+	go func() {
+		for {
+			p, err := pdu.Decode(srv)
+			if err != nil {
+				return
+			}
+			var r pdu.Body
+			switch p.Header().ID {
+			case pdu.BindTransmitterID:
+				r = pdu.NewBindTransmitterResp()
+				_ = r.Fields().Set(pdufield.SystemID, "pipe")
+			case pdu.SubmitSMID:
+				r = pdu.NewSubmitSMResp()
+				_ = r.Fields().Set(pdufield.MessageID, "foobar")
+			case pdu.UnbindID:
+				r = pdu.NewUnbindResp()
+			default:
+				continue
+			}
+			r.Header().Seq = p.Header().Seq
+			if err := r.SerializeTo(srv); err != nil {
+				return
+			}
+		}
+	}()
+	tx := &Transmitter{
+		Addr:        "pipe",
+		User:        smpptest.DefaultUser,
+		Passwd:      smpptest.DefaultPasswd,
+		EnquireLink: -1,
+		Dialer:      pipeDialer{cli},
+	}
+	defer tx.Close()
+	conn := <-tx.Bind()
+	switch conn.Status() {
+	case Connected:
+	default:
+		t.Fatal(conn.Error())
+	}
+	if id := tx.PeerSystemID(); id != "pipe" {
+		t.Fatalf("unexpected system_id: want %q, have %q", "pipe", id)
+	}
+	sm, err := tx.Submit(&ShortMessage{
+		Src:  "root",
+		Dst:  "foobar",
+		Text: pdutext.Raw("Lorem ipsum"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id := sm.RespID(); id != "foobar" {
+		t.Fatalf("unexpected message id: want %q, have %q", "foobar", id)
+	}
+}
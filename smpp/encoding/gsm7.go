@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"errors"
 	"math"
+	"unicode/utf8"
 
 	"golang.org/x/text/encoding"
 	"golang.org/x/text/transform"
@@ -26,6 +27,10 @@ Source: https://en.wikipedia.org/wiki/GSM_03.38#GSM_7-bit_default_alphabet_and_e
 */
 const escapeSequence = 0x1B
 
+// EscapeSequence is the GSM 7-bit escape to the extension table. It
+// is followed by one septet of the extension table, e.g. 0x65 for '€'.
+const EscapeSequence = escapeSequence
+
 var forwardLookup = map[rune]byte{
 	'@': 0x00, '£': 0x01, '$': 0x02, '¥': 0x03, 'è': 0x04, 'é': 0x05, 'ù': 0x06, 'ì': 0x07,
 	'ò': 0x08, 'Ç': 0x09, '\n': 0x0a, 'Ø': 0x0b, 'ø': 0x0c, '\r': 0x0d, 'Å': 0x0e, 'å': 0x0f,
@@ -47,6 +52,19 @@ var forwardLookup = map[rune]byte{
 var forwardEscape = map[rune]byte{
 	'\f': 0x0A, '^': 0x14, '{': 0x28, '}': 0x29, '\\': 0x2F, '[': 0x3C, '~': 0x3D, ']': 0x3E, '|': 0x40, '€': 0x65,
 }
+
+// asciiIdentity reports, for each ASCII byte, whether it encodes to the
+// same septet value, e.g. 'A' is 0x41 in both.
+var asciiIdentity [utf8.RuneSelf]bool
+
+func init() {
+	for r, v := range forwardLookup {
+		if r < utf8.RuneSelf && byte(r) == v {
+			asciiIdentity[r] = true
+		}
+	}
+}
+
 var reverseLookup = map[byte]rune{
 	0x00: '@', 0x01: '£', 0x02: '$', 0x03: '¥', 0x04: 'è', 0x05: 'é', 0x06: 'ù', 0x07: 'ì',
 	0x08: 'ò', 0x09: 'Ç', 0x0a: '\n', 0x0b: 'Ø', 0x0c: 'ø', 0x0d: '\r', 0x0e: 'Å', 0x0f: 'å',
@@ -216,7 +234,9 @@ func (g *gsm7Decoder) Transform(dst, src []byte, atEOF bool) (nDst, nSrc int, er
 		if b == escapeSequence {
 			nSeptet++
 			if nSeptet >= len(septets) {
-				return 0, 0, ErrInvalidByte
+				// A lone trailing escape has no character to extend,
+				// e.g. a split extension character, and is ignored.
+				break
 			}
 			e := septets[nSeptet]
 			if r, ok := reverseEscape[e]; ok {
@@ -255,22 +275,19 @@ func (g *gsm7Encoder) Transform(dst, src []byte, atEOF bool) (nDst, nSrc int, er
 		return 0, 0, nil
 	}
 
-	text := string(src) // work with []rune (a.k.a string) instead of []byte
-	septets := make([]byte, 0, len(text))
-	for _, r := range text {
-		if v, ok := forwardLookup[r]; ok {
-			septets = append(septets, v)
-		} else if v, ok := forwardEscape[r]; ok {
-			septets = append(septets, escapeSequence, v)
-		} else {
-			return 0, 0, ErrInvalidCharacter
+	septets := src
+	if !isASCIIIdentity(src) {
+		septets, nSrc, err = encodeSeptets(src)
+		if err != nil {
+			return 0, 0, err
 		}
-		nSrc++
+	} else {
+		nSrc = len(src)
 	}
 
 	nDst = len(septets)
 	if g.packed {
-		nDst = int(math.Ceil(float64(len(septets)) * 7 / 8))
+		nDst = packedLen(len(septets))
 	}
 	if len(dst) < nDst {
 		return 0, 0, transform.ErrShortDst
@@ -281,6 +298,64 @@ func (g *gsm7Encoder) Transform(dst, src []byte, atEOF bool) (nDst, nSrc int, er
 		return nDst, nSrc, nil
 	}
 
+	return packSeptets(dst, septets), nSrc, nil
+}
+
+// isASCIIIdentity reports whether every byte of src is ASCII and encodes
+// to itself, in which case src is already its own septets.
+func isASCIIIdentity(src []byte) bool {
+	for _, b := range src {
+		if b >= utf8.RuneSelf || !asciiIdentity[b] {
+			return false
+		}
+	}
+	return true
+}
+
+// encodeSeptets maps each rune of src to its septet, or escape sequence,
+// and returns the septets and the number of runes read.
+func encodeSeptets(src []byte) (septets []byte, n int, err error) {
+	text := string(src) // work with []rune (a.k.a string) instead of []byte
+	septets = make([]byte, 0, len(text))
+	for _, r := range text {
+		if v, ok := forwardLookup[r]; ok {
+			septets = append(septets, v)
+		} else if v, ok := forwardEscape[r]; ok {
+			septets = append(septets, escapeSequence, v)
+		} else {
+			return nil, 0, ErrInvalidCharacter
+		}
+		n++
+	}
+	return septets, n, nil
+}
+
+// Septets maps text to GSM 7-bit septets, one per octet, with
+// characters of the extension table escaped. The result is the unpacked
+// encoding of text, and can be packed with PackSeptets.
+func Septets(text []byte) ([]byte, error) {
+	if isASCIIIdentity(text) {
+		return append([]byte(nil), text...), nil
+	}
+	septets, _, err := encodeSeptets(text)
+	return septets, err
+}
+
+// PackSeptets packs septets into octets, 8 septets per 7 octets.
+func PackSeptets(septets []byte) []byte {
+	dst := make([]byte, packedLen(len(septets)))
+	packSeptets(dst, septets)
+	return dst
+}
+
+// packedLen returns the number of octets of n packed septets.
+func packedLen(n int) int {
+	return int(math.Ceil(float64(n) * 7 / 8))
+}
+
+// packSeptets packs septets into dst, which must hold packedLen octets,
+// and returns the number of octets written.
+func packSeptets(dst, septets []byte) (nDst int) {
 	nDst = 0
 	nSeptet := 0
 	remain := len(septets) - nSeptet
@@ -350,5 +425,5 @@ func (g *gsm7Encoder) Transform(dst, src []byte, atEOF bool) (nDst, nSrc int, er
 		}
 		remain = len(septets) - nSeptet
 	}
-	return nDst, nSrc, err
+	return nDst
 }
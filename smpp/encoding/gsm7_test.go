@@ -88,7 +88,6 @@ var invalidByteTests = []struct {
 	Buff   []byte
 }{
 	{Packed: false, Buff: []byte{0x80}},
-	{Packed: false, Buff: []byte{0x1B}},
 	{Packed: false, Buff: []byte{0x1B, 0x80}},
 }
 
@@ -204,3 +203,99 @@ func TestInvalidByte(t *testing.T) {
 		}
 	}
 }
+
+func TestDecodeEscape(t *testing.T) {
+	tests := []struct {
+		Packed bool
+		Buff   []byte
+		Text   string
+	}{
+		{Packed: false, Buff: []byte{0x1B, 0x65}, Text: "€"},
+		{Packed: false, Buff: []byte{0x1B, 0x3C}, Text: "["},
+		{Packed: false, Buff: []byte{0x1B, 0x28}, Text: "{"},
+		{Packed: false, Buff: []byte{0x31, 0x1B, 0x65, 0x1B, 0x3C, 0x1B, 0x28, 0x32}, Text: "1€[{2"},
+		{Packed: false, Buff: []byte{0x31, 0x32, 0x1B}, Text: "12"},
+		{Packed: false, Buff: []byte{0x1B}, Text: ""},
+		{Packed: true, Buff: []byte{0x9B, 0x32}, Text: "€"},
+		{Packed: true, Buff: []byte{0xB1, 0x0D}, Text: "1"},
+	}
+	for index, row := range tests {
+		decoder := GSM7(row.Packed).NewDecoder()
+		have, _, err := transform.Bytes(decoder, row.Buff)
+		if err != nil {
+			t.Fatalf("%2d: unexpected error: %v", index, err)
+		}
+		if string(have) != row.Text {
+			t.Fatalf("%2d: unexpected text: want %q, have %q", index, row.Text, have)
+		}
+	}
+	for r, e := range forwardEscape {
+		have, _, err := transform.Bytes(GSM7(false).NewDecoder(), []byte{escapeSequence, e})
+		if err != nil {
+			t.Fatalf("unexpected error for %q: %v", r, err)
+		}
+		if string(have) != string(r) {
+			t.Fatalf("unexpected text: want %q, have %q", string(r), have)
+		}
+	}
+}
+
+func TestASCIIFastPath(t *testing.T) {
+	var all []rune
+	for r := range forwardLookup {
+		all = append(all, r)
+	}
+	for r := range forwardEscape {
+		all = append(all, r)
+	}
+	for r := rune(0); r < 0x80; r++ {
+		all = append(all, r)
+	}
+	for _, r := range all {
+		for _, text := range []string{string(r), "Hello " + string(r) + " world"} {
+			want, _, wantErr := encodeSeptets([]byte(text))
+			for _, packed := range []bool{false, true} {
+				have, _, err := transform.Bytes(GSM7(packed).NewEncoder(), []byte(text))
+				if err != nil || wantErr != nil {
+					if (err != nil) != (wantErr != nil) {
+						t.Fatalf("unexpected error for %q: want %v, have %v", text, wantErr, err)
+					}
+					continue
+				}
+				if !packed && !reflect.DeepEqual(have, want) {
+					t.Fatalf("unexpected encoding for %q: want %x, have %x", text, want, have)
+				}
+				decoded, _, err := transform.Bytes(GSM7(packed).NewDecoder(), have)
+				if err != nil {
+					t.Fatalf("unexpected decode error for %q: %v", text, err)
+				}
+				if string(decoded) != text {
+					t.Fatalf("unexpected round trip: want %q, have %q", text, decoded)
+				}
+			}
+		}
+	}
+}
+
+func BenchmarkGSM7Encode(b *testing.B) {
+	inputs := []struct {
+		name string
+		text string
+	}{
+		{"ascii", "Your verification code is 123456. Do not share it with anyone, it expires in 10 minutes."},
+		{"extended", "Votre code est 123456. Ne le partagez pas, il expire dans 10 minutes: 5€ [ré-essai] ~ à bientüt"},
+	}
+	for _, in := range inputs {
+		b.Run(in.name, func(b *testing.B) {
+			src := []byte(in.text)
+			encoder := GSM7(false).NewEncoder()
+			b.ReportAllocs()
+			b.SetBytes(int64(len(src)))
+			for b.Loop() {
+				if _, _, err := transform.Bytes(encoder, src); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
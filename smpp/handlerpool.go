@@ -0,0 +1,41 @@
+// Copyright 2015 go-smpp authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package smpp
+
+import "github.com/florentchauveau/go-smpp/smpp/pdu"
+
+// handlerPool calls a HandlerFunc on a fixed number of goroutines, so
+// that a slow handler does not block the read loop. PDUs wait in a
+// bounded queue for a free worker.
+type handlerPool struct {
+	queue chan pdu.Body
+}
+
+// newHandlerPool starts workers goroutines calling h, with a queue of
+// up to queue PDUs, or workers if queue is not positive.
+func newHandlerPool(h HandlerFunc, workers, queue int) *handlerPool {
+	if queue <= 0 {
+		queue = workers
+	}
+	hp := &handlerPool{queue: make(chan pdu.Body, queue)}
+	for range workers {
+		go func() {
+			for p := range hp.queue {
+				h(p)
+			}
+		}()
+	}
+	return hp
+}
+
+// handle queues p for a worker, waiting for room if the queue is full.
+func (hp *handlerPool) handle(p pdu.Body) {
+	hp.queue <- p
+}
+
+// close stops the workers once the queued PDUs are handled.
+func (hp *handlerPool) close() {
+	close(hp.queue)
+}
@@ -0,0 +1,114 @@
+// Copyright 2015 go-smpp authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package smpp
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/florentchauveau/go-smpp/smpp/pdu/pdutext"
+	"github.com/florentchauveau/go-smpp/smpp/pdu/pdutlv"
+)
+
+// textJSON is the JSON form of the Text of a ShortMessage, with the
+// name of its codec so that it is encoded the same way on submit.
+type textJSON struct {
+	Codec string
+	Data  []byte
+}
+
+// codecNames maps the codecs of pdutext to their JSON names.
+var codecNames = map[string]func(b []byte) pdutext.Codec{
+	"gsm7":       func(b []byte) pdutext.Codec { return pdutext.GSM7(b) },
+	"gsm7packed": func(b []byte) pdutext.Codec { return pdutext.GSM7Packed(b) },
+	"latin1":     func(b []byte) pdutext.Codec { return pdutext.Latin1(b) },
+	"ucs2":       func(b []byte) pdutext.Codec { return pdutext.UCS2(b) },
+	"iso88595":   func(b []byte) pdutext.Codec { return pdutext.ISO88595(b) },
+	"binary":     func(b []byte) pdutext.Codec { return pdutext.Binary(b) },
+	"raw":        func(b []byte) pdutext.Codec { return pdutext.Raw(b) },
+}
+
+func newTextJSON(c pdutext.Codec) (*textJSON, error) {
+	switch c := c.(type) {
+	case nil:
+		return nil, nil
+	case pdutext.GSM7:
+		return &textJSON{"gsm7", c}, nil
+	case pdutext.GSM7Packed:
+		return &textJSON{"gsm7packed", c}, nil
+	case pdutext.Latin1:
+		return &textJSON{"latin1", c}, nil
+	case pdutext.UCS2:
+		return &textJSON{"ucs2", c}, nil
+	case pdutext.ISO88595:
+		return &textJSON{"iso88595", c}, nil
+	case pdutext.Binary:
+		return &textJSON{"binary", c}, nil
+	case pdutext.Raw:
+		return &textJSON{"raw", c}, nil
+	}
+	return nil, fmt.Errorf("smpp: cannot marshal text of type %T", c)
+}
+
+// MarshalJSON implements the json.Marshaler interface, e.g. to queue
+// sm and submit it later from another process. The codec of Text is
+// kept, and TLVFields are marshalled as their binary values. The
+// response of a submitted sm is not marshalled.
+func (sm *ShortMessage) MarshalJSON() ([]byte, error) {
+	type shortMessage ShortMessage // without methods
+	text, err := newTextJSON(sm.Text)
+	if err != nil {
+		return nil, err
+	}
+	var tlv map[pdutlv.Tag][]byte
+	if len(sm.TLVFields) > 0 {
+		m := make(pdutlv.Map)
+		for tag, v := range sm.TLVFields {
+			if err := m.Set(tag, v); err != nil {
+				return nil, fmt.Errorf("smpp: cannot marshal TLV %#04x: %v", uint16(tag), err)
+			}
+		}
+		tlv = make(map[pdutlv.Tag][]byte, len(m))
+		for tag, f := range m {
+			tlv[tag] = f.Bytes()
+		}
+	}
+	return json.Marshal(struct {
+		*shortMessage
+		Text      *textJSON             `json:",omitempty"`
+		TLVFields map[pdutlv.Tag][]byte `json:",omitempty"`
+	}{(*shortMessage)(sm), text, tlv})
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface, and restores
+// a ShortMessage marshalled with MarshalJSON. TLVFields are restored as
+// []byte values.
+func (sm *ShortMessage) UnmarshalJSON(b []byte) error {
+	type shortMessage ShortMessage // without methods
+	v := struct {
+		*shortMessage
+		Text      *textJSON
+		TLVFields map[pdutlv.Tag][]byte
+	}{shortMessage: (*shortMessage)(sm)}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	sm.Text = nil
+	if v.Text != nil {
+		codec, ok := codecNames[v.Text.Codec]
+		if !ok {
+			return fmt.Errorf("smpp: unknown text codec %q", v.Text.Codec)
+		}
+		sm.Text = codec(v.Text.Data)
+	}
+	sm.TLVFields = nil
+	if len(v.TLVFields) > 0 {
+		sm.TLVFields = make(pdutlv.Fields, len(v.TLVFields))
+		for tag, data := range v.TLVFields {
+			sm.TLVFields[tag] = data
+		}
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+// Copyright 2015 go-smpp authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package smpp
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/florentchauveau/go-smpp/smpp/pdu/pdufield"
+	"github.com/florentchauveau/go-smpp/smpp/pdu/pdutext"
+	"github.com/florentchauveau/go-smpp/smpp/pdu/pdutlv"
+)
+
+func TestShortMessageJSON(t *testing.T) {
+	dpf := true
+	bearer := pdutlv.BearerSMS
+	test := []*ShortMessage{
+		{
+			Src:          "root",
+			Dst:          "+33612345678",
+			Text:         pdutext.UCS2("Привет €"),
+			Validity:     90 * time.Minute,
+			ValidityMode: ValidityRelative,
+			Register:     pdufield.FinalDeliveryReceipt,
+			TLVFields: pdutlv.Fields{
+				pdutlv.TagReceiptedMessageID:   pdutlv.CString("foobar"),
+				pdutlv.TagUserMessageReference: []byte{0x00, 0x2a},
+			},
+			SourceAddrTON:  pdufield.TONAlphanumeric,
+			DestAddrTON:    pdufield.TONInternational,
+			DestAddrNPI:    pdufield.NPIISDN,
+			MessageClass:   pdutext.Class1,
+			SetDPF:         &dpf,
+			DestBearerType: &bearer,
+		},
+		{
+			Src:         "root",
+			Dst:         "foobar",
+			Text:        pdutext.GSM7("Hello [world] €"),
+			ShiftTables: pdutext.ShiftTables{Single: pdutext.Turkish},
+			UDH:         []pdufield.UDHIE{pdufield.NewIEApplicationPort16Bit(2948, 9200)},
+			MWI:         &pdutext.MWI{Type: pdutext.MWIVoicemail, Active: true},
+		},
+	}
+	for _, sm := range test {
+		b, err := json.Marshal(sm)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var have ShortMessage
+		if err := json.Unmarshal(b, &have); err != nil {
+			t.Fatal(err)
+		}
+		if reflect.TypeOf(have.Text) != reflect.TypeOf(sm.Text) {
+			t.Fatalf("unexpected text codec: want %T, have %T", sm.Text, have.Text)
+		}
+		if !reflect.DeepEqual(have.UDH, sm.UDH) || have.ShiftTables != sm.ShiftTables {
+			t.Fatalf("unexpected concatenation settings: want %v %v, have %v %v",
+				sm.UDH, sm.ShiftTables, have.UDH, have.ShiftTables)
+		}
+		want := serializeSubmit(t, sm)
+		if got := serializeSubmit(t, &have); !bytes.Equal(want, got) {
+			t.Fatalf("unexpected submit_sm after unmarshal:\nwant %x\nhave %x", want, got)
+		}
+	}
+}
+
+func TestShortMessageJSONUnknownCodec(t *testing.T) {
+	var sm ShortMessage
+	err := json.Unmarshal([]byte(`{"Text":{"Codec":"foo","Data":""}}`), &sm)
+	if err == nil {
+		t.Fatal("unexpected unmarshal of unknown codec")
+	}
+}
+
+// serializeSubmit returns the submit_sm of sm, with a zero sequence
+// number.
+func serializeSubmit(t *testing.T, sm *ShortMessage) []byte {
+	t.Helper()
+	pdus, err := sm.BuildPDUs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b bytes.Buffer
+	for _, p := range pdus {
+		p.Header().Seq = 0
+		if err := p.SerializeTo(&b); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return b.Bytes()
+}
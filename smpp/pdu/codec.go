@@ -8,13 +8,16 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"slices"
+	"sync"
 	"sync/atomic"
 
 	"github.com/florentchauveau/go-smpp/smpp/pdu/pdufield"
 	"github.com/florentchauveau/go-smpp/smpp/pdu/pdutlv"
 )
 
-var nextSeq uint32
+// seq is the sequence of new PDUs.
+var seq Sequence
 
 // codec is the base type of all PDUs.
 // It implements the PDU interface and provides a generic encoder.
@@ -34,7 +37,46 @@ func (pdu *codec) init() {
 	pdu.f = make(pdufield.Map)
 	pdu.t = make(pdutlv.Map)
 	if pdu.h.Seq == 0 { // If Seq not set
-		pdu.h.Seq = atomic.AddUint32(&nextSeq, 1)
+		pdu.h.Seq = NextSeq()
+	}
+}
+
+// NextSeq returns the next sequence number, as assigned to new PDUs.
+func NextSeq() uint32 {
+	return seq.Next()
+}
+
+// MaxSeq is the highest sequence number, see SMPP 3.4 spec 3.2.
+const MaxSeq = 0x7FFFFFFF
+
+// Sequence generates sequence numbers from 1 to MaxSeq, wrapping back
+// to 1 after MaxSeq. The zero value starts at 1. It is safe for
+// concurrent use, and each call to Next returns the next number.
+type Sequence struct {
+	last atomic.Uint32
+}
+
+// NewSequence returns a Sequence starting at start, or 1 if start is 0
+// or over MaxSeq.
+func NewSequence(start uint32) *Sequence {
+	s := &Sequence{}
+	if start > 0 && start <= MaxSeq {
+		s.last.Store(start - 1)
+	}
+	return s
+}
+
+// Next returns the next sequence number.
+func (s *Sequence) Next() uint32 {
+	for {
+		last := s.last.Load()
+		next := last + 1
+		if next > MaxSeq {
+			next = 1
+		}
+		if s.last.CompareAndSwap(last, next) {
+			return next
+		}
 	}
 }
 
@@ -51,10 +93,10 @@ func (pdu *codec) Header() *Header {
 // Len implements the PDU interface.
 func (pdu *codec) Len() int {
 	l := HeaderLen
-	for _, f := range pdu.f {
+	for _, f := range pdu.fields() {
 		l += f.Len()
 	}
-	for _, t := range pdu.t {
+	for _, t := range pdu.tlvs() {
 		l += t.Len()
 	}
 	return l
@@ -75,33 +117,97 @@ func (pdu *codec) TLVFields() pdutlv.Map {
 	return pdu.t
 }
 
-// SerializeTo implements the PDU interface.
+// bufPool holds the buffers PDUs are serialized into.
+var bufPool = sync.Pool{New: func() any { return new(bytes.Buffer) }}
+
+// SerializeTo implements the PDU interface. The PDU is written to w in
+// a single call. If the message_payload TLV is set, short_message is
+// written empty with a zero sm_length, and the UDH, if any, is written
+// at the start of message_payload instead. The fields and TLVs of the
+// PDU are not modified.
 func (pdu *codec) SerializeTo(w io.Writer) error {
-	var b bytes.Buffer
-	for _, k := range pdu.FieldList() {
-		f, ok := pdu.f[k]
-		if !ok {
-			_ = pdu.f.Set(k, nil)
-			f = pdu.f[k]
-		}
-		if err := f.SerializeTo(&b); err != nil {
+	b := bufPool.Get().(*bytes.Buffer)
+	defer func() {
+		b.Reset()
+		bufPool.Put(b)
+	}()
+	var hdr [HeaderLen]byte
+	b.Write(hdr[:]) // set once the length is known
+	for _, f := range pdu.fields() {
+		if err := f.SerializeTo(b); err != nil {
 			return err
 		}
 	}
-	for _, f := range pdu.TLVFields() {
-		if err := f.SerializeTo(&b); err != nil {
+	for _, t := range pdu.tlvs() {
+		if err := t.SerializeTo(b); err != nil {
 			return err
 		}
 	}
-	pdu.h.Len = uint32(pdu.Len())
-	err := pdu.h.SerializeTo(w)
-	if err != nil {
-		return err
-	}
-	_, err = io.Copy(w, &b)
+	pdu.h.Len = uint32(b.Len())
+	pdu.h.put(b.Bytes()[:HeaderLen])
+	_, err := w.Write(b.Bytes())
 	return err
 }
 
+// hasPayload reports whether the message is carried by the
+// message_payload TLV, with an empty short_message, see SMPP 3.4 spec
+// 5.3.2.32.
+func (pdu *codec) hasPayload() bool {
+	_, ok := pdu.t[pdutlv.TagMessagePayload]
+	return ok && slices.Contains(pdu.l, pdufield.ShortMessage)
+}
+
+// fields returns the fields of the PDU in the order they are written,
+// with default values for the missing ones. The fields of the PDU are
+// left untouched, e.g. udh_length is computed from the UDH.
+func (pdu *codec) fields() []pdufield.Body {
+	udh := pdu.UDH()
+	payload := pdu.hasPayload()
+	fields := make([]pdufield.Body, len(pdu.l))
+	for i, k := range pdu.l {
+		f := pdu.f[k]
+		switch {
+		case payload && k == pdufield.SMLength:
+			f = &pdufield.Fixed{Data: 0}
+		case payload && k == pdufield.ShortMessage:
+			f = pdufield.New(k, nil)
+		case payload && (k == pdufield.UDHLength || k == pdufield.GSMUserData):
+			f = &pdufield.Null{} // written in message_payload
+		case k == pdufield.UDHLength && udh != nil:
+			f = &pdufield.Fixed{Data: uint8(udh.Len())}
+		case f == nil:
+			f = pdufield.New(k, nil)
+		}
+		fields[i] = f
+	}
+	return fields
+}
+
+// tlvs returns the TLVs of the PDU in tag order, so that the output is
+// deterministic. The UDH is prepended to message_payload, along with
+// its length octet, when the message is carried by message_payload.
+func (pdu *codec) tlvs() []pdutlv.Body {
+	tags := make([]pdutlv.Tag, 0, len(pdu.t))
+	for tag := range pdu.t {
+		tags = append(tags, tag)
+	}
+	slices.Sort(tags)
+	udh := pdu.UDH()
+	payload := udh != nil && pdu.hasPayload()
+	tlvs := make([]pdutlv.Body, len(tags))
+	for i, tag := range tags {
+		t := pdu.t[tag]
+		if payload && tag == pdutlv.TagMessagePayload {
+			b := make([]byte, 0, 1+udh.Len()+len(t.Bytes()))
+			b = append(b, uint8(udh.Len()))
+			b = append(b, udh.Bytes()...)
+			t = pdutlv.NewTLV(tag, append(b, t.Bytes()...))
+		}
+		tlvs[i] = t
+	}
+	return tlvs
+}
+
 // UDH implements the PDU interface.
 func (pdu *codec) UDH() *pdufield.UDH {
 	udh, ok := pdu.f[pdufield.GSMUserData].(*pdufield.UDH)
@@ -118,14 +224,31 @@ type decoder interface {
 	setup(f pdufield.Map, t pdutlv.Map)
 }
 
-func decodeFields(pdu decoder, b []byte) (Body, error) {
+func decodeFields(pdu decoder, b []byte, opts pdufield.DecodeOptions) (Body, error) {
 	l := pdu.FieldList()
 	r := bytes.NewBuffer(b)
-	f, err := l.Decode(r)
+	f, err := l.DecodeWithOptions(r, opts)
 	if err != nil {
 		return nil, err
 	}
+	trailing := r.Len()
 	t, err := pdutlv.DecodeTLV(r)
+	if (err != nil || r.Len() > 0) && pdu.Header().Status != 0 {
+		// Error responses may omit the mandatory fields and only
+		// carry TLVs, e.g. additional_status_info_text.
+		if t, err := pdutlv.DecodeTLV(bytes.NewBuffer(b)); err == nil {
+			pdu.setup(make(pdufield.Map), t)
+			return pdu, nil
+		}
+	}
+	if err != nil || r.Len() > 0 {
+		// Octets left after the short message that are not valid
+		// TLVs mean the declared sm_length is too small.
+		if sm, ok := f[pdufield.SMLength].(*pdufield.Fixed); ok {
+			n := int(sm.Data)
+			return nil, &pdufield.SMLengthError{SMLength: n, Have: n + trailing}
+		}
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -137,6 +260,36 @@ func decodeFields(pdu decoder, b []byte) (Body, error) {
 // with header and all fields decoded. The returned PDU can be modified
 // and re-serialized to its binary form.
 func Decode(r io.Reader) (Body, error) {
+	return DecodeWithOptions(r, pdufield.DecodeOptions{})
+}
+
+// DecodeBytes decodes the PDU in the complete frame b, header included,
+// e.g. as captured by a proxy. It returns an error wrapping
+// io.ErrUnexpectedEOF if b is shorter than the command_length of its
+// header, and an error if b has trailing octets.
+func DecodeBytes(b []byte) (Body, error) {
+	if len(b) < HeaderLen {
+		return nil, fmt.Errorf("PDU truncated: %d octets, header needs %d: %w",
+			len(b), HeaderLen, io.ErrUnexpectedEOF)
+	}
+	hdr, err := DecodeHeader(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	switch l := int(hdr.Len); {
+	case len(b) < l:
+		return nil, fmt.Errorf("PDU truncated: %d octets, command_length %d: %w",
+			len(b), l, io.ErrUnexpectedEOF)
+	case len(b) > l:
+		return nil, fmt.Errorf("PDU has %d trailing octets after command_length %d",
+			len(b)-l, l)
+	}
+	return Decode(bytes.NewReader(b))
+}
+
+// DecodeWithOptions is like Decode, and decodes the PDU fields with
+// the given options.
+func DecodeWithOptions(r io.Reader, opts pdufield.DecodeOptions) (Body, error) {
 	hdr, err := DecodeHeader(r)
 	if err != nil {
 		return nil, err
@@ -148,51 +301,51 @@ func Decode(r io.Reader) (Body, error) {
 	}
 	switch hdr.ID {
 	case AlertNotificationID:
-		// TODO(fiorix): Implement AlertNotification.
+		return decodeFields(newAlertNotification(hdr), b, opts)
 	case BindReceiverID, BindTransceiverID, BindTransmitterID:
-		return decodeFields(newBind(hdr), b)
+		return decodeFields(newBind(hdr), b, opts)
 	case BindReceiverRespID, BindTransceiverRespID, BindTransmitterRespID:
-		return decodeFields(newBindResp(hdr), b)
+		return decodeFields(newBindResp(hdr), b, opts)
 	case CancelSMID:
 		// TODO(fiorix): Implement CancelSM.
 	case CancelSMRespID:
 		// TODO(fiorix): Implement CancelSMResp.
 	case DataSMID:
-		// TODO(fiorix): Implement DataSM.
+		return decodeFields(newDataSM(hdr), b, opts)
 	case DataSMRespID:
-		// TODO(fiorix): Implement DataSMResp.
+		return decodeFields(newDataSMResp(hdr), b, opts)
 	case DeliverSMID:
-		return decodeFields(newDeliverSM(hdr), b)
+		return decodeFields(newDeliverSM(hdr), b, opts)
 	case DeliverSMRespID:
-		return decodeFields(newDeliverSMResp(hdr), b)
+		return decodeFields(newDeliverSMResp(hdr), b, opts)
 	case EnquireLinkID:
-		return decodeFields(newEnquireLink(hdr), b)
+		return decodeFields(newEnquireLink(hdr), b, opts)
 	case EnquireLinkRespID:
-		return decodeFields(newEnquireLinkResp(hdr), b)
+		return decodeFields(newEnquireLinkResp(hdr), b, opts)
 	case GenericNACKID:
-		return decodeFields(newGenericNACK(hdr), b)
+		return decodeFields(newGenericNACK(hdr), b, opts)
 	case OutbindID:
 		// TODO(fiorix): Implement Outbind.
 	case QuerySMID:
-		return decodeFields(newQuerySM(hdr), b)
+		return decodeFields(newQuerySM(hdr), b, opts)
 	case QuerySMRespID:
-		return decodeFields(newQuerySMResp(hdr), b)
+		return decodeFields(newQuerySMResp(hdr), b, opts)
 	case ReplaceSMID:
 		// TODO(fiorix): Implement ReplaceSM.
 	case ReplaceSMRespID:
 		// TODO(fiorix): Implement ReplaceSMResp.
 	case SubmitMultiID:
-		return decodeFields(newSubmitMulti(hdr), b)
+		return decodeFields(newSubmitMulti(hdr), b, opts)
 	case SubmitMultiRespID:
-		return decodeFields(newSubmitMultiResp(hdr), b)
+		return decodeFields(newSubmitMultiResp(hdr), b, opts)
 	case SubmitSMID:
-		return decodeFields(newSubmitSM(hdr), b)
+		return decodeFields(newSubmitSM(hdr), b, opts)
 	case SubmitSMRespID:
-		return decodeFields(newSubmitSMResp(hdr), b)
+		return decodeFields(newSubmitSMResp(hdr), b, opts)
 	case UnbindID:
-		return decodeFields(newUnbind(hdr), b)
+		return decodeFields(newUnbind(hdr), b, opts)
 	case UnbindRespID:
-		return decodeFields(newUnbindResp(hdr), b)
+		return decodeFields(newUnbindResp(hdr), b, opts)
 	default:
 		return nil, fmt.Errorf("unknown PDU type: %#x", hdr.ID)
 	}
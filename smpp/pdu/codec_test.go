@@ -2,9 +2,14 @@ package pdu
 
 import (
 	"bytes"
+	"errors"
+	"io"
+	"sync"
 	"testing"
 
 	"github.com/florentchauveau/go-smpp/smpp/pdu/pdufield"
+	"github.com/florentchauveau/go-smpp/smpp/pdu/pdutext"
+	"github.com/florentchauveau/go-smpp/smpp/pdu/pdutlv"
 )
 
 func TestDecodeWithUDH(t *testing.T) {
@@ -119,3 +124,292 @@ func TestDecode(t *testing.T) {
 		t.Fatalf("Decode() unexpected UDH field")
 	}
 }
+
+func TestDecodeBytes(t *testing.T) {
+	p := NewSubmitSM(nil)
+	_ = p.Fields().Set(pdufield.SourceAddr, "root")
+	_ = p.Fields().Set(pdufield.ShortMessage, pdutext.Raw("Lorem ipsum"))
+	var b bytes.Buffer
+	if err := p.SerializeTo(&b); err != nil {
+		t.Fatal(err)
+	}
+	frame := b.Bytes()
+	have, err := DecodeBytes(frame)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have.Header().ID != SubmitSMID || have.Header().Seq != p.Header().Seq {
+		t.Fatalf("unexpected header: want %+v, have %+v", p.Header(), have.Header())
+	}
+	if sm := have.Fields()[pdufield.ShortMessage].String(); sm != "Lorem ipsum" {
+		t.Fatalf("unexpected short message: %q", sm)
+	}
+	for _, n := range []int{0, 10, HeaderLen, len(frame) - 1} {
+		_, err := DecodeBytes(frame[:n])
+		if !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Fatalf("unexpected error for %d octets: want %v, have %v", n, io.ErrUnexpectedEOF, err)
+		}
+	}
+	_, err = DecodeBytes(append(frame, 0x00))
+	if err == nil || errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("unexpected error with trailing octet: %v", err)
+	}
+}
+
+func TestDecodeErrorRespTLVOnly(t *testing.T) {
+	text := "quota exceeded\x00"
+	b := []byte{
+		0x00, 0x00, 0x00, byte(HeaderLen + 4 + len(text)),
+		0x80, 0x00, 0x00, 0x04, // submit_sm_resp
+		0x00, 0x00, 0x00, 0x08, // ESME_RSYSERR
+		0x00, 0x00, 0x00, 0x01,
+		0x00, 0x1d, 0x00, byte(len(text)),
+	}
+	b = append(b, text...)
+	p, err := Decode(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Header().Status != StatusSysErr {
+		t.Fatalf("unexpected status: want %v, have %v", StatusSysErr, p.Header().Status)
+	}
+	have, ok := p.TLVFields().String(pdutlv.TagAdditionalStatusInfoText)
+	if !ok || have != "quota exceeded" {
+		t.Fatalf("unexpected additional_status_info_text: want %q, have %q", "quota exceeded", have)
+	}
+}
+
+func TestSerializeTLVOrder(t *testing.T) {
+	p := NewSubmitSM(pdutlv.Fields{
+		pdutlv.TagPrivacyIndicator: pdutlv.PrivacySecret,
+		pdutlv.TagPayloadType:      pdutlv.PayloadWCMP,
+	})
+	_ = p.Fields().Set(pdufield.ShortMessage, []byte("hello"))
+	var b bytes.Buffer
+	if err := p.SerializeTo(&b); err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{0x00, 0x19, 0x00, 0x01, 0x01, 0x02, 0x01, 0x00, 0x01, 0x03}
+	if have := b.Bytes()[b.Len()-len(want):]; !bytes.Equal(want, have) {
+		t.Fatalf("unexpected TLV bytes: want %x, have %x", want, have)
+	}
+	d, err := Decode(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := d.TLVFields().PrivacyIndicator(); !ok || v != pdutlv.PrivacySecret {
+		t.Fatalf("unexpected privacy_indicator: want %d, have %d", pdutlv.PrivacySecret, v)
+	}
+	if v, ok := d.TLVFields().PayloadType(); !ok || v != pdutlv.PayloadWCMP {
+		t.Fatalf("unexpected payload_type: want %d, have %d", pdutlv.PayloadWCMP, v)
+	}
+}
+
+func TestSerializeUDHLength(t *testing.T) {
+	p := NewSubmitSM(nil)
+	f := p.Fields()
+	_ = f.Set(pdufield.ESMClass, pdufield.ESMClassUDHIndicator)
+	_ = f.Set(pdufield.ShortMessage, []byte("hello"))
+	udh := pdufield.NewUDH(pdufield.NewIEConcatenatedShortMessage(1, 2, 1))
+	_ = f.Set(pdufield.GSMUserData, &udh)
+	fields := len(f)
+	for _, want := range []int{1, 2} {
+		_ = f.Set(pdufield.SMLength, uint8(5+udh.Len()+1))
+		var b bytes.Buffer
+		if err := p.SerializeTo(&b); err != nil {
+			t.Fatal(err)
+		}
+		if len(f) != fields {
+			t.Fatalf("unexpected fields after serialize: want %d, have %d", fields, len(f))
+		}
+		if b.Len() != p.Len() {
+			t.Fatalf("unexpected length: want %d, have %d", b.Len(), p.Len())
+		}
+		d, err := Decode(&b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if have := d.UDH(); have == nil || len(have.IE) != want {
+			t.Fatalf("unexpected UDH: want %d IEs, have %v", want, have)
+		}
+		udh.Append(pdufield.NewIEApplicationPort16Bit(2948, 9200))
+	}
+}
+
+func TestMessagePayloadLen(t *testing.T) {
+	payload := bytes.Repeat([]byte("0123456789"), 100)
+	p := NewSubmitSM(pdutlv.Fields{pdutlv.TagMessagePayload: payload})
+	var b bytes.Buffer
+	if err := p.SerializeTo(&b); err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{0x04, 0x24, 0x03, 0xe8} // tag, 1000 octets length
+	if have := b.Bytes()[b.Len()-len(payload)-4 : b.Len()-len(payload)]; !bytes.Equal(want, have) {
+		t.Fatalf("unexpected TLV header: want %x, have %x", want, have)
+	}
+	d, err := Decode(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := d.TLVFields().Bytes(pdutlv.TagMessagePayload); !ok || !bytes.Equal(v, payload) {
+		t.Fatalf("unexpected message_payload: want %d octets, have %d", len(payload), len(v))
+	}
+}
+
+func TestMessagePayloadEmptyShortMessage(t *testing.T) {
+	p := NewSubmitSM(pdutlv.Fields{pdutlv.TagMessagePayload: []byte("Lorem ipsum")})
+	f := p.Fields()
+	_ = f.Set(pdufield.ESMClass, pdufield.ESMClassUDHIndicator)
+	_ = f.Set(pdufield.ShortMessage, "Lorem ipsum")
+	udh := pdufield.NewUDH(pdufield.NewIEConcatenatedShortMessage(1, 2, 1))
+	_ = f.Set(pdufield.GSMUserData, &udh)
+	var b bytes.Buffer
+	if err := p.SerializeTo(&b); err != nil {
+		t.Fatal(err)
+	}
+	if int(p.Header().Len) != p.Len() {
+		t.Fatalf("unexpected command_length: want %d, have %d", p.Len(), p.Header().Len)
+	}
+	d, err := Decode(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := d.Fields().Uint8(pdufield.SMLength); !ok || v != 0 {
+		t.Fatalf("unexpected sm_length: want 0, have %d", v)
+	}
+	if v := d.Fields()[pdufield.ShortMessage].Bytes(); len(v) != 0 {
+		t.Fatalf("unexpected short_message: want empty, have %q", v)
+	}
+	want := append([]byte{0x05, 0x00, 0x03, 0x01, 0x02, 0x01}, "Lorem ipsum"...)
+	if v, ok := d.TLVFields().Bytes(pdutlv.TagMessagePayload); !ok || !bytes.Equal(v, want) {
+		t.Fatalf("unexpected message_payload: want %q, have %q", want, v)
+	}
+	if v, _ := d.Fields().Uint8(pdufield.ESMClass); !pdufield.ESMClassFlags(v).HasUDH() {
+		t.Fatalf("unexpected esm_class without UDHI: %#02x", v)
+	}
+	if v := f[pdufield.ShortMessage].String(); v != "Lorem ipsum" || p.UDH() != &udh {
+		t.Fatalf("unexpected PDU fields after serialize: %q %v", v, p.UDH())
+	}
+}
+
+func TestVendorTLVRoundTrip(t *testing.T) {
+	const tag = pdutlv.Tag(0x1501)
+	if !tag.IsVendor() {
+		t.Fatalf("tag %s not in the vendor range", tag.Hex())
+	}
+	p := NewDeliverSM()
+	_ = p.Fields().Set(pdufield.ShortMessage, []byte("hello"))
+	_ = p.TLVFields().Set(tag, []byte{0xde, 0xad, 0xbe, 0xef})
+	_ = p.TLVFields().Set(pdutlv.TagReceiptedMessageID, pdutlv.CString("abc"))
+	var b bytes.Buffer
+	if err := p.SerializeTo(&b); err != nil {
+		t.Fatal(err)
+	}
+	want := append([]byte(nil), b.Bytes()...)
+	d, err := Decode(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := d.TLVFields().Bytes(tag); !ok || !bytes.Equal(v, []byte{0xde, 0xad, 0xbe, 0xef}) {
+		t.Fatalf("unexpected vendor TLV: have % x (%t)", v, ok)
+	}
+	b.Reset()
+	if err := d.SerializeTo(&b); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(want, b.Bytes()) {
+		t.Fatalf("unexpected round-trip:\nwant % x\nhave % x", want, b.Bytes())
+	}
+}
+
+func TestDecodeSMLengthMismatch(t *testing.T) {
+	tx := []byte{
+		0x0, 0x0, 0x0, 0x3f, 0x0, 0x0, 0x0, 0x5,
+		0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x4, 0x92,
+		0x0, 0x1, 0x1, 0x33, 0x33, 0x36, 0x33, 0x39,
+		0x39, 0x38, 0x37, 0x35, 0x37, 0x35, 0x0, 0x1,
+		0x1, 0x33, 0x33, 0x36, 0x33, 0x39, 0x39, 0x38,
+		0x31, 0x39, 0x39, 0x39, 0x0, 0x0, 0x0, 0x0,
+		0x0, 0x0, 0x0, 0x0, 0x0, 0x0, 0x8, 0x42,
+		0x6f, 0x6e, 0x6a, 0x6f, 0x75, 0x72, 0x73}
+	const smLengthOffset = 54
+
+	for _, smLength := range []byte{0x0a, 0x06} {
+		b := bytes.Clone(tx)
+		b[smLengthOffset] = smLength
+		_, err := Decode(bytes.NewReader(b))
+		var e *pdufield.SMLengthError
+		if !errors.As(err, &e) {
+			t.Fatalf("unexpected error for sm_length %d: want SMLengthError, have %v", smLength, err)
+		}
+		if e.SMLength != int(smLength) || e.Have != 8 {
+			t.Fatalf("unexpected error: want sm_length %d and 8 octets, have %#v", smLength, e)
+		}
+	}
+}
+
+func BenchmarkSerializeTo(b *testing.B) {
+	p := NewSubmitSM(pdutlv.Fields{pdutlv.TagReceiptedMessageID: pdutlv.CString("abc")})
+	f := p.Fields()
+	_ = f.Set(pdufield.SourceAddr, "root")
+	_ = f.Set(pdufield.DestinationAddr, "foobar")
+	_ = f.Set(pdufield.ShortMessage, []byte("Lorem ipsum dolor sit amet"))
+	b.ReportAllocs()
+	for b.Loop() {
+		if err := p.SerializeTo(io.Discard); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestSequenceWrap(t *testing.T) {
+	s := NewSequence(MaxSeq - 1)
+	for _, want := range []uint32{MaxSeq - 1, MaxSeq, 1, 2} {
+		if have := s.Next(); have != want {
+			t.Fatalf("unexpected seq: want %d, have %d", want, have)
+		}
+	}
+	if have := NewSequence(0).Next(); have != 1 {
+		t.Fatalf("unexpected first seq: want 1, have %d", have)
+	}
+	if have := NewSequence(MaxSeq + 1).Next(); have != 1 {
+		t.Fatalf("unexpected first seq over MaxSeq: want 1, have %d", have)
+	}
+}
+
+func TestSequenceConcurrent(t *testing.T) {
+	const workers, n = 8, 1000
+	s := NewSequence(MaxSeq - workers*n/2)
+	var (
+		mu   sync.Mutex
+		seen = make(map[uint32]bool)
+		wg   sync.WaitGroup
+	)
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range n {
+				v := s.Next()
+				mu.Lock()
+				seen[v] = true
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if len(seen) != workers*n {
+		t.Fatalf("unexpected unique seqs: want %d, have %d", workers*n, len(seen))
+	}
+	// No gaps: every number from the start to MaxSeq, then 1 onwards.
+	for v := uint32(MaxSeq - workers*n/2); v <= MaxSeq; v++ {
+		if !seen[v] {
+			t.Fatalf("missing seq %d", v)
+		}
+	}
+	for v := uint32(1); v < workers*n/2; v++ {
+		if !seen[v] {
+			t.Fatalf("missing seq %d", v)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+// Copyright 2015 go-smpp authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package pdu
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/florentchauveau/go-smpp/smpp/pdu/pdufield"
+	"github.com/florentchauveau/go-smpp/smpp/pdu/pdutlv"
+)
+
+// Dump returns p rendered for debugging, one line per item: the header,
+// each mandatory field present in order, then each TLV in tag order,
+// with their names and values. Printable values are quoted and followed
+// by their octets in hex, and the IEs of a UDH are listed each on their
+// own line.
+func Dump(p Body) string {
+	var b strings.Builder
+	h := p.Header()
+	fmt.Fprintf(&b, "command_id: %s (0x%08x)\n", h.ID, uint32(h.ID))
+	fmt.Fprintf(&b, "command_status: %s (0x%08x)\n", h.Status.Name(), uint32(h.Status))
+	fmt.Fprintf(&b, "sequence_number: %d\n", h.Seq)
+	f := p.Fields()
+	for _, k := range p.FieldList() {
+		v := f[k]
+		if v == nil {
+			continue
+		}
+		switch v := v.(type) {
+		case *pdufield.Fixed:
+			fmt.Fprintf(&b, "%s: %d (0x%02x)\n", k, v.Data, v.Data)
+		case *pdufield.UDH:
+			fmt.Fprintf(&b, "%s: %x\n", k, v.Bytes())
+			for _, ie := range v.IE {
+				fmt.Fprintf(&b, "  ie 0x%02x: %x\n", ie.IEI, ie.IEData)
+			}
+		default:
+			fmt.Fprintf(&b, "%s: %s\n", k, dumpValue(v.Bytes()))
+		}
+	}
+	tlv := p.TLVFields()
+	tags := make([]pdutlv.Tag, 0, len(tlv))
+	for tag := range tlv {
+		tags = append(tags, tag)
+	}
+	slices.Sort(tags)
+	for _, tag := range tags {
+		fmt.Fprintf(&b, "tlv %s (0x%04x): %s\n", tag.Name(), uint16(tag), dumpValue(tlv[tag].Bytes()))
+	}
+	return b.String()
+}
+
+// dumpValue returns b quoted and in hex if it is printable text,
+// quoted without its null terminator, or else in hex only.
+func dumpValue(b []byte) string {
+	text := strings.TrimSuffix(string(b), "\x00")
+	if text == "" {
+		return `""`
+	}
+	if !utf8.ValidString(text) || strings.ContainsFunc(text, func(r rune) bool {
+		return !strconv.IsPrint(r)
+	}) {
+		return fmt.Sprintf("%x", b)
+	}
+	return fmt.Sprintf("%q (%x)", text, b)
+}
@@ -0,0 +1,54 @@
+// Copyright 2015 go-smpp authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package pdu
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/florentchauveau/go-smpp/smpp/pdu/pdufield"
+	"github.com/florentchauveau/go-smpp/smpp/pdu/pdutext"
+	"github.com/florentchauveau/go-smpp/smpp/pdu/pdutlv"
+)
+
+func TestDump(t *testing.T) {
+	p := NewSubmitSM(pdutlv.Fields{pdutlv.TagUserMessageReference: []byte{0x00, 0x2a}})
+	p.Header().Seq = 42
+	f := p.Fields()
+	_ = f.Set(pdufield.SourceAddr, "root")
+	_ = f.Set(pdufield.DestinationAddr, "foobar")
+	_ = f.Set(pdufield.ESMClass, pdufield.ESMClassUDHIndicator)
+	_ = f.Set(pdufield.ShortMessage, pdutext.Raw("hello"))
+	udh := pdufield.NewUDHConcatenatedShortMessage(0x2a, 2, 1)
+	_ = f.Set(pdufield.UDHLength, uint8(udh.Len()))
+	_ = f.Set(pdufield.GSMUserData, &udh)
+	_ = f.Set(pdufield.SMLength, uint8(f[pdufield.ShortMessage].Len()+udh.Len()+1))
+	var b bytes.Buffer
+	if err := p.SerializeTo(&b); err != nil {
+		t.Fatal(err)
+	}
+	d, err := Decode(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dump := Dump(d)
+	for _, want := range []string{
+		"command_id: SubmitSM (0x00000004)\n",
+		"command_status: ESME_ROK (0x00000000)\n",
+		"sequence_number: 42\n",
+		"source_addr: \"root\" (726f6f7400)\n",
+		"destination_addr: \"foobar\"",
+		"esm_class: 64 (0x40)\n",
+		"gsm_sms_ud.udh: 00032a0201\n",
+		"  ie 0x00: 2a0201\n",
+		"short_message: \"hello\" (68656c6c6f)\n",
+		"tlv user_message_reference (0x0204): 002a\n",
+	} {
+		if !strings.Contains(dump, want) {
+			t.Fatalf("dump does not contain %q:\n%s", want, dump)
+		}
+	}
+}
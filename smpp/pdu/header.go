@@ -53,6 +53,12 @@ func (id ID) String() string {
 	return idString[id]
 }
 
+// IsResponse returns true if the ID is of a response PDU, including
+// GenericNACK.
+func (id ID) IsResponse() bool {
+	return id&0x80000000 != 0
+}
+
 // Group returns group of a given ID.
 // Example: SubmitSM, SubmitSMResp should return the same group: 0x04.
 func (id ID) Group() uint16 {
@@ -103,12 +109,129 @@ func DecodeHeader(r io.Reader) (*Header, error) {
 // SerializeTo serializes the Header to its binary form to the given writer.
 func (h *Header) SerializeTo(w io.Writer) error {
 	b := make([]byte, HeaderLen)
+	h.put(b)
+	_, err := w.Write(b)
+	return err
+}
+
+// put writes the binary form of the Header to b, of HeaderLen octets.
+func (h *Header) put(b []byte) {
 	binary.BigEndian.PutUint32(b[0:4], h.Len)
 	binary.BigEndian.PutUint32(b[4:8], uint32(h.ID))
 	binary.BigEndian.PutUint32(b[8:12], uint32(h.Status))
 	binary.BigEndian.PutUint32(b[12:16], h.Seq)
-	_, err := w.Write(b)
-	return err
+}
+
+// Command status values, see SMPP 3.4 section 5.1.3.
+const (
+	StatusOK              Status = 0x00000000 // ESME_ROK
+	StatusInvMsgLen       Status = 0x00000001 // ESME_RINVMSGLEN
+	StatusInvCmdLen       Status = 0x00000002 // ESME_RINVCMDLEN
+	StatusInvCmdID        Status = 0x00000003 // ESME_RINVCMDID
+	StatusInvBndSts       Status = 0x00000004 // ESME_RINVBNDSTS
+	StatusAlyBnd          Status = 0x00000005 // ESME_RALYBND
+	StatusInvPrtFlg       Status = 0x00000006 // ESME_RINVPRTFLG
+	StatusInvRegDlvFlg    Status = 0x00000007 // ESME_RINVREGDLVFLG
+	StatusSysErr          Status = 0x00000008 // ESME_RSYSERR
+	StatusInvSrcAdr       Status = 0x0000000a // ESME_RINVSRCADR
+	StatusInvDstAdr       Status = 0x0000000b // ESME_RINVDSTADR
+	StatusInvMsgID        Status = 0x0000000c // ESME_RINVMSGID
+	StatusBindFail        Status = 0x0000000d // ESME_RBINDFAIL
+	StatusInvPaswd        Status = 0x0000000e // ESME_RINVPASWD
+	StatusInvSysID        Status = 0x0000000f // ESME_RINVSYSID
+	StatusCancelFail      Status = 0x00000011 // ESME_RCANCELFAIL
+	StatusReplaceFail     Status = 0x00000013 // ESME_RREPLACEFAIL
+	StatusMsgQFul         Status = 0x00000014 // ESME_RMSGQFUL
+	StatusInvSerTyp       Status = 0x00000015 // ESME_RINVSERTYP
+	StatusInvNumDests     Status = 0x00000033 // ESME_RINVNUMDESTS
+	StatusInvDLName       Status = 0x00000034 // ESME_RINVDLNAME
+	StatusInvDestFlag     Status = 0x00000040 // ESME_RINVDESTFLAG
+	StatusInvSubRep       Status = 0x00000042 // ESME_RINVSUBREP
+	StatusInvEsmClass     Status = 0x00000043 // ESME_RINVESMCLASS
+	StatusCntSubDL        Status = 0x00000044 // ESME_RCNTSUBDL
+	StatusSubmitFail      Status = 0x00000045 // ESME_RSUBMITFAIL
+	StatusInvSrcTON       Status = 0x00000048 // ESME_RINVSRCTON
+	StatusInvSrcNPI       Status = 0x00000049 // ESME_RINVSRCNPI
+	StatusInvDstTON       Status = 0x00000050 // ESME_RINVDSTTON
+	StatusInvDstNPI       Status = 0x00000051 // ESME_RINVDSTNPI
+	StatusInvSysTyp       Status = 0x00000053 // ESME_RINVSYSTYP
+	StatusInvRepFlag      Status = 0x00000054 // ESME_RINVREPFLAG
+	StatusInvNumMsgs      Status = 0x00000055 // ESME_RINVNUMMSGS
+	StatusThrottled       Status = 0x00000058 // ESME_RTHROTTLED
+	StatusInvSched        Status = 0x00000061 // ESME_RINVSCHED
+	StatusInvExpiry       Status = 0x00000062 // ESME_RINVEXPIRY
+	StatusInvDftMsgID     Status = 0x00000063 // ESME_RINVDFTMSGID
+	StatusXTAppn          Status = 0x00000064 // ESME_RX_T_APPN
+	StatusXPAppn          Status = 0x00000065 // ESME_RX_P_APPN
+	StatusXRAppn          Status = 0x00000066 // ESME_RX_R_APPN
+	StatusQueryFail       Status = 0x00000067 // ESME_RQUERYFAIL
+	StatusInvOptParStream Status = 0x000000c0 // ESME_RINVOPTPARSTREAM
+	StatusOptParNotAllwd  Status = 0x000000c1 // ESME_ROPTPARNOTALLWD
+	StatusInvParLen       Status = 0x000000c2 // ESME_RINVPARLEN
+	StatusMissingOptParam Status = 0x000000c3 // ESME_RMISSINGOPTPARAM
+	StatusInvOptParamVal  Status = 0x000000c4 // ESME_RINVOPTPARAMVAL
+	StatusDeliveryFailure Status = 0x000000fe // ESME_RDELIVERYFAILURE
+	StatusUnknownErr      Status = 0x000000ff // ESME_RUNKNOWNERR
+)
+
+// Name returns the SMPP name of the status, e.g. ESME_RINVPASWD, or its
+// hexadecimal value if unknown.
+func (s Status) Name() string {
+	if n, ok := statusName[s]; ok {
+		return n
+	}
+	return fmt.Sprintf("0x%08x", uint32(s))
+}
+
+var statusName = map[Status]string{
+	StatusOK:              "ESME_ROK",
+	StatusInvMsgLen:       "ESME_RINVMSGLEN",
+	StatusInvCmdLen:       "ESME_RINVCMDLEN",
+	StatusInvCmdID:        "ESME_RINVCMDID",
+	StatusInvBndSts:       "ESME_RINVBNDSTS",
+	StatusAlyBnd:          "ESME_RALYBND",
+	StatusInvPrtFlg:       "ESME_RINVPRTFLG",
+	StatusInvRegDlvFlg:    "ESME_RINVREGDLVFLG",
+	StatusSysErr:          "ESME_RSYSERR",
+	StatusInvSrcAdr:       "ESME_RINVSRCADR",
+	StatusInvDstAdr:       "ESME_RINVDSTADR",
+	StatusInvMsgID:        "ESME_RINVMSGID",
+	StatusBindFail:        "ESME_RBINDFAIL",
+	StatusInvPaswd:        "ESME_RINVPASWD",
+	StatusInvSysID:        "ESME_RINVSYSID",
+	StatusCancelFail:      "ESME_RCANCELFAIL",
+	StatusReplaceFail:     "ESME_RREPLACEFAIL",
+	StatusMsgQFul:         "ESME_RMSGQFUL",
+	StatusInvSerTyp:       "ESME_RINVSERTYP",
+	StatusInvNumDests:     "ESME_RINVNUMDESTS",
+	StatusInvDLName:       "ESME_RINVDLNAME",
+	StatusInvDestFlag:     "ESME_RINVDESTFLAG",
+	StatusInvSubRep:       "ESME_RINVSUBREP",
+	StatusInvEsmClass:     "ESME_RINVESMCLASS",
+	StatusCntSubDL:        "ESME_RCNTSUBDL",
+	StatusSubmitFail:      "ESME_RSUBMITFAIL",
+	StatusInvSrcTON:       "ESME_RINVSRCTON",
+	StatusInvSrcNPI:       "ESME_RINVSRCNPI",
+	StatusInvDstTON:       "ESME_RINVDSTTON",
+	StatusInvDstNPI:       "ESME_RINVDSTNPI",
+	StatusInvSysTyp:       "ESME_RINVSYSTYP",
+	StatusInvRepFlag:      "ESME_RINVREPFLAG",
+	StatusInvNumMsgs:      "ESME_RINVNUMMSGS",
+	StatusThrottled:       "ESME_RTHROTTLED",
+	StatusInvSched:        "ESME_RINVSCHED",
+	StatusInvExpiry:       "ESME_RINVEXPIRY",
+	StatusInvDftMsgID:     "ESME_RINVDFTMSGID",
+	StatusXTAppn:          "ESME_RX_T_APPN",
+	StatusXPAppn:          "ESME_RX_P_APPN",
+	StatusXRAppn:          "ESME_RX_R_APPN",
+	StatusQueryFail:       "ESME_RQUERYFAIL",
+	StatusInvOptParStream: "ESME_RINVOPTPARSTREAM",
+	StatusOptParNotAllwd:  "ESME_ROPTPARNOTALLWD",
+	StatusInvParLen:       "ESME_RINVPARLEN",
+	StatusMissingOptParam: "ESME_RMISSINGOPTPARAM",
+	StatusInvOptParamVal:  "ESME_RINVOPTPARAMVAL",
+	StatusDeliveryFailure: "ESME_RDELIVERYFAILURE",
+	StatusUnknownErr:      "ESME_RUNKNOWNERR",
 }
 
 // Error implements the Error interface.
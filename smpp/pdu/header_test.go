@@ -134,3 +134,12 @@ func TestKey(t *testing.T) {
 		t.Fatalf("unexpected key: %s", k)
 	}
 }
+
+func TestStatusName(t *testing.T) {
+	if have := StatusInvPaswd.Name(); have != "ESME_RINVPASWD" {
+		t.Fatalf("unexpected name: want ESME_RINVPASWD, have %q", have)
+	}
+	if have := Status(0x2000).Name(); have != "0x00002000" {
+		t.Fatalf("unexpected name: want 0x00002000, have %q", have)
+	}
+}
@@ -26,6 +26,8 @@ func New(n Name, data []byte) Body {
 		DestAddrNPI,
 		DestAddrTON,
 		ESMClass,
+		ESMEAddrNPI,
+		ESMEAddrTON,
 		ErrorCode,
 		InterfaceVersion,
 		MessageState,
@@ -46,7 +48,7 @@ func New(n Name, data []byte) Body {
 	case
 		AddressRange,
 		DestinationAddr,
-		DestinationList,
+		ESMEAddr,
 		FinalDate,
 		MessageID,
 		Password,
@@ -55,12 +57,19 @@ func New(n Name, data []byte) Body {
 		SourceAddr,
 		SystemID,
 		SystemType,
-		UnsuccessSme,
 		ValidityPeriod:
 		if data == nil {
 			data = []byte{}
 		}
 		return &Variable{Data: data}
+	case
+		DestinationList,
+		UnsuccessSme:
+		// Raw lists, not null terminated as a whole.
+		if data == nil {
+			data = []byte{}
+		}
+		return &SM{Data: data}
 	case UDHLength:
 		if len(data) == 0 {
 			return &Null{}
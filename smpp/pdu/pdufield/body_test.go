@@ -36,3 +36,36 @@ func TestData_Invalid(t *testing.T) {
 		t.Fatalf("unexpected field: %#v", d)
 	}
 }
+
+func TestDataTermination(t *testing.T) {
+	test := []struct {
+		name Name
+		data string
+		want string
+	}{
+		// C-Octet-Strings are null terminated, once.
+		{SystemID, "foo", "foo\x00"},
+		{Password, "foo\x00", "foo\x00"},
+		{ServiceType, "", "\x00"},
+		{SourceAddr, "123", "123\x00"},
+		{ValidityPeriod, "000000013000000R", "000000013000000R\x00"},
+		// Octet-Strings and raw lists are not.
+		{ShortMessage, "foo", "foo"},
+		{ShortMessage, "", ""},
+		{DestinationList, "\x01\x00\x00foo\x00", "\x01\x00\x00foo\x00"},
+		{UnsuccessSme, "\x00\x00foo\x00\x00\x00\x00\x0b", "\x00\x00foo\x00\x00\x00\x00\x0b"},
+	}
+	for _, tc := range test {
+		var b bytes.Buffer
+		d := New(tc.name, []byte(tc.data))
+		if err := d.SerializeTo(&b); err != nil {
+			t.Fatal(err)
+		}
+		if b.String() != tc.want {
+			t.Fatalf("unexpected %s: want %q, have %q", tc.name, tc.want, b.String())
+		}
+		if d.Len() != len(tc.want) {
+			t.Fatalf("unexpected %s length: want %d, have %d", tc.name, len(tc.want), d.Len())
+		}
+	}
+}
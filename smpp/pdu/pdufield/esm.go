@@ -0,0 +1,115 @@
+// Copyright 2015 go-smpp authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package pdufield
+
+// ESMClassFlags is the value of the esm_class field, which packs the
+// messaging mode, message type and GSM network specific features.
+// See SMPP 3.4 spec 5.2.12.
+type ESMClassFlags uint8
+
+// MessageMode is the messaging mode of esm_class (bits 0-1).
+type MessageMode uint8
+
+// Supported messaging modes.
+const (
+	DefaultMode         MessageMode = 0x00 // Default SMSC mode, e.g. store and forward
+	DatagramMode        MessageMode = 0x01
+	ForwardMode         MessageMode = 0x02 // Transaction mode
+	StoreAndForwardMode MessageMode = 0x03
+
+	messageModeMask = 0x03
+)
+
+// MessageType is the message type of esm_class (bits 2-5).
+type MessageType uint8
+
+// Supported message types.
+const (
+	DefaultMessageType           MessageType = 0x00
+	DeliveryReceiptType          MessageType = 0x04 // SMSC delivery receipt
+	DeliveryAckType              MessageType = 0x08 // SME delivery acknowledgement
+	UserAckType                  MessageType = 0x10 // SME manual/user acknowledgement
+	ConversationAbortType        MessageType = 0x18
+	IntermediateNotificationType MessageType = 0x20
+)
+
+// GSMFeatures are the GSM network specific features of esm_class
+// (bits 6-7).
+type GSMFeatures uint8
+
+// Supported GSM network specific features.
+const (
+	NoGSMFeatures    GSMFeatures = 0x00
+	UDHIFeature      GSMFeatures = ESMClassUDHIndicator
+	ReplyPathFeature GSMFeatures = ESMClassReplyPath
+	UDHIAndReplyPath GSMFeatures = ESMClassUDHIndicator | ESMClassReplyPath
+)
+
+// BuildESMClass returns the esm_class with the given messaging mode,
+// message type and GSM network specific features, e.g. for a reply to
+// a mobile originated message with the reply path set:
+//
+//	BuildESMClass(DefaultMode, DefaultMessageType, ReplyPathFeature)
+func BuildESMClass(mode MessageMode, msgType MessageType, features GSMFeatures) ESMClassFlags {
+	return ESMClassFlags(0).WithMode(mode).WithType(msgType).WithGSMFeatures(features)
+}
+
+// MessageMode returns the messaging mode.
+func (e ESMClassFlags) MessageMode() MessageMode {
+	return MessageMode(e & messageModeMask)
+}
+
+// MessageType returns the message type.
+func (e ESMClassFlags) MessageType() MessageType {
+	return MessageType(e & ESMClassDefaultMessageType)
+}
+
+// GSMFeatures returns the GSM network specific features.
+func (e ESMClassFlags) GSMFeatures() GSMFeatures {
+	return GSMFeatures(e & (ESMClassUDHIndicator | ESMClassReplyPath))
+}
+
+// IsDeliveryReceipt returns true if the message type is an SMSC
+// delivery receipt.
+func (e ESMClassFlags) IsDeliveryReceipt() bool {
+	return e.MessageType() == DeliveryReceiptType
+}
+
+// HasUDH returns true if the UDH indicator is set.
+func (e ESMClassFlags) HasUDH() bool {
+	return e&ESMClassUDHIndicator != 0
+}
+
+// HasReplyPath returns true if the reply path is set.
+func (e ESMClassFlags) HasReplyPath() bool {
+	return e&ESMClassReplyPath != 0
+}
+
+// WithMode returns a copy of e with the messaging mode set to m.
+func (e ESMClassFlags) WithMode(m MessageMode) ESMClassFlags {
+	return e&^messageModeMask | ESMClassFlags(m&messageModeMask)
+}
+
+// WithType returns a copy of e with the message type set to t.
+func (e ESMClassFlags) WithType(t MessageType) ESMClassFlags {
+	return e&^ESMClassDefaultMessageType | ESMClassFlags(t&ESMClassDefaultMessageType)
+}
+
+// WithGSMFeatures returns a copy of e with the GSM network specific
+// features set to f.
+func (e ESMClassFlags) WithGSMFeatures(f GSMFeatures) ESMClassFlags {
+	const mask = ESMClassUDHIndicator | ESMClassReplyPath
+	return e&^mask | ESMClassFlags(f&mask)
+}
+
+// WithUDH returns a copy of e with the UDH indicator set.
+func (e ESMClassFlags) WithUDH() ESMClassFlags {
+	return e | ESMClassUDHIndicator
+}
+
+// WithReplyPath returns a copy of e with the reply path set.
+func (e ESMClassFlags) WithReplyPath() ESMClassFlags {
+	return e | ESMClassReplyPath
+}
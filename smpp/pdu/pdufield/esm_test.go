@@ -0,0 +1,98 @@
+// Copyright 2015 go-smpp authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package pdufield
+
+import "testing"
+
+func TestESMClassFlags(t *testing.T) {
+	test := []struct {
+		v       uint8
+		mode    MessageMode
+		typ     MessageType
+		receipt bool
+		udh     bool
+		reply   bool
+	}{
+		{0x00, DefaultMode, DefaultMessageType, false, false, false},
+		{0x04, DefaultMode, DeliveryReceiptType, true, false, false},
+		{0x40, DefaultMode, DefaultMessageType, false, true, false},
+		{0x43, StoreAndForwardMode, DefaultMessageType, false, true, false},
+		{0x80, DefaultMode, DefaultMessageType, false, false, true},
+		{0x21, DatagramMode, IntermediateNotificationType, false, false, false},
+		{0xC2, ForwardMode, DefaultMessageType, false, true, true},
+		{0x08, DefaultMode, DeliveryAckType, false, false, false},
+	}
+	for _, tc := range test {
+		e := ESMClassFlags(tc.v)
+		if e.MessageMode() != tc.mode {
+			t.Fatalf("%#x: unexpected mode: want %#x, have %#x", tc.v, tc.mode, e.MessageMode())
+		}
+		if e.MessageType() != tc.typ {
+			t.Fatalf("%#x: unexpected type: want %#x, have %#x", tc.v, tc.typ, e.MessageType())
+		}
+		if e.IsDeliveryReceipt() != tc.receipt {
+			t.Fatalf("%#x: unexpected delivery receipt: want %t", tc.v, tc.receipt)
+		}
+		if e.HasUDH() != tc.udh {
+			t.Fatalf("%#x: unexpected udh: want %t", tc.v, tc.udh)
+		}
+		if e.HasReplyPath() != tc.reply {
+			t.Fatalf("%#x: unexpected reply path: want %t", tc.v, tc.reply)
+		}
+	}
+}
+
+func TestESMClassFlagsBuilder(t *testing.T) {
+	e := ESMClassFlags(0).WithMode(StoreAndForwardMode).WithType(DeliveryReceiptType).WithUDH().WithReplyPath()
+	if e != 0xC7 {
+		t.Fatalf("unexpected esm_class: want 0xc7, have %#x", uint8(e))
+	}
+	e = e.WithMode(DatagramMode).WithType(DefaultMessageType)
+	if e != 0xC1 {
+		t.Fatalf("unexpected esm_class: want 0xc1, have %#x", uint8(e))
+	}
+	m := make(Map)
+	if _, ok := m.ESMClassFlags(); ok {
+		t.Fatal("unexpected esm_class in empty map")
+	}
+	if err := m.Set(ESMClass, e); err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := m.ESMClassFlags(); !ok || v != e {
+		t.Fatalf("unexpected esm_class: want %#x, have %#x", uint8(e), uint8(v))
+	}
+}
+
+func TestBuildESMClass(t *testing.T) {
+	test := []struct {
+		mode     MessageMode
+		msgType  MessageType
+		features GSMFeatures
+		want     uint8
+	}{
+		{DefaultMode, DefaultMessageType, NoGSMFeatures, 0x00},
+		{DefaultMode, DefaultMessageType, ReplyPathFeature, 0x80},
+		{DefaultMode, DefaultMessageType, UDHIAndReplyPath, 0xC0},
+		{StoreAndForwardMode, DefaultMessageType, UDHIFeature, 0x43},
+		{DatagramMode, UserAckType, ReplyPathFeature, 0x91},
+		{ForwardMode, DeliveryAckType, UDHIAndReplyPath, 0xCA},
+	}
+	for _, tc := range test {
+		e := BuildESMClass(tc.mode, tc.msgType, tc.features)
+		if uint8(e) != tc.want {
+			t.Fatalf("unexpected esm_class: want %#x, have %#x", tc.want, uint8(e))
+		}
+		if e.MessageMode() != tc.mode || e.MessageType() != tc.msgType || e.GSMFeatures() != tc.features {
+			t.Fatalf("%#x: unexpected fields: want %d %#x %#x, have %d %#x %#x", tc.want,
+				tc.mode, tc.msgType, tc.features, e.MessageMode(), e.MessageType(), e.GSMFeatures())
+		}
+	}
+	if e := BuildESMClass(DefaultMode, DefaultMessageType, UDHIAndReplyPath); e != ESMClassFlags(0).WithUDH().WithReplyPath() {
+		t.Fatalf("unexpected esm_class: want %#x, have %#x", ESMClassUDHIndicator|ESMClassReplyPath, uint8(e))
+	}
+	if e := ESMClassFlags(0xC3).WithGSMFeatures(ReplyPathFeature); e != 0x83 {
+		t.Fatalf("unexpected esm_class: want 0x83, have %#x", uint8(e))
+	}
+}
@@ -6,6 +6,7 @@ package pdufield
 
 import (
 	"bytes"
+	"fmt"
 	"io"
 
 	"github.com/florentchauveau/go-smpp/smpp/pdu/pdutext"
@@ -14,12 +15,68 @@ import (
 // List is a list of PDU fields.
 type List []Name
 
+// DecodeOptions configures how a List is decoded. The zero value
+// is the default behavior of Decode.
+type DecodeOptions struct {
+	// RawShortMessage disables the automatic decoding of the
+	// short_message field according to data_coding. The field
+	// then holds the bytes as read off the wire.
+	RawShortMessage bool
+
+	// KeepRawShortMessage keeps the short_message bytes as read off
+	// the wire in the Wire field of the decoded SM, e.g. to debug
+	// encoding mismatches. The decoded text is unchanged.
+	KeepRawShortMessage bool
+
+	// LenientCString tolerates a missing null terminator in
+	// C-Octet-String fields with a maximum length, e.g. service_type.
+	// If none of the octets the field may take is null, the field is
+	// cut at its maximum length, assuming the terminator was omitted
+	// after a value of maximum length, and the next field is decoded
+	// from there instead of from after the next null octet.
+	LenientCString bool
+}
+
+// cStringMaxLen is the maximum length of C-Octet-String fields, null
+// terminator included, as per SMPP 3.4.
+var cStringMaxLen = map[Name]int{
+	AddressRange:         41,
+	DestinationAddr:      21,
+	ESMEAddr:             65,
+	FinalDate:            17,
+	MessageID:            65,
+	Password:             9,
+	ScheduleDeliveryTime: 17,
+	ServiceType:          6,
+	SourceAddr:           21,
+	SystemID:             16,
+	SystemType:           13,
+	ValidityPeriod:       17,
+}
+
+// SMLengthError is returned when decoding a PDU whose sm_length does
+// not match the number of short_message octets actually present.
+type SMLengthError struct {
+	SMLength int // Declared sm_length, including the UDH.
+	Have     int // Octets available for short_message, including the UDH.
+}
+
+// Error implements the error interface.
+func (e *SMLengthError) Error() string {
+	return fmt.Sprintf("sm_length mismatch: want %d octets, have %d", e.SMLength, e.Have)
+}
+
 // Decode decodes binary data in the given buffer to build a Map.
 //
 // If the ShortMessage field is present, and DataCoding as well,
 // we attempt to decode text automatically. See pdutext package
 // for more information.
 func (l List) Decode(r *bytes.Buffer) (Map, error) {
+	return l.DecodeWithOptions(r, DecodeOptions{})
+}
+
+// DecodeWithOptions is like Decode, with the given options.
+func (l List) DecodeWithOptions(r *bytes.Buffer, opts DecodeOptions) (Map, error) {
 	var (
 		unsuccessCount, numDest, udhLength, smLength int
 		dataCoding                                   pdutext.DataCoding
@@ -32,6 +89,7 @@ loop:
 		case
 			AddressRange,
 			DestinationAddr,
+			ESMEAddr,
 			ErrorCode,
 			FinalDate,
 			MessageID,
@@ -43,8 +101,18 @@ loop:
 			SystemID,
 			SystemType,
 			ValidityPeriod:
+			if n, ok := cStringMaxLen[k]; ok && opts.LenientCString &&
+				r.Len() >= n && bytes.IndexByte(r.Bytes()[:n], 0x00) < 0 {
+				f[k] = &Variable{Data: bytes.Clone(r.Next(n - 1))}
+				continue
+			}
 			b, err := r.ReadBytes(0x00)
 			if err == io.EOF {
+				if len(b) > 0 {
+					// Tolerate a missing null terminator
+					// at the end of the body.
+					f[k] = &Variable{Data: b}
+				}
 				break loop
 			}
 			if err != nil {
@@ -58,6 +126,8 @@ loop:
 			DestAddrNPI,
 			DestAddrTON,
 			ESMClass,
+			ESMEAddrNPI,
+			ESMEAddrTON,
 			InterfaceVersion,
 			NumberDests,
 			NoUnsuccess,
@@ -91,6 +161,10 @@ loop:
 				udhiFlag = mask == b&mask
 			}
 		case UDHLength:
+			if smLength == 0 {
+				// The UDH, if any, is in message_payload.
+				udhiFlag = false
+			}
 			if !udhiFlag {
 				f[k] = &Null{}
 				continue
@@ -154,6 +228,19 @@ loop:
 					return nil, err
 				}
 				dest.Flag = Fixed{Data: b}
+				if b == DistributionListFlag {
+					// Read dl_name, distribution lists have no Ton and npi
+					bt, err := r.ReadBytes(0x00)
+					if err == io.EOF {
+						break loop
+					}
+					if err != nil {
+						return nil, err
+					}
+					dest.DestAddr = Variable{Data: bt}
+					destList = append(destList, dest)
+					continue
+				}
 				// Read Ton
 				b, err = r.ReadByte()
 				if err == io.EOF {
@@ -186,6 +273,7 @@ loop:
 			f[k] = &DestSmeList{Data: destList}
 		case UnsuccessSme:
 			var unsList []UnSme
+			width := errCodeWidth(r.Bytes(), unsuccessCount)
 			for i := 0; i < unsuccessCount; i++ {
 				var uns UnSme
 				// Read Ton
@@ -215,19 +303,33 @@ loop:
 					return nil, err
 				}
 				uns.DestAddr = Variable{Data: bt}
-				// Read error code
-				uns.ErrCode = Variable{Data: r.Next(4)}
+				// Read error code, cut short at the end of the buffer
+				uns.ErrCode = Variable{Data: r.Next(width)}
 				// Add unSme to the list
 				unsList = append(unsList, uns)
 			}
 			f[k] = &UnSmeList{Data: unsList}
 		case ShortMessage:
+			n := smLength
 			if udhiFlag {
-				smLength -= udhLength + 1 // +1 for UDHLength octet
+				n -= udhLength + 1 // +1 for UDHLength octet
+			}
+			if n < 0 || n > r.Len() {
+				have := r.Len()
+				if udhiFlag {
+					have += udhLength + 1
+				}
+				return nil, &SMLengthError{SMLength: smLength, Have: have}
 			}
-			msg := r.Next(smLength)
-			// Decode text according to DataCoding
-			switch dataCoding {
+			wire := r.Next(n)
+			msg := wire
+			if opts.RawShortMessage {
+				f[k] = &SM{Data: msg}
+				continue
+			}
+			// Decode text according to DataCoding, ignoring the
+			// message class and message waiting indication bits.
+			switch dataCoding.Alphabet() {
 			case pdutext.DefaultType:
 				msg = pdutext.GSM7(msg).Decode()
 			case pdutext.Latin1Type:
@@ -237,8 +339,62 @@ loop:
 			case pdutext.ISO88595Type:
 				msg = pdutext.ISO88595(msg).Decode()
 			}
-			f[k] = &SM{Data: msg}
+			sm := &SM{Data: msg}
+			if opts.KeepRawShortMessage {
+				sm.Wire = wire
+			}
+			f[k] = sm
 		}
 	}
 	return f, nil
 }
+
+// errCodeWidth returns the number of octets of the error_status_code of
+// the n unsuccess_sme entries at the start of b. It is 4 as per the
+// specification, unless the entries only line up with the end of b, or
+// with TLVs following them, when read with 2 or 1 octet codes as sent
+// by some SMSCs.
+func errCodeWidth(b []byte, n int) int {
+	for _, w := range []int{4, 2, 1} {
+		if end := unSmeListLen(b, n, w); end >= 0 && isTLVs(b[end:]) {
+			return w
+		}
+	}
+	return 4
+}
+
+// unSmeListLen returns the length of the n unsuccess_sme entries at the
+// start of b with error codes of w octets, or -1 if they overrun b.
+func unSmeListLen(b []byte, n, w int) int {
+	off := 0
+	for i := 0; i < n; i++ {
+		off += 2 // dest_addr_ton, dest_addr_npi
+		if off > len(b) {
+			return -1
+		}
+		j := bytes.IndexByte(b[off:], 0x00)
+		if j < 0 {
+			return -1
+		}
+		off += j + 1 + w
+		if off > len(b) {
+			return -1
+		}
+	}
+	return off
+}
+
+// isTLVs reports whether b is a sequence of whole TLVs, possibly empty.
+func isTLVs(b []byte) bool {
+	for len(b) > 0 {
+		if len(b) < 4 {
+			return false
+		}
+		n := 4 + (int(b[2])<<8 | int(b[3]))
+		if n > len(b) {
+			return false
+		}
+		b = b[n:]
+	}
+	return true
+}
@@ -6,6 +6,7 @@ package pdufield
 
 import (
 	"bytes"
+	"reflect"
 	"testing"
 )
 
@@ -51,6 +52,83 @@ func TestListDecoder_Variable(t *testing.T) {
 	}
 }
 
+func TestListDecoder_VariableUnterminated(t *testing.T) {
+	l := List{SystemID, Password}
+	m, err := l.Decode(bytes.NewBufferString("hello\x00secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, want := range map[Name]string{SystemID: "hello", Password: "secret"} {
+		if v, ok := m.String(k); !ok || v != want {
+			t.Fatalf("unexpected %s: want %q, have %q (%t)", k, want, v, ok)
+		}
+	}
+	var b bytes.Buffer
+	if err := m[Password].SerializeTo(&b); err != nil {
+		t.Fatal(err)
+	}
+	if b.String() != "secret\x00" {
+		t.Fatalf("unexpected serialized password: %q", b.String())
+	}
+}
+
+func TestListDecoder_LenientCString(t *testing.T) {
+	l := List{
+		ServiceType,
+		SourceAddrTON,
+		SourceAddrNPI,
+		SourceAddr,
+		DestAddrTON,
+		DestAddrNPI,
+		DestinationAddr,
+		ESMClass,
+	}
+	test := []struct {
+		data        string
+		serviceType string
+	}{
+		{"CMT12\x01\x02123\x00\x05\x06456\x00\x40", "CMT12"}, // service_type without null
+		{"CMT\x00\x01\x02123\x00\x05\x06456\x00\x40", "CMT"},
+		{"\x00\x01\x02123\x00\x05\x06456\x00\x40", ""},
+	}
+	for _, tc := range test {
+		m, err := l.DecodeWithOptions(bytes.NewBufferString(tc.data), DecodeOptions{LenientCString: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := map[Name]string{
+			ServiceType:     tc.serviceType,
+			SourceAddr:      "123",
+			DestinationAddr: "456",
+		}
+		for k, v := range want {
+			if have, ok := m.String(k); !ok || have != v {
+				t.Fatalf("unexpected %s: want %q, have %q (%t)", k, v, have, ok)
+			}
+		}
+		wantFixed := map[Name]uint8{
+			SourceAddrTON: 0x01,
+			SourceAddrNPI: 0x02,
+			DestAddrTON:   0x05,
+			DestAddrNPI:   0x06,
+			ESMClass:      0x40,
+		}
+		for k, v := range wantFixed {
+			if have, ok := m.Uint8(k); !ok || have != v {
+				t.Fatalf("unexpected %s: want %#02x, have %#02x (%t)", k, v, have, ok)
+			}
+		}
+	}
+	// Without LenientCString, service_type runs into the next fields.
+	m, err := l.Decode(bytes.NewBufferString(test[0].data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := m.String(ServiceType); v == "CMT12" {
+		t.Fatalf("unexpected service_type: %q", v)
+	}
+}
+
 func TestListDecoder_SM(t *testing.T) {
 	l := List{SMLength, ShortMessage}
 	want := []byte{0x05, 'h', 'e', 'l', 'l', 'o', 0x0A, 0x0B}
@@ -105,6 +183,38 @@ func TestListDecoder_DestinationList(t *testing.T) {
 	}
 }
 
+func TestListDecoder_DestinationListMixed(t *testing.T) {
+	l := List{NumberDests, DestinationList}
+	raw := []byte{
+		0x03,
+		0x01, 0x01, 0x01, '3', '3', '6', 0x00,
+		0x02, 's', 't', 'a', 'f', 'f', 0x00,
+		0x01, 0x00, 0x00, 'f', 'o', 'o', 0x00,
+	}
+	m, err := l.Decode(bytes.NewBuffer(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	have, ok := m.Destinations()
+	if !ok {
+		t.Fatalf("missing %q key: %#v", DestinationList, m)
+	}
+	want := []Destination{
+		{Type: SMEAddressFlag, Addr: "336", TON: 0x01, NPI: 0x01},
+		{Type: DistributionListFlag, Addr: "staff"},
+		{Type: SMEAddressFlag, Addr: "foo"},
+	}
+	if !reflect.DeepEqual(have, want) {
+		t.Fatalf("unexpected destinations:\nwant: %+v\nhave: %+v", want, have)
+	}
+	if b := m[DestinationList].Bytes(); !bytes.Equal(b, raw[1:]) {
+		t.Fatalf("unexpected dest_addresses: want %x, have %x", raw[1:], b)
+	}
+	if _, ok := make(Map).Destinations(); ok {
+		t.Fatal("unexpected destinations for absent field")
+	}
+}
+
 func TestListDecoder_UnSmeList(t *testing.T) {
 	l := List{NoUnsuccess, UnsuccessSme}
 	want := []byte{0x01, 0x01, 0x01, '1', '2', '3', 0x00, 0x00, 0x00, 0x00, 0x11, 0x00}
@@ -135,6 +245,74 @@ func TestListDecoder_UnSmeList(t *testing.T) {
 	}
 }
 
+func TestListDecoder_UnSmeListErrCodeWidth(t *testing.T) {
+	l := List{NoUnsuccess, UnsuccessSme}
+	test := []struct {
+		name string
+		code []byte
+		want uint32
+		tlv  []byte
+	}{
+		{"1 octet", []byte{0x11}, 0x11, nil},
+		{"2 octets", []byte{0x04, 0x11}, 0x411, nil},
+		{"4 octets", []byte{0x00, 0x00, 0x04, 0x11}, 0x411, nil},
+		{"1 octet with TLV", []byte{0x11}, 0x11, []byte{0x04, 0x20, 0x00, 0x01, 0x01}},
+		{"4 octets with TLV", []byte{0x00, 0x00, 0x04, 0x11}, 0x411, []byte{0x04, 0x20, 0x00, 0x01, 0x01}},
+	}
+	for _, tc := range test {
+		t.Run(tc.name, func(t *testing.T) {
+			b := []byte{0x02}
+			for _, addr := range []string{"123", "4567"} {
+				b = append(b, 0x01, 0x01)
+				b = append(b, addr...)
+				b = append(b, 0x00)
+				b = append(b, tc.code...)
+			}
+			b = append(b, tc.tlv...)
+			r := bytes.NewBuffer(b)
+			m, err := l.Decode(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			v, ok := m[UnsuccessSme].(*UnSmeList)
+			if !ok || len(v.Data) != 2 {
+				t.Fatalf("unexpected unsuccess_sme: %#v", m[UnsuccessSme])
+			}
+			for i, addr := range []string{"123", "4567"} {
+				uns := v.Data[i]
+				if uns.DestAddr.String() != addr {
+					t.Fatalf("entry %d: unexpected address: want %q, have %q", i, addr, uns.DestAddr)
+				}
+				if !bytes.Equal(uns.ErrCode.Data, tc.code) {
+					t.Fatalf("entry %d: unexpected error code: want %x, have %x", i, tc.code, uns.ErrCode.Data)
+				}
+				if c := uns.ErrorCode(); c != tc.want {
+					t.Fatalf("entry %d: unexpected error code value: want %#x, have %#x", i, tc.want, c)
+				}
+			}
+			if !bytes.Equal(r.Bytes(), tc.tlv) {
+				t.Fatalf("unexpected remaining bytes: want %x, have %x", tc.tlv, r.Bytes())
+			}
+		})
+	}
+}
+
+func TestListDecoder_UnSmeListTruncated(t *testing.T) {
+	l := List{NoUnsuccess, UnsuccessSme}
+	b := []byte{0x01, 0x01, 0x01, '1', '2', '3', 0x00, 0x00, 0x04, 0x11}
+	m, err := l.Decode(bytes.NewBuffer(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, ok := m[UnsuccessSme].(*UnSmeList)
+	if !ok || len(v.Data) != 1 {
+		t.Fatalf("unexpected unsuccess_sme: %#v", m[UnsuccessSme])
+	}
+	if c := v.Data[0].ErrorCode(); c != 0x411 {
+		t.Fatalf("unexpected error code: want %#x, have %#x", 0x411, c)
+	}
+}
+
 func TestListDecoderDataEncoding(t *testing.T) {
 	l := List{
 		ServiceType,
@@ -184,3 +362,86 @@ func TestListDecoderDataEncoding(t *testing.T) {
 		t.Fatalf("unexpected decoded text: want %q, have %q", wantText, sm.String())
 	}
 }
+
+func TestListDecoder_RawShortMessage(t *testing.T) {
+	l := List{DataCoding, SMLength, ShortMessage}
+	ucs2 := []byte{0x00, 0x68, 0x00, 0x69} // "hi" in UCS2
+	data := append([]byte{0x08, byte(len(ucs2))}, ucs2...)
+
+	m, err := l.Decode(bytes.NewBuffer(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := m[ShortMessage].Bytes(); !bytes.Equal(v, []byte("hi")) {
+		t.Fatalf("unexpected decoded data: want %q, have %q", "hi", v)
+	}
+
+	m, err = l.DecodeWithOptions(bytes.NewBuffer(data), DecodeOptions{RawShortMessage: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := m[ShortMessage].Bytes(); !bytes.Equal(v, ucs2) {
+		t.Fatalf("unexpected raw data: want %q, have %q", ucs2, v)
+	}
+}
+
+func TestListDecoder_KeepRawShortMessage(t *testing.T) {
+	l := List{DataCoding, SMLength, ShortMessage}
+	ucs2 := []byte{0x00, 0x68, 0x00, 0x69} // "hi" in UCS2
+	data := append([]byte{0x08, byte(len(ucs2))}, ucs2...)
+
+	m, err := l.Decode(bytes.NewBuffer(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := m.WireShortMessage(); ok {
+		t.Fatal("unexpected wire short_message without KeepRawShortMessage")
+	}
+
+	m, err = l.DecodeWithOptions(bytes.NewBuffer(data), DecodeOptions{KeepRawShortMessage: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := m[ShortMessage].Bytes(); !bytes.Equal(v, []byte("hi")) {
+		t.Fatalf("unexpected decoded data: want %q, have %q", "hi", v)
+	}
+	v, ok := m.WireShortMessage()
+	if !ok || !bytes.Equal(v, ucs2) {
+		t.Fatalf("unexpected wire data: want %q, have %q (%t)", ucs2, v, ok)
+	}
+}
+
+func TestListDecoder_MessageClass(t *testing.T) {
+	l := List{DataCoding, SMLength, ShortMessage}
+	test := []struct {
+		dc   byte
+		data []byte
+	}{
+		{0x10, []byte("hi")},                   // GSM7, class 0
+		{0x18, []byte{0x00, 0x68, 0x00, 0x69}}, // UCS2, class 0
+		{0xE8, []byte{0x00, 0x68, 0x00, 0x69}}, // UCS2, MWI
+	}
+	for _, tc := range test {
+		data := append([]byte{tc.dc, byte(len(tc.data))}, tc.data...)
+		m, err := l.Decode(bytes.NewBuffer(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v := m[ShortMessage].Bytes(); !bytes.Equal(v, []byte("hi")) {
+			t.Fatalf("unexpected decoded data for %#x: want %q, have %q", tc.dc, "hi", v)
+		}
+	}
+}
+
+func TestListDecoder_SMLengthMismatch(t *testing.T) {
+	l := List{ESMClass, SMLength, UDHLength, GSMUserData, ShortMessage}
+	for _, data := range [][]byte{
+		{0x00, 0x06, 'h', 'e', 'l', 'l', 'o'},
+		{0x40, 0x02, 0x03, 0x00, 0x01, 0x02, 'h', 'i'},
+	} {
+		_, err := l.Decode(bytes.NewBuffer(data))
+		if _, ok := err.(*SMLengthError); !ok {
+			t.Fatalf("unexpected error for % x: want SMLengthError, have %v", data, err)
+		}
+	}
+}
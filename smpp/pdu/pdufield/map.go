@@ -6,6 +6,7 @@ package pdufield
 
 import (
 	"fmt"
+	"reflect"
 
 	"github.com/florentchauveau/go-smpp/smpp/pdu/pdutext"
 )
@@ -20,7 +21,12 @@ type Map map[Name]Body
 //
 // If k is ShortMessage and v is of type pdutext.Codec, text is
 // encoded and data_coding PDU and sm_length PDUs are set.
+//
+// A nil pointer, e.g. a nil *Fixed, is an error.
 func (m Map) Set(k Name, v any) error {
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Pointer && rv.IsNil() {
+		return fmt.Errorf("nil field data: %T", v)
+	}
 	switch v := v.(type) {
 	case nil:
 		m[k] = New(k, nil) // use default value
@@ -34,6 +40,8 @@ func (m Map) Set(k Name, v any) error {
 		m[k] = New(k, []byte(v))
 	case DeliverySetting:
 		m[k] = New(k, []byte{uint8(v)})
+	case ESMClassFlags:
+		m[k] = New(k, []byte{uint8(v)})
 	case Body:
 		m[k] = v
 	case pdutext.Codec:
@@ -50,3 +58,73 @@ func (m Map) Set(k Name, v any) error {
 	}
 	return nil
 }
+
+// Uint8 returns the value of the fixed length field k. It returns
+// false if the field is not present or is not a Fixed field.
+func (m Map) Uint8(k Name) (uint8, bool) {
+	f, ok := m[k].(*Fixed)
+	if !ok || f == nil {
+		return 0, false
+	}
+	return f.Data, true
+}
+
+// String returns the text of the variable length or short message
+// field k, without the null terminator. It returns false if the field
+// is not present or is not a text field.
+func (m Map) String(k Name) (string, bool) {
+	switch f := m[k].(type) {
+	case *Variable:
+		if f == nil {
+			return "", false
+		}
+		return f.String(), true
+	case *SM:
+		if f == nil {
+			return "", false
+		}
+		return f.String(), true
+	}
+	return "", false
+}
+
+// Bytes returns the raw binary data of field k. It returns false
+// if the field is not present or does not hold binary data, such as
+// Fixed or Null fields.
+func (m Map) Bytes(k Name) ([]byte, bool) {
+	f, ok := m[k]
+	if !ok || f == nil {
+		return nil, false
+	}
+	b, ok := f.Raw().([]byte)
+	return b, ok
+}
+
+// WireShortMessage returns the short_message bytes as read off the
+// wire, before decoding according to data_coding. It returns false if
+// the Map was not decoded with DecodeOptions.KeepRawShortMessage.
+func (m Map) WireShortMessage() ([]byte, bool) {
+	sm, ok := m[ShortMessage].(*SM)
+	if !ok || sm.Wire == nil {
+		return nil, false
+	}
+	return sm.Wire, true
+}
+
+// Destinations returns the entries of the dest_addresses field of a
+// decoded submit_multi, SME addresses and distribution lists in order.
+// It returns false if the field is not present.
+func (m Map) Destinations() ([]Destination, bool) {
+	dsl, ok := m[DestinationList].(*DestSmeList)
+	if !ok || dsl == nil {
+		return nil, false
+	}
+	return dsl.Destinations(), true
+}
+
+// ESMClassFlags returns the esm_class field as typed flags. It returns
+// false if the field is not present.
+func (m Map) ESMClassFlags() (ESMClassFlags, bool) {
+	v, ok := m.Uint8(ESMClass)
+	return ESMClassFlags(v), ok
+}
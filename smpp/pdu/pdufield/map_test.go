@@ -26,6 +26,7 @@ func TestMapSet(t *testing.T) {
 		{DataCoding, int(1), true},
 		{DataCoding, t, false},
 		{DataCoding, New(DataCoding, []byte{0x03}), true},
+		{DataCoding, (*Fixed)(nil), false},
 	}
 	for _, el := range test {
 		if err := m.Set(el.k, el.v); el.ok && err != nil {
@@ -64,3 +65,49 @@ func TestMapSetTextCodec(t *testing.T) {
 		t.Fatalf("unexpected text: want %q, have %q", text, nt)
 	}
 }
+
+func TestMapTypedAccessors(t *testing.T) {
+	m := make(Map)
+	_ = m.Set(DataCoding, uint8(0x08))
+	_ = m.Set(SourceAddr, "root")
+	_ = m.Set(ShortMessage, []byte("hello"))
+	_ = m.Set(UDHLength, nil)
+
+	if v, ok := m.Uint8(DataCoding); !ok || v != 0x08 {
+		t.Fatalf("unexpected uint8: want 8, have %d (%t)", v, ok)
+	}
+	if v, ok := m.String(SourceAddr); !ok || v != "root" {
+		t.Fatalf("unexpected string: want %q, have %q (%t)", "root", v, ok)
+	}
+	if v, ok := m.String(ShortMessage); !ok || v != "hello" {
+		t.Fatalf("unexpected string: want %q, have %q (%t)", "hello", v, ok)
+	}
+	if v, ok := m.Bytes(ShortMessage); !ok || !bytes.Equal(v, []byte("hello")) {
+		t.Fatalf("unexpected bytes: want %q, have %q (%t)", "hello", v, ok)
+	}
+
+	// absent fields
+	if _, ok := m.Uint8(ESMClass); ok {
+		t.Fatal("unexpected uint8 for absent field")
+	}
+	if _, ok := m.String(DestinationAddr); ok {
+		t.Fatal("unexpected string for absent field")
+	}
+	if _, ok := m.Bytes(MessageID); ok {
+		t.Fatal("unexpected bytes for absent field")
+	}
+
+	// wrong types
+	if _, ok := m.Uint8(SourceAddr); ok {
+		t.Fatal("unexpected uint8 for variable field")
+	}
+	if _, ok := m.String(DataCoding); ok {
+		t.Fatal("unexpected string for fixed field")
+	}
+	if _, ok := m.Bytes(DataCoding); ok {
+		t.Fatal("unexpected bytes for fixed field")
+	}
+	if _, ok := m.Bytes(UDHLength); ok {
+		t.Fatal("unexpected bytes for null field")
+	}
+}
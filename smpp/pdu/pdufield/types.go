@@ -10,6 +10,8 @@ import (
 	"io"
 	"strconv"
 	"strings"
+
+	"github.com/florentchauveau/go-smpp/smpp/pdu/pdutext"
 )
 
 // Name is the name of a PDU field.
@@ -26,6 +28,9 @@ const (
 	DestinationAddr      Name = "destination_addr"
 	DestinationList      Name = "dest_addresses"
 	ESMClass             Name = "esm_class"
+	ESMEAddr             Name = "esme_addr"
+	ESMEAddrNPI          Name = "esme_addr_npi"
+	ESMEAddrTON          Name = "esme_addr_ton"
 	ErrorCode            Name = "error_code"
 	FinalDate            Name = "final_date"
 	InterfaceVersion     Name = "interface_version"
@@ -54,11 +59,45 @@ const (
 	ValidityPeriod       Name = "validity_period"
 
 	UDHIEIConcatenatedShortMessage8Bit  = 0x00
+	UDHIEIApplicationPort8Bit           = 0x04
+	UDHIEIApplicationPort16Bit          = 0x05
 	UDHIEIConcatenatedShortMessage16Bit = 0x08
+	UDHIEINationalLanguageSingleShift   = 0x24
+	UDHIEINationalLanguageLockingShift  = 0x25
 
 	ESMClassUDHIndicator        = 0x40
+	ESMClassReplyPath           = 0x80
 	ESMClassSMSCDeliveryReceipt = 0x04
 	ESMClassDefaultMessageType  = 0x3C
+
+	// MaxAddrLen is the maximum length of source_addr and
+	// destination_addr, not including the null terminator.
+	MaxAddrLen = 20
+)
+
+// Type of Number (TON) values, see SMPP 3.4 spec 5.2.5.
+const (
+	TONUnknown          = 0x00
+	TONInternational    = 0x01
+	TONNational         = 0x02
+	TONNetworkSpecific  = 0x03
+	TONSubscriberNumber = 0x04
+	TONAlphanumeric     = 0x05
+	TONAbbreviated      = 0x06
+)
+
+// Numbering Plan Indicator (NPI) values, see SMPP 3.4 spec 5.2.6.
+const (
+	NPIUnknown  = 0x00
+	NPIISDN     = 0x01 // E163/E164
+	NPIData     = 0x03 // X.121
+	NPITelex    = 0x04 // F.69
+	NPILandMob  = 0x06 // E.212
+	NPINational = 0x08
+	NPIPrivate  = 0x09
+	NPIERMES    = 0x0A
+	NPIInternet = 0x0E // IP
+	NPIWAP      = 0x12 // WAP Client Id
 )
 
 // Fixed is a PDU of fixed length.
@@ -88,18 +127,25 @@ func (f *Fixed) Bytes() []byte {
 
 // SerializeTo implements the Data interface.
 func (f *Fixed) SerializeTo(w io.Writer) error {
+	if bw, ok := w.(io.ByteWriter); ok {
+		return bw.WriteByte(f.Data)
+	}
 	_, err := w.Write(f.Bytes())
 	return err
 }
 
-// Variable is a PDU field of variable length.
+// Variable is a PDU field of variable length, a C-Octet-String. It is
+// serialized with a null terminator, unless Data already ends with one.
 type Variable struct {
 	Data []byte
 }
 
 // Len implements the Data interface.
 func (v *Variable) Len() int {
-	return len(v.Bytes())
+	if len(v.Data) > 0 && v.Data[len(v.Data)-1] == 0x00 {
+		return len(v.Data)
+	}
+	return len(v.Data) + 1
 }
 
 // Raw implements the Data interface.
@@ -120,13 +166,31 @@ func (v *Variable) Bytes() []byte {
 	if len(v.Data) > 0 && v.Data[len(v.Data)-1] == 0x00 {
 		return v.Data
 	}
-	return append(v.Data, 0x00)
+	// Copy so appending the terminator never writes into the
+	// backing array of Data.
+	b := make([]byte, len(v.Data), len(v.Data)+1)
+	copy(b, v.Data)
+	return append(b, 0x00)
+}
+
+// RawBytes returns Data verbatim, without adding or removing the
+// trailing null terminator.
+func (v *Variable) RawBytes() []byte {
+	return v.Data
 }
 
 // SerializeTo implements the Data interface.
 func (v *Variable) SerializeTo(w io.Writer) error {
-	_, err := w.Write(v.Bytes())
-	return err
+	bw, ok := w.(io.ByteWriter)
+	if !ok || v.Len() == len(v.Data) {
+		_, err := w.Write(v.Bytes())
+		return err
+	}
+	// Write the terminator separately, saving a copy of Data.
+	if _, err := w.Write(v.Data); err != nil {
+		return err
+	}
+	return bw.WriteByte(0x00)
 }
 
 // Null is an optional PDU field, it does not write any data.
@@ -157,9 +221,11 @@ func (o *Null) SerializeTo(w io.Writer) error {
 	return nil
 }
 
-// SM is a PDU field used for Short Messages.
+// SM is a PDU field used for Short Messages, an Octet-String. It is
+// serialized as is, without a null terminator.
 type SM struct {
 	Data []byte
+	Wire []byte // Bytes as read off the wire, see DecodeOptions.KeepRawShortMessage.
 }
 
 // Len implements the Data interface.
@@ -189,16 +255,31 @@ func (sm *SM) SerializeTo(w io.Writer) error {
 }
 
 // DeliverySetting is used to configure registered delivery
-// for short messages.
+// for short messages. It is the whole registered_delivery bitmask: one
+// of the receipt settings, ORed with the acknowledgement and
+// intermediate notification bits if requested, e.g.
+// FinalDeliveryReceipt | IntermediateNotification.
 type DeliverySetting uint8
 
-// Supported delivery settings.
+// Supported delivery settings, see SMPP 3.4 spec 5.2.17.
 const (
 	NoDeliveryReceipt      DeliverySetting = 0x00
 	FinalDeliveryReceipt   DeliverySetting = 0x01
 	FailureDeliveryReceipt DeliverySetting = 0x02
+
+	// SME originated acknowledgement bits.
+	DeliveryAcknowledgement DeliverySetting = 0x04
+	UserAcknowledgement     DeliverySetting = 0x08
+
+	IntermediateNotification DeliverySetting = 0x10
 )
 
+// Receipt returns the SMSC delivery receipt setting of d, without the
+// acknowledgement and intermediate notification bits.
+func (d DeliverySetting) Receipt() DeliverySetting {
+	return d & 0x03
+}
+
 // DestSme is a PDU field used for an sme addreses.
 type DestSme struct {
 	Flag     Fixed
@@ -207,8 +288,27 @@ type DestSme struct {
 	DestAddr Variable
 }
 
+// Destination flags of submit_multi dest_addresses.
+const (
+	SMEAddressFlag       = 0x01
+	DistributionListFlag = 0x02
+)
+
+// NewDestSme creates a new DestSme for the given SME address.
+func NewDestSme(ton, npi uint8, addr string) DestSme {
+	return DestSme{
+		Flag:     Fixed{Data: SMEAddressFlag},
+		Ton:      Fixed{Data: ton},
+		Npi:      Fixed{Data: npi},
+		DestAddr: Variable{Data: []byte(addr)},
+	}
+}
+
 // Len implements the Data interface.
 func (ds *DestSme) Len() int {
+	if ds.Flag.Data == DistributionListFlag {
+		return ds.Flag.Len() + ds.DestAddr.Len()
+	}
 	return ds.Flag.Len() + ds.Ton.Len() + ds.Npi.Len() + ds.DestAddr.Len()
 }
 
@@ -222,10 +322,14 @@ func (ds *DestSme) String() string {
 	return ds.Flag.String() + "," + ds.Ton.String() + "," + ds.Npi.String() + "," + ds.DestAddr.String()
 }
 
-// Bytes implements the Data interface.
+// Bytes implements the Data interface. Distribution list entries have
+// no Ton and Npi.
 func (ds *DestSme) Bytes() []byte {
 	var ret []byte
 	ret = append(ret, ds.Flag.Bytes()...)
+	if ds.Flag.Data == DistributionListFlag {
+		return append(ret, ds.DestAddr.Bytes()...)
+	}
 	ret = append(ret, ds.Ton.Bytes()...)
 	ret = append(ret, ds.Npi.Bytes()...)
 	ret = append(ret, ds.DestAddr.Bytes()...)
@@ -238,11 +342,37 @@ func (ds *DestSme) SerializeTo(w io.Writer) error {
 	return err
 }
 
+// Destination is an entry of the dest_addresses of submit_multi.
+type Destination struct {
+	Type uint8  // SMEAddressFlag or DistributionListFlag.
+	Addr string // SME address, or distribution list name.
+	TON  uint8  // TON of an SME address.
+	NPI  uint8  // NPI of an SME address.
+}
+
+// Destination returns ds as a Destination.
+func (ds *DestSme) Destination() Destination {
+	d := Destination{Type: ds.Flag.Data, Addr: ds.DestAddr.String()}
+	if d.Type != DistributionListFlag {
+		d.TON, d.NPI = ds.Ton.Data, ds.Npi.Data
+	}
+	return d
+}
+
 // DestSmeList contains a list of DestSme
 type DestSmeList struct {
 	Data []DestSme
 }
 
+// Destinations returns the entries of dsl as Destinations.
+func (dsl *DestSmeList) Destinations() []Destination {
+	d := make([]Destination, len(dsl.Data))
+	for i := range dsl.Data {
+		d[i] = dsl.Data[i].Destination()
+	}
+	return d
+}
+
 // Len implements the Data interface.
 func (dsl *DestSmeList) Len() int {
 	var ret int
@@ -301,7 +431,7 @@ func (us *UnSme) Raw() any {
 
 // String implements the Data interface.
 func (us *UnSme) String() string {
-	return us.Ton.String() + "," + us.Npi.String() + "," + us.DestAddr.String() + "," + strconv.Itoa(int(binary.BigEndian.Uint32(us.ErrCode.Data)))
+	return us.Ton.String() + "," + us.Npi.String() + "," + us.DestAddr.String() + "," + strconv.Itoa(int(us.ErrorCode()))
 }
 
 // Bytes implements the Data interface.
@@ -314,6 +444,18 @@ func (us *UnSme) Bytes() []byte {
 	return ret
 }
 
+// ErrorCode returns the error_status_code. Codes shorter than 4 octets,
+// as sent by some SMSCs, are read as big endian values of their length,
+// and codes longer than 4 octets return zero.
+func (us *UnSme) ErrorCode() uint32 {
+	if len(us.ErrCode.Data) > 4 {
+		return 0
+	}
+	var b [4]byte
+	copy(b[4-len(us.ErrCode.Data):], us.ErrCode.Data)
+	return binary.BigEndian.Uint32(b[:])
+}
+
 // SerializeTo implements the Data interface.
 func (us *UnSme) SerializeTo(w io.Writer) error {
 	_, err := w.Write(us.Bytes())
@@ -391,11 +533,12 @@ func (ie *UDHIE) String() string {
 	return strings.Join(ret, ":")
 }
 
-// Bytes implements the Data interface.
+// Bytes implements the Data interface. The IE length octet is computed
+// from IEData.
 func (ie *UDHIE) Bytes() []byte {
 	var ret []byte
 	ret = append(ret, ie.IEI)
-	ret = append(ret, ie.IELength)
+	ret = append(ret, uint8(len(ie.IEData)))
 	ret = append(ret, ie.IEData...)
 	return ret
 }
@@ -411,6 +554,22 @@ type UDH struct {
 	IE []UDHIE
 }
 
+// NewUDH creates a new UDH with the given IEs.
+func NewUDH(ies ...UDHIE) UDH {
+	var udh UDH
+	udh.Append(ies...)
+	return udh
+}
+
+// Append adds the given IEs to the UDH, setting their IELength from
+// their data.
+func (udh *UDH) Append(ies ...UDHIE) {
+	for _, ie := range ies {
+		ie.IELength = uint8(len(ie.IEData))
+		udh.IE = append(udh.IE, ie)
+	}
+}
+
 // Len implements the Data interface.
 func (udh *UDH) Len() int {
 	var ret int
@@ -451,28 +610,71 @@ func (udh *UDH) SerializeTo(w io.Writer) error {
 }
 
 // IsConcatenated checks if the UDH contains a concatenated short message IE.
+// The 16-bit reference IE is preferred if both are present, and IEs with
+// an invalid length are ignored.
 func (udh *UDH) IsConcatenated() (concatenated bool, ref, total, part int) {
+	total = 1
+	part = 1
 	for _, ie := range udh.IE {
-		if ie.IEI == UDHIEIConcatenatedShortMessage8Bit && ie.IELength == 3 {
-			concatenated = true
-			ref = int(ie.IEData[0])
-			total = int(ie.IEData[1])
-			part = int(ie.IEData[2])
-			return
-		}
-		if ie.IEI == UDHIEIConcatenatedShortMessage16Bit && ie.IELength == 4 {
-			concatenated = true
+		if ie.IEI == UDHIEIConcatenatedShortMessage16Bit && ie.valid() {
 			ref = int(binary.BigEndian.Uint16(ie.IEData[0:2]))
-			total = int(ie.IEData[2])
-			part = int(ie.IEData[3])
-			return
+			return true, ref, int(ie.IEData[2]), int(ie.IEData[3])
+		}
+	}
+	for _, ie := range udh.IE {
+		if ie.IEI == UDHIEIConcatenatedShortMessage8Bit && ie.valid() {
+			return true, int(ie.IEData[0]), int(ie.IEData[1]), int(ie.IEData[2])
 		}
 	}
-	total = 1
-	part = 1
 	return
 }
 
+// valid reports whether the data of ie matches its length, and the
+// length of a concatenation IE is 3 or 4 octets for the 8-bit or
+// 16-bit reference.
+func (ie UDHIE) valid() bool {
+	if int(ie.IELength) != len(ie.IEData) {
+		return false
+	}
+	switch ie.IEI {
+	case UDHIEIConcatenatedShortMessage8Bit:
+		return ie.IELength == 3
+	case UDHIEIConcatenatedShortMessage16Bit:
+		return ie.IELength == 4
+	}
+	return true
+}
+
+// Validate checks the structure of the UDH. It returns an error if the
+// data of an IE does not match its length, a concatenation IE does not
+// have the length of its reference size or has a part out of range, or
+// the concatenation IEs of both reference sizes disagree.
+func (udh *UDH) Validate() error {
+	var parts [][2]int // total and part of each concatenation IE
+	for _, ie := range udh.IE {
+		if !ie.valid() {
+			return fmt.Errorf("invalid UDH: IE %#02x with length %d and %d octets of data",
+				ie.IEI, ie.IELength, len(ie.IEData))
+		}
+		n := len(ie.IEData)
+		switch ie.IEI {
+		case UDHIEIConcatenatedShortMessage8Bit, UDHIEIConcatenatedShortMessage16Bit:
+			total, part := int(ie.IEData[n-2]), int(ie.IEData[n-1])
+			if total == 0 || part == 0 || part > total {
+				return fmt.Errorf("invalid UDH: concatenated part %d of %d", part, total)
+			}
+			parts = append(parts, [2]int{total, part})
+		}
+	}
+	for _, p := range parts {
+		if p != parts[0] {
+			return fmt.Errorf("invalid UDH: concatenation IEs disagree, part %d of %d and %d of %d",
+				parts[0][1], parts[0][0], p[1], p[0])
+		}
+	}
+	return nil
+}
+
 // NewIEConcatenatedShortMessage creates a new UDHIE for a concatenated short message.
 func NewIEConcatenatedShortMessage(ref uint16, total int, part int) UDHIE {
 	var iei uint8
@@ -511,3 +713,39 @@ func NewUDHConcatenatedShortMessage(ref uint16, total int, part int) UDH {
 		},
 	}
 }
+
+// NewIEApplicationPort16Bit creates a new UDHIE for application port
+// addressing with 16 bit port numbers, e.g. for WAP push.
+func NewIEApplicationPort16Bit(dst, src uint16) UDHIE {
+	return UDHIE{
+		IEI:      UDHIEIApplicationPort16Bit,
+		IELength: 4,
+		IEData: []byte{
+			byte(dst >> 8),
+			byte(dst & 0xFF),
+			byte(src >> 8),
+			byte(src & 0xFF),
+		},
+	}
+}
+
+// NewIENationalLanguageShift creates new UDHIEs for the given national
+// language shift tables. Tables set to the default language are omitted.
+func NewIENationalLanguageShift(s pdutext.ShiftTables) []UDHIE {
+	var ies []UDHIE
+	if s.Locking != pdutext.DefaultLanguage {
+		ies = append(ies, UDHIE{
+			IEI:      UDHIEINationalLanguageLockingShift,
+			IELength: 1,
+			IEData:   []byte{byte(s.Locking)},
+		})
+	}
+	if s.Single != pdutext.DefaultLanguage {
+		ies = append(ies, UDHIE{
+			IEI:      UDHIEINationalLanguageSingleShift,
+			IELength: 1,
+			IEData:   []byte{byte(s.Single)},
+		})
+	}
+	return ies
+}
@@ -61,6 +61,32 @@ func TestVariable(t *testing.T) {
 	}
 }
 
+func TestVariableRawBytes(t *testing.T) {
+	for _, data := range [][]byte{
+		[]byte("foo\x00bar"),
+		[]byte("foobar\x00"),
+		[]byte("foobar\x00\x00"),
+	} {
+		f := &Variable{Data: data}
+		if v := f.RawBytes(); !bytes.Equal(data, v) {
+			t.Fatalf("unexpected raw bytes: want %q, have %q", data, v)
+		}
+	}
+	f := &Variable{Data: []byte("foobar\x00\x00")}
+	if v := f.Bytes(); !bytes.Equal(f.Data, v) {
+		t.Fatalf("unexpected bytes: want %q, have %q", f.Data, v)
+	}
+	// Bytes must not write the terminator into spare capacity of Data.
+	buf := []byte("foobarXX")
+	f = &Variable{Data: buf[:6]}
+	if v := f.Bytes(); !bytes.Equal([]byte("foobar\x00"), v) {
+		t.Fatalf("unexpected bytes: want %q, have %q", "foobar\x00", v)
+	}
+	if buf[6] != 'X' {
+		t.Fatalf("unexpected write past Data: have %q", buf)
+	}
+}
+
 func TestSM(t *testing.T) {
 	want := []byte("foobar")
 	f := &SM{Data: want}
@@ -198,6 +224,28 @@ func TestUnSme(t *testing.T) {
 	}
 }
 
+func TestUnSmeErrorCode(t *testing.T) {
+	f := UnSme{ErrCode: Variable{Data: []byte{0x00, 0x00, 0x04, 0x11}}}
+	if v := f.ErrorCode(); v != 0x411 {
+		t.Fatalf("unexpected error code: want %d, have %d", 0x411, v)
+	}
+	f.ErrCode.Data = []byte{0x11}
+	if v := f.ErrorCode(); v != 0x11 {
+		t.Fatalf("unexpected error code for 1 octet: want %d, have %d", 0x11, v)
+	}
+	f.ErrCode.Data = []byte{0x04, 0x11}
+	if v := f.ErrorCode(); v != 0x411 {
+		t.Fatalf("unexpected error code for 2 octets: want %d, have %d", 0x411, v)
+	}
+	f.ErrCode.Data = []byte{0x00, 0x00, 0x00, 0x04, 0x11}
+	if v := f.ErrorCode(); v != 0 {
+		t.Fatalf("unexpected error code for long data: want 0, have %d", v)
+	}
+	if v := f.String(); v == "" {
+		t.Fatal("unexpected empty string")
+	}
+}
+
 func TestUnSmeList(t *testing.T) {
 	err := []byte{0x00, 0x00, 0x00, 0x11}
 	ton := Fixed{Data: byte(0x01)}
@@ -259,3 +307,64 @@ func TestUDH(t *testing.T) {
 		t.Fatalf("unexpected serialized bytes: want %q, have %q", want, v)
 	}
 }
+
+func TestUDHMultipleIE(t *testing.T) {
+	udh := NewUDH(
+		NewIEConcatenatedShortMessage(0x4142, 3, 1),
+		NewIEApplicationPort16Bit(2948, 9200),
+	)
+	udh.Append(UDHIE{IEI: 0x70, IEData: []byte{0x01}})
+	want := []byte{
+		0x08, 0x04, 0x41, 0x42, 0x03, 0x01,
+		0x05, 0x04, 0x0b, 0x84, 0x23, 0xf0,
+		0x70, 0x01, 0x01,
+	}
+	if udh.Len() != len(want) {
+		t.Fatalf("unexpected len: want %d, have %d", len(want), udh.Len())
+	}
+	if v := udh.Bytes(); !bytes.Equal(want, v) {
+		t.Fatalf("unexpected bytes: want %x, have %x", want, v)
+	}
+	if v := udh.IE[2].IELength; v != 1 {
+		t.Fatalf("unexpected IE length: want 1, have %d", v)
+	}
+	concatenated, ref, total, part := udh.IsConcatenated()
+	if !concatenated || ref != 0x4142 || total != 3 || part != 1 {
+		t.Fatalf("unexpected concatenation: have %t %d %d %d", concatenated, ref, total, part)
+	}
+}
+
+func TestUDHConcatenationIEs(t *testing.T) {
+	ie8 := UDHIE{IEI: UDHIEIConcatenatedShortMessage8Bit, IELength: 3, IEData: []byte{0x42, 3, 2}}
+	ie16 := UDHIE{IEI: UDHIEIConcatenatedShortMessage16Bit, IELength: 4, IEData: []byte{0x41, 0x42, 3, 2}}
+	port := NewIEApplicationPort16Bit(2948, 9200)
+	test := []struct {
+		name          string
+		ie            []UDHIE
+		concatenated  bool
+		ref, tot, prt int
+		valid         bool
+	}{
+		{"none", []UDHIE{port}, false, 0, 1, 1, true},
+		{"8-bit", []UDHIE{port, ie8}, true, 0x42, 3, 2, true},
+		{"16-bit", []UDHIE{ie16}, true, 0x4142, 3, 2, true},
+		{"both", []UDHIE{ie8, ie16}, true, 0x4142, 3, 2, true},
+		{"8-bit too long", []UDHIE{{IEI: UDHIEIConcatenatedShortMessage8Bit, IELength: 4, IEData: []byte{0x41, 0x42, 3, 2}}}, false, 0, 1, 1, false},
+		{"16-bit too short", []UDHIE{{IEI: UDHIEIConcatenatedShortMessage16Bit, IELength: 3, IEData: []byte{0x42, 3, 2}}, ie8}, true, 0x42, 3, 2, false},
+		{"truncated", []UDHIE{{IEI: UDHIEIConcatenatedShortMessage8Bit, IELength: 3, IEData: []byte{0x42}}}, false, 0, 1, 1, false},
+		{"part zero", []UDHIE{{IEI: UDHIEIConcatenatedShortMessage8Bit, IELength: 3, IEData: []byte{0x42, 3, 0}}}, true, 0x42, 3, 0, false},
+		{"part over total", []UDHIE{{IEI: UDHIEIConcatenatedShortMessage8Bit, IELength: 3, IEData: []byte{0x42, 3, 4}}}, true, 0x42, 3, 4, false},
+		{"disagree", []UDHIE{ie8, {IEI: UDHIEIConcatenatedShortMessage16Bit, IELength: 4, IEData: []byte{0x41, 0x42, 3, 1}}}, true, 0x4142, 3, 1, false},
+	}
+	for _, tc := range test {
+		udh := &UDH{IE: tc.ie}
+		concatenated, ref, total, part := udh.IsConcatenated()
+		if concatenated != tc.concatenated || ref != tc.ref || total != tc.tot || part != tc.prt {
+			t.Fatalf("%s: unexpected concatenation: want %t %d %d %d, have %t %d %d %d", tc.name,
+				tc.concatenated, tc.ref, tc.tot, tc.prt, concatenated, ref, total, part)
+		}
+		if err := udh.Validate(); (err == nil) != tc.valid {
+			t.Fatalf("%s: unexpected validation: want valid %t, have %v", tc.name, tc.valid, err)
+		}
+	}
+}
@@ -0,0 +1,23 @@
+// Copyright 2015 go-smpp authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package pdutext
+
+// Binary codec, for 8-bit binary data such as WAP push.
+type Binary []byte
+
+// Type implements the Codec interface.
+func (s Binary) Type() DataCoding {
+	return Binary2Type
+}
+
+// Encode binary data, no encoding.
+func (s Binary) Encode() []byte {
+	return s
+}
+
+// Decode binary data, no decoding.
+func (s Binary) Decode() []byte {
+	return s
+}
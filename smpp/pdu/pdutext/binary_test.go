@@ -0,0 +1,24 @@
+// Copyright 2015 go-smpp authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package pdutext
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestBinaryEncoder(t *testing.T) {
+	want := []byte{0x01, 0x06, 0x01, 0xae}
+	s := Binary(want)
+	if s.Type() != 0x04 {
+		t.Fatalf("Unexpected data type; want 0x04, have %d", s.Type())
+	}
+	if have := s.Encode(); !bytes.Equal(want, have) {
+		t.Fatalf("Unexpected data; want %q, have %q", want, have)
+	}
+	if have := s.Decode(); !bytes.Equal(want, have) {
+		t.Fatalf("Unexpected data; want %q, have %q", want, have)
+	}
+}
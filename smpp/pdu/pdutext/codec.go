@@ -13,7 +13,7 @@ const (
 	//	IA5Type       DataCoding = 0x01 // IA5 (CCITT T.50)/ASCII (ANSI X3.4)
 	//	BinaryType    DataCoding = 0x02 // Octet unspecified (8-bit binary)
 	Latin1Type DataCoding = 0x03 // Latin 1 (ISO-8859-1)
-	//	Binary2Type   DataCoding = 0x04 // Octet unspecified (8-bit binary)
+	Binary2Type DataCoding = 0x04 // Octet unspecified (8-bit binary)
 	//	JISType       DataCoding = 0x05 // JIS (X 0208-1990)
 	ISO88595Type DataCoding = 0x06 // Cyrillic (ISO-8859-5)
 	//	ISO88598Type  DataCoding = 0x07 // Latin/Hebrew (ISO-8859-8)
@@ -0,0 +1,126 @@
+// Copyright 2015 go-smpp authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package pdutext
+
+// MessageClass is the GSM message class set in data_coding, see
+// 3GPP TS 23.038 section 4. The zero value means no message class.
+type MessageClass uint8
+
+// Supported message classes.
+const (
+	NoClass MessageClass = iota
+	Class0               // Flash message, displayed and not stored.
+	Class1               // ME specific.
+	Class2               // SIM specific.
+	Class3               // TE specific.
+)
+
+// MWIType is the kind of message waiting indication.
+type MWIType uint8
+
+// Supported message waiting indication types.
+const (
+	MWIVoicemail MWIType = 0x00
+	MWIFax       MWIType = 0x01
+	MWIEmail     MWIType = 0x02
+	MWIOther     MWIType = 0x03
+)
+
+// MWI is a message waiting indication set in data_coding.
+type MWI struct {
+	Type   MWIType
+	Active bool // Set the indication, or clear it when false.
+	Store  bool // Store the message, or discard it when false.
+}
+
+// WithClass returns the data_coding for the alphabet c with the message
+// class bits set. Only DefaultType, Binary2Type and UCS2Type have a
+// message class form, other values are returned unchanged.
+func (c DataCoding) WithClass(class MessageClass) DataCoding {
+	if class == NoClass {
+		return c
+	}
+	switch c {
+	case DefaultType, Binary2Type, UCS2Type:
+		return 0x10 | c | DataCoding(class-1)
+	}
+	return c
+}
+
+// BuildDataCoding returns the data_coding of text encoded with c, with
+// the message class bits set and the compressed bit if compressed, see
+// 3GPP TS 23.038 section 4. Only DefaultType, Binary2Type and UCS2Type
+// have a message class and compression form, other types are returned
+// unchanged.
+func BuildDataCoding(c Codec, class MessageClass, compressed bool) DataCoding {
+	dc := c.Type().WithClass(class)
+	if !compressed {
+		return dc
+	}
+	switch c.Type() {
+	case DefaultType, Binary2Type, UCS2Type:
+		return 0x20 | dc
+	}
+	return dc
+}
+
+// Compressed reports whether the compressed bit of c is set, for the
+// general data coding groups 0x00 to 0x3F.
+func (c DataCoding) Compressed() bool {
+	return c < 0x40 && c&0x20 != 0
+}
+
+// WithMWI returns the data_coding for the alphabet c with the message
+// waiting indication m. Only DefaultType, and UCS2Type when the message
+// is stored, have a message waiting form, other values are returned
+// unchanged.
+func (c DataCoding) WithMWI(m MWI) DataCoding {
+	var group DataCoding
+	switch {
+	case c == DefaultType && !m.Store:
+		group = 0xC0
+	case c == DefaultType:
+		group = 0xD0
+	case c == UCS2Type && m.Store:
+		group = 0xE0
+	default:
+		return c
+	}
+	if m.Active {
+		group |= 0x08
+	}
+	return group | DataCoding(m.Type&0x03)
+}
+
+// MWI returns the message waiting indication of c, and false if c is not
+// in one of the message waiting indication groups 0xC0 to 0xEF.
+func (c DataCoding) MWI() (MWI, bool) {
+	if c < 0xC0 || c >= 0xF0 {
+		return MWI{}, false
+	}
+	return MWI{
+		Type:   MWIType(c & 0x03),
+		Active: c&0x08 != 0,
+		Store:  c >= 0xD0,
+	}, true
+}
+
+// Alphabet returns the base coding of c, with the message class and
+// message waiting indication bits masked, e.g. DefaultType for 0x10.
+func (c DataCoding) Alphabet() DataCoding {
+	switch {
+	case c < 0x10:
+		return c
+	case c < 0x40:
+		return c & 0x0C
+	case c >= 0xC0 && c < 0xE0:
+		return DefaultType
+	case c >= 0xE0 && c < 0xF0:
+		return UCS2Type
+	case c >= 0xF0:
+		return c & 0x04
+	}
+	return c
+}
@@ -0,0 +1,129 @@
+// Copyright 2015 go-smpp authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package pdutext
+
+import "testing"
+
+func TestDataCodingWithClass(t *testing.T) {
+	test := []struct {
+		c     DataCoding
+		class MessageClass
+		want  DataCoding
+	}{
+		{DefaultType, NoClass, 0x00},
+		{DefaultType, Class0, 0x10},
+		{DefaultType, Class2, 0x12},
+		{Binary2Type, Class1, 0x15},
+		{UCS2Type, Class0, 0x18},
+		{UCS2Type, Class3, 0x1B},
+		{Latin1Type, Class0, Latin1Type},
+	}
+	for _, tc := range test {
+		if have := tc.c.WithClass(tc.class); have != tc.want {
+			t.Fatalf("unexpected data coding for %#x class %d: want %#x, have %#x", tc.c, tc.class, tc.want, have)
+		}
+		if have := tc.want.Alphabet(); have != tc.c {
+			t.Fatalf("unexpected alphabet for %#x: want %#x, have %#x", tc.want, tc.c, have)
+		}
+	}
+}
+
+func TestBuildDataCoding(t *testing.T) {
+	test := []struct {
+		c          Codec
+		class      MessageClass
+		compressed bool
+		want       DataCoding
+	}{
+		{GSM7("foo"), Class0, false, 0x10},
+		{UCS2("foo"), Class1, false, 0x19},
+		{GSM7("foo"), NoClass, false, DefaultType},
+		{UCS2("foo"), NoClass, false, UCS2Type},
+		{Latin1("foo"), Class0, false, Latin1Type},
+		{GSM7("foo"), NoClass, true, 0x20},
+		{UCS2("foo"), Class1, true, 0x39},
+		{Latin1("foo"), NoClass, true, Latin1Type},
+	}
+	for _, tc := range test {
+		have := BuildDataCoding(tc.c, tc.class, tc.compressed)
+		if have != tc.want {
+			t.Fatalf("unexpected data coding for %#x class %d compressed %t: want %#x, have %#x",
+				tc.c.Type(), tc.class, tc.compressed, tc.want, have)
+		}
+		if have.Compressed() != (tc.want&0x20 != 0) {
+			t.Fatalf("unexpected compressed bit for %#x: %t", have, have.Compressed())
+		}
+		if a := have.Alphabet(); a != tc.c.Type() {
+			t.Fatalf("unexpected alphabet for %#x: want %#x, have %#x", have, tc.c.Type(), a)
+		}
+	}
+}
+
+func TestDataCodingWithMWI(t *testing.T) {
+	test := []struct {
+		c    DataCoding
+		m    MWI
+		want DataCoding
+	}{
+		{DefaultType, MWI{Type: MWIVoicemail, Active: true}, 0xC8},
+		{DefaultType, MWI{Type: MWIFax, Store: true}, 0xD1},
+		{UCS2Type, MWI{Type: MWIEmail, Active: true, Store: true}, 0xEA},
+		{UCS2Type, MWI{Type: MWIEmail, Active: true}, UCS2Type},
+		{Latin1Type, MWI{Active: true, Store: true}, Latin1Type},
+	}
+	for _, tc := range test {
+		if have := tc.c.WithMWI(tc.m); have != tc.want {
+			t.Fatalf("unexpected data coding for %#x %+v: want %#x, have %#x", tc.c, tc.m, tc.want, have)
+		}
+		if have := tc.want.Alphabet(); have != tc.c {
+			t.Fatalf("unexpected alphabet for %#x: want %#x, have %#x", tc.want, tc.c, have)
+		}
+	}
+}
+
+func TestDataCodingMWI(t *testing.T) {
+	test := []struct {
+		name string
+		c    DataCoding
+		want MWI
+	}{
+		{"set voicemail, discard", 0xC8, MWI{Type: MWIVoicemail, Active: true}},
+		{"clear voicemail, discard", 0xC0, MWI{Type: MWIVoicemail}},
+		{"set voicemail, store", 0xD8, MWI{Type: MWIVoicemail, Active: true, Store: true}},
+		{"clear voicemail, store", 0xD0, MWI{Type: MWIVoicemail, Store: true}},
+		{"set email, store UCS2", 0xEA, MWI{Type: MWIEmail, Active: true, Store: true}},
+	}
+	for _, tc := range test {
+		have, ok := tc.c.MWI()
+		if !ok || have != tc.want {
+			t.Fatalf("%s: unexpected MWI for %#x: want %+v, have %+v (%t)", tc.name, tc.c, tc.want, have, ok)
+		}
+		alphabet := DefaultType
+		if tc.c >= 0xE0 {
+			alphabet = UCS2Type
+		}
+		if v := alphabet.WithMWI(tc.want); v != tc.c {
+			t.Fatalf("%s: unexpected data coding: want %#x, have %#x", tc.name, tc.c, v)
+		}
+	}
+	if _, ok := UCS2Type.WithClass(Class0).MWI(); ok {
+		t.Fatal("unexpected MWI for class 0 data coding")
+	}
+}
+
+func TestDataCodingAlphabet(t *testing.T) {
+	test := map[DataCoding]DataCoding{
+		0x03: Latin1Type,
+		0x08: UCS2Type,
+		0x30: DefaultType,
+		0xF1: DefaultType,
+		0xF6: Binary2Type,
+	}
+	for c, want := range test {
+		if have := c.Alphabet(); have != want {
+			t.Fatalf("unexpected alphabet for %#x: want %#x, have %#x", c, want, have)
+		}
+	}
+}
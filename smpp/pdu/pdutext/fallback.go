@@ -0,0 +1,51 @@
+// Copyright 2015 go-smpp authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package pdutext
+
+// Fallback returns c if it can encode its whole text, or else the same
+// text with the first codec of chain that can, as reported by
+// Unencodable, e.g. Fallback(GSM7(text), UCS2Type). GSM 7-bit text
+// falls back only for characters of neither the default alphabet nor
+// its extension table. Codecs of chain are given by their data_coding,
+// one of DefaultType for GSM7, Latin1Type, ISO88595Type or UCS2Type,
+// others being skipped. c is returned if no codec of chain can encode
+// the text either.
+func Fallback(c Codec, chain ...DataCoding) Codec {
+	if len(Unencodable(c)) == 0 {
+		return c
+	}
+	var text string
+	switch v := c.(type) {
+	case GSM7:
+		text = string(v)
+	case GSM7Packed:
+		text = string(v)
+	case Latin1:
+		text = string(v)
+	case ISO88595:
+		text = string(v)
+	case UCS2:
+		text = string(v)
+	}
+	for _, dc := range chain {
+		var f Codec
+		switch dc {
+		case DefaultType:
+			f = GSM7(text)
+		case Latin1Type:
+			f = Latin1(text)
+		case ISO88595Type:
+			f = ISO88595(text)
+		case UCS2Type:
+			f = UCS2(text)
+		default:
+			continue
+		}
+		if len(Unencodable(f)) == 0 {
+			return f
+		}
+	}
+	return c
+}
@@ -0,0 +1,32 @@
+// Copyright 2015 go-smpp authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package pdutext
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFallback(t *testing.T) {
+	test := []struct {
+		c     Codec
+		chain []DataCoding
+		want  Codec
+	}{
+		{GSM7("Hello [world] €"), []DataCoding{UCS2Type}, GSM7("Hello [world] €")},
+		{GSM7("Hello world 😀"), []DataCoding{UCS2Type}, UCS2("Hello world 😀")},
+		{GSM7Packed("Привет"), []DataCoding{Latin1Type, ISO88595Type, UCS2Type}, ISO88595("Привет")},
+		{Latin1("Ölçü"), []DataCoding{UCS2Type}, Latin1("Ölçü")},
+		{GSM7("Ölçü"), []DataCoding{Binary2Type, Latin1Type}, Latin1("Ölçü")},
+		{GSM7("Hello world 😀"), []DataCoding{Latin1Type}, GSM7("Hello world 😀")},
+		{GSM7("Hello world 😀"), nil, GSM7("Hello world 😀")},
+		{Raw("\xff\xfe"), []DataCoding{UCS2Type}, Raw("\xff\xfe")},
+	}
+	for _, tc := range test {
+		if have := Fallback(tc.c, tc.chain...); !reflect.DeepEqual(have, tc.want) {
+			t.Fatalf("unexpected fallback of %T %q: want %T %q, have %T %q", tc.c, tc.c, tc.want, tc.want, have, have)
+		}
+	}
+}
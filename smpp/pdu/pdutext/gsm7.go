@@ -10,6 +10,9 @@ import (
 )
 
 // GSM 7-bit (unpacked)
+//
+// Each septet takes one octet, so sm_length is the number of septets,
+// escaped characters of the extension table counting for two.
 type GSM7 []byte
 
 // Type implements the Codec interface.
@@ -36,3 +39,25 @@ func (s GSM7) Decode() []byte {
 	}
 	return es
 }
+
+// Septets is text mapped to the GSM 7-bit default alphabet, one septet
+// per octet. It is mapped once by EncodeSeptets and can then be sent
+// unpacked, e.g. in message_payload, or packed in short_message.
+type Septets []byte
+
+// EncodeSeptets maps text to GSM 7-bit septets. It returns an error if
+// text has characters that can not be represented in GSM 7-bit.
+func EncodeSeptets(text []byte) (Septets, error) {
+	return encoding.Septets(text)
+}
+
+// Unpacked returns the septets as GSM7 encodes them, one per octet.
+func (s Septets) Unpacked() []byte {
+	return s
+}
+
+// Packed returns the septets as GSM7Packed encodes them, 8 septets per
+// 7 octets.
+func (s Septets) Packed() []byte {
+	return encoding.PackSeptets(s)
+}
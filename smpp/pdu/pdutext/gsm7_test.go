@@ -53,3 +53,27 @@ func TestGSM7Length(t *testing.T) {
 		}
 	}
 }
+
+func TestEncodeSeptets(t *testing.T) {
+	for _, text := range []string{
+		"",
+		"Hello world",
+		"Hello world!",
+		"@£$¥ èé {curly} [square] ~tilde~ 5€",
+		"12345678",
+	} {
+		s, err := EncodeSeptets([]byte(text))
+		if err != nil {
+			t.Fatalf("%q: %v", text, err)
+		}
+		if want := GSM7(text).Encode(); !bytes.Equal(want, s.Unpacked()) {
+			t.Fatalf("unexpected unpacked %q; want %x, have %x", text, want, s.Unpacked())
+		}
+		if want := GSM7Packed(text).Encode(); !bytes.Equal(want, s.Packed()) {
+			t.Fatalf("unexpected packed %q; want %x, have %x", text, want, s.Packed())
+		}
+	}
+	if _, err := EncodeSeptets([]byte("日本")); err == nil {
+		t.Fatal("unexpected nil error for non GSM 7-bit text")
+	}
+}
@@ -10,6 +10,9 @@ import (
 )
 
 // GSM 7-bit (packed)
+//
+// Septets are packed 8 per 7 octets, so sm_length is the number of
+// octets, the septets times 7/8 rounded up.
 type GSM7Packed []byte
 
 // Type implements the Codec interface.
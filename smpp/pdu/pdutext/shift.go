@@ -0,0 +1,73 @@
+// Copyright 2015 go-smpp authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package pdutext
+
+// NationalLanguage identifies a GSM 7-bit national language shift
+// table, see 3GPP TS 23.038 section 6.2.1.2.4.
+type NationalLanguage uint8
+
+// Supported national language identifiers.
+const (
+	DefaultLanguage NationalLanguage = 0x00 // No shift table
+	Turkish         NationalLanguage = 0x01
+	Spanish         NationalLanguage = 0x02
+	Portuguese      NationalLanguage = 0x03
+	Bengali         NationalLanguage = 0x04
+	Gujarati        NationalLanguage = 0x05
+	Hindi           NationalLanguage = 0x06
+	Kannada         NationalLanguage = 0x07
+	Malayalam       NationalLanguage = 0x08
+	Oriya           NationalLanguage = 0x09
+	Punjabi         NationalLanguage = 0x0A
+	Tamil           NationalLanguage = 0x0B
+	Telugu          NationalLanguage = 0x0C
+	Urdu            NationalLanguage = 0x0D
+)
+
+// ShiftTables holds the national language tables in use for a GSM 7-bit
+// message. A DefaultLanguage entry means the table is not in use.
+type ShiftTables struct {
+	Locking NationalLanguage // National language locking shift table
+	Single  NationalLanguage // National language single shift table
+}
+
+// UDHLen returns the number of User Data Header octets taken by the
+// national language IEs, not including the UDH length octet.
+func (s ShiftTables) UDHLen() int {
+	l := 0
+	if s.Locking != DefaultLanguage {
+		l += 3 // IEI, IE length, language
+	}
+	if s.Single != DefaultLanguage {
+		l += 3
+	}
+	return l
+}
+
+// MaxGSM7WithShiftTable returns the maximum number of septets available
+// for the text of a single GSM 7-bit message part, given the shift tables
+// in use and whether the part carries a concatenation IE.
+//
+// Each national language IE takes 3 octets of User Data Header, and the
+// header is padded to a septet boundary. Escaped characters count as two
+// septets against this budget.
+func MaxGSM7WithShiftTable(s ShiftTables, concatenated bool) int {
+	udh := s.UDHLen()
+	if concatenated {
+		udh += 6 // IE with 2 byte reference number
+	}
+	return MaxGSM7WithUDH(udh)
+}
+
+// MaxGSM7WithUDH returns the maximum number of GSM 7-bit septets that
+// fit in a short message along with a UDH of udhLen octets, not counting
+// the UDH length octet.
+func MaxGSM7WithUDH(udhLen int) int {
+	if udhLen == 0 {
+		return MaxGSM7ShortMessageLenEncoded
+	}
+	// 140 octets of user data, minus the UDH and its length octet.
+	return (140 - udhLen - 1) * 8 / 7
+}
@@ -0,0 +1,28 @@
+// Copyright 2015 go-smpp authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package pdutext
+
+import "testing"
+
+func TestMaxGSM7WithShiftTable(t *testing.T) {
+	tests := []struct {
+		tables       ShiftTables
+		concatenated bool
+		want         int
+	}{
+		{ShiftTables{}, false, 160},
+		{ShiftTables{}, true, MaxGSM7ConcatenatedShortMessageLenEncoded},
+		{ShiftTables{Single: Turkish}, false, 155},
+		{ShiftTables{Single: Turkish}, true, 148},
+		{ShiftTables{Locking: Turkish, Single: Turkish}, false, 152},
+		{ShiftTables{Locking: Turkish, Single: Turkish}, true, 145},
+	}
+	for _, tc := range tests {
+		if have := MaxGSM7WithShiftTable(tc.tables, tc.concatenated); have != tc.want {
+			t.Fatalf("unexpected budget for %+v (concatenated=%t): want %d, have %d",
+				tc.tables, tc.concatenated, tc.want, have)
+		}
+	}
+}
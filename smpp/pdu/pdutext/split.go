@@ -0,0 +1,123 @@
+// Copyright 2015 go-smpp authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package pdutext
+
+import (
+	"errors"
+
+	"github.com/florentchauveau/go-smpp/smpp/encoding"
+)
+
+// ErrUDHTooLong is returned by SplitPayloads when the UDH leaves less
+// than 2 septets or octets of text per part, or 4 octets with UCS2.
+var ErrUDHTooLong = errors.New("UDH leaves no room for text")
+
+// Part is a part of a concatenated short message.
+type Part struct {
+	UDH  []byte // User data header, starting with its length octet.
+	Text []byte // Encoded text of the part.
+}
+
+// Bytes returns the short_message of the part, the UDH followed by the
+// text.
+func (p Part) Bytes() []byte {
+	b := make([]byte, 0, len(p.UDH)+len(p.Text))
+	b = append(b, p.UDH...)
+	return append(b, p.Text...)
+}
+
+// Split splits text encoded with c into the parts of a concatenated
+// message with reference ref, each with its concatenation UDH. The
+// UDH uses an 8-bit reference if ref fits in one octet. The esm_class
+// of the submits must have the UDH indicator set.
+func Split(encoded []byte, c Codec, ref uint16) []Part {
+	payloads, _ := SplitPayloads(encoded, c, 6) // always leaves room
+	parts := make([]Part, len(payloads))
+	for i, text := range payloads {
+		var udh []byte
+		if ref > 0xFF {
+			udh = []byte{0x06, 0x08, 0x04, byte(ref >> 8), byte(ref), byte(len(payloads)), byte(i + 1)}
+		} else {
+			udh = []byte{0x05, 0x00, 0x03, byte(ref), byte(len(payloads)), byte(i + 1)}
+		}
+		parts[i] = Part{UDH: udh, Text: text}
+	}
+	return parts
+}
+
+// SplitPayloads splits text encoded with c into payloads that fit in a
+// short message along with a UDH of udhLen octets, not counting the UDH
+// length octet, e.g. 6 for a concatenation IE with a 16-bit reference.
+// GSM 7-bit payloads never split an escaped character of the extension
+// table, and UCS2 payloads never split a character or surrogate pair.
+// ErrUDHTooLong is returned if the UDH leaves too little room for text.
+func SplitPayloads(encoded []byte, c Codec, udhLen int) ([][]byte, error) {
+	minLen, maxLen := 2, 140-udhLen-1
+	switch c.(type) {
+	case GSM7:
+		maxLen = MaxGSM7WithUDH(udhLen)
+	case UCS2:
+		minLen, maxLen = 4, maxLen&^1
+	}
+	if maxLen < minLen {
+		return nil, ErrUDHTooLong
+	}
+	switch c.(type) {
+	case GSM7:
+		return splitGSM7(encoded, maxLen), nil
+	case UCS2:
+		return splitUCS2(encoded, maxLen), nil
+	}
+	return splitBytes(encoded, maxLen), nil
+}
+
+// splitBytes splits b into parts of at most maxLen bytes.
+func splitBytes(b []byte, maxLen int) [][]byte {
+	parts := make([][]byte, 0, (len(b)-1)/maxLen+1)
+	for len(b) > maxLen {
+		parts = append(parts, b[:maxLen])
+		b = b[maxLen:]
+	}
+	return append(parts, b)
+}
+
+// splitGSM7 splits GSM 7-bit (unpacked) encoded text into parts of at
+// most maxLen septets. A part is shortened by one septet when needed so
+// that escaped characters of the extension table are never split.
+func splitGSM7(b []byte, maxLen int) [][]byte {
+	var parts [][]byte
+	for len(b) > maxLen {
+		n := 0
+		for n < maxLen {
+			w := 1
+			if b[n] == encoding.EscapeSequence {
+				w = 2
+			}
+			if n+w > maxLen {
+				break
+			}
+			n += w
+		}
+		parts = append(parts, b[:n])
+		b = b[n:]
+	}
+	return append(parts, b)
+}
+
+// splitUCS2 splits UTF-16 big endian encoded text into parts of at
+// most maxLen bytes, maxLen being even. A part is shortened by one code
+// unit when needed so that surrogate pairs are never split.
+func splitUCS2(b []byte, maxLen int) [][]byte {
+	var parts [][]byte
+	for len(b) > maxLen {
+		n := maxLen
+		if hi := b[n-2]; hi >= 0xD8 && hi <= 0xDB { // high surrogate
+			n -= 2
+		}
+		parts = append(parts, b[:n])
+		b = b[n:]
+	}
+	return append(parts, b)
+}
@@ -0,0 +1,137 @@
+// Copyright 2015 go-smpp authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package pdutext
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/florentchauveau/go-smpp/smpp/encoding"
+)
+
+func TestSplitGSM7(t *testing.T) {
+	text := "a" + strings.Repeat("{}", 100)
+	raw := GSM7(text).Encode()
+	maxLen := MaxGSM7ConcatenatedShortMessageLenEncoded
+	parts := splitGSM7(raw, maxLen)
+	var decoded string
+	for i, part := range parts {
+		if len(part) > maxLen {
+			t.Fatalf("part %d: too long: %d > %d", i+1, len(part), maxLen)
+		}
+		if part[len(part)-1] == encoding.EscapeSequence {
+			t.Fatalf("part %d: ends with an escape", i+1)
+		}
+		decoded += string(GSM7(part).Decode())
+	}
+	if len(parts[0]) != maxLen-1 {
+		t.Fatalf("unexpected first part length: want %d, have %d", maxLen-1, len(parts[0]))
+	}
+	if decoded != text {
+		t.Fatalf("unexpected text:\nwant: %q\nhave: %q", text, decoded)
+	}
+}
+
+func TestSplitUCS2Surrogates(t *testing.T) {
+	text := "a" + strings.Repeat("😀", 100) // 2 octets, then 4 per emoji
+	raw := UCS2(text).Encode()
+	parts, err := SplitPayloads(raw, UCS2(text), 6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded string
+	for i, part := range parts {
+		if len(part) > MaxUCS2ConcatenatedShortMessageLenEncoded {
+			t.Fatalf("part %d: too long: %d > %d", i+1, len(part), MaxUCS2ConcatenatedShortMessageLenEncoded)
+		}
+		decoded += string(UCS2(part).Decode())
+	}
+	if len(parts[0]) != MaxUCS2ConcatenatedShortMessageLenEncoded-2 {
+		t.Fatalf("unexpected first part length: want %d, have %d", MaxUCS2ConcatenatedShortMessageLenEncoded-2, len(parts[0]))
+	}
+	if decoded != text {
+		t.Fatalf("unexpected text:\nwant: %q\nhave: %q", text, decoded)
+	}
+}
+
+func TestSplitPayloadsUDHTooLong(t *testing.T) {
+	test := []struct {
+		c      Codec
+		udhLen int
+	}{
+		{GSM7("{}{}"), 138},
+		{GSM7("{}{}"), 139},
+		{UCS2("foo"), 136},
+		{Latin1("foo"), 138},
+		{Raw("foo"), 139},
+		{Raw("foo"), 200},
+	}
+	for _, tc := range test {
+		if _, err := SplitPayloads(tc.c.Encode(), tc.c, tc.udhLen); err != ErrUDHTooLong {
+			t.Fatalf("unexpected error for %T with a UDH of %d octets: %v", tc.c, tc.udhLen, err)
+		}
+	}
+	for _, tc := range []struct {
+		c      Codec
+		udhLen int
+	}{
+		{GSM7("{}{}"), 137},
+		{UCS2("foo"), 135},
+		{Latin1("foo"), 137},
+	} {
+		parts, err := SplitPayloads(tc.c.Encode(), tc.c, tc.udhLen)
+		if err != nil {
+			t.Fatalf("unexpected error for %T with a UDH of %d octets: %v", tc.c, tc.udhLen, err)
+		}
+		var joined []byte
+		for _, part := range parts {
+			joined = append(joined, part...)
+		}
+		if !bytes.Equal(joined, tc.c.Encode()) {
+			t.Fatalf("unexpected parts of %T: %x", tc.c, parts)
+		}
+	}
+}
+
+func TestSplit(t *testing.T) {
+	text := GSM7("Lorem ipsum dolor sit amet, consectetur adipiscing elit. Nam consequat nisl enim, vel finibus neque aliquet sit amet. Interdum et malesuada fames ac ante ipsum primis in faucibus.")
+	raw := text.Encode()
+	test := []struct {
+		ref  uint16
+		udh  []byte // without part number
+		lens []int
+	}{
+		{0x4142, []byte{0x06, 0x08, 0x04, 0x41, 0x42, 0x02}, []int{152, len(raw) - 152}},
+		{0x42, []byte{0x05, 0x00, 0x03, 0x42, 0x02}, []int{152, len(raw) - 152}},
+	}
+	for _, tc := range test {
+		parts := Split(raw, text, tc.ref)
+		if len(parts) != len(tc.lens) {
+			t.Fatalf("unexpected number of parts: want %d, have %d", len(tc.lens), len(parts))
+		}
+		var joined []byte
+		for i, part := range parts {
+			udh := append(append([]byte(nil), tc.udh...), byte(i+1))
+			if !bytes.Equal(part.UDH, udh) {
+				t.Fatalf("part %d: unexpected UDH: want %x, have %x", i+1, udh, part.UDH)
+			}
+			if len(part.Text) != tc.lens[i] {
+				t.Fatalf("part %d: unexpected length: want %d, have %d", i+1, tc.lens[i], len(part.Text))
+			}
+			if b := part.Bytes(); !bytes.Equal(b, append(udh, part.Text...)) {
+				t.Fatalf("part %d: unexpected short message: %x", i+1, b)
+			}
+			joined = append(joined, part.Text...)
+		}
+		if !bytes.Equal(joined, raw) {
+			t.Fatalf("unexpected joined parts:\nwant: %q\nhave: %q", raw, joined)
+		}
+	}
+	parts := Split(Latin1("foo").Encode(), Latin1("foo"), 0x42)
+	if len(parts) != 1 || string(parts[0].Text) != "foo" {
+		t.Fatalf("unexpected parts: %+v", parts)
+	}
+}
@@ -17,7 +17,7 @@ func (s UCS2) Type() DataCoding {
 	return UCS2Type
 }
 
-// Encode to UCS2.
+// Encode to UCS2, big-endian without byte order mark.
 func (s UCS2) Encode() []byte {
 	e := unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM)
 	es, _, err := transform.Bytes(e.NewEncoder(), s)
@@ -27,9 +27,10 @@ func (s UCS2) Encode() []byte {
 	return es
 }
 
-// Decode from UCS2.
+// Decode from UCS2. A leading byte order mark, 0xFEFF or 0xFFFE, is
+// stripped and selects the byte order, big-endian otherwise.
 func (s UCS2) Decode() []byte {
-	e := unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM)
+	e := unicode.UTF16(unicode.BigEndian, unicode.UseBOM)
 	es, _, err := transform.Bytes(e.NewDecoder(), s)
 	if err != nil {
 		return s
@@ -34,3 +34,21 @@ func TestUCS2Decoder(t *testing.T) {
 		t.Fatalf("Unexpected text; want %q, have %q", want, have)
 	}
 }
+
+func TestUCS2DecoderBOM(t *testing.T) {
+	want := []byte("Olá mundão")
+	test := []struct {
+		name string
+		text []byte
+	}{
+		{"big-endian", []byte("\x00O\x00l\x00\xe1\x00 \x00m\x00u\x00n\x00d\x00\xe3\x00o")},
+		{"big-endian with BOM", []byte("\xfe\xff\x00O\x00l\x00\xe1\x00 \x00m\x00u\x00n\x00d\x00\xe3\x00o")},
+		{"little-endian with BOM", []byte("\xff\xfeO\x00l\x00\xe1\x00 \x00m\x00u\x00n\x00d\x00\xe3\x00o\x00")},
+	}
+	for _, tc := range test {
+		have := UCS2(tc.text).Decode()
+		if !bytes.Equal(want, have) {
+			t.Fatalf("%s: unexpected text; want %q, have %q", tc.name, want, have)
+		}
+	}
+}
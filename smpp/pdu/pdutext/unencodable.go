@@ -0,0 +1,55 @@
+// Copyright 2015 go-smpp authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package pdutext
+
+import (
+	"slices"
+	"unicode/utf8"
+
+	"github.com/florentchauveau/go-smpp/smpp/encoding"
+	"golang.org/x/text/encoding/charmap"
+)
+
+// Unencodable returns the characters of the text of c that c can not
+// encode, each once in order of appearance, or nil if the whole text
+// can be encoded. Invalid UTF-8 is reported as utf8.RuneError. Raw and
+// Binary hold octets, and encode anything.
+func Unencodable(c Codec) []rune {
+	switch v := c.(type) {
+	case GSM7:
+		return unencodable(string(v), func(r rune) bool {
+			return len(encoding.ValidateGSM7String(string(r))) == 0
+		})
+	case GSM7Packed:
+		return Unencodable(GSM7(v))
+	case Latin1:
+		return unencodable(string(v), func(r rune) bool {
+			_, ok := charmap.Windows1252.EncodeRune(r)
+			return ok
+		})
+	case ISO88595:
+		return unencodable(string(v), func(r rune) bool {
+			_, ok := charmap.ISO8859_5.EncodeRune(r)
+			return ok
+		})
+	case UCS2:
+		return unencodable(string(v), func(r rune) bool { return true })
+	}
+	return nil
+}
+
+// unencodable returns the characters of text for which encodable
+// returns false, and invalid UTF-8, each once.
+func unencodable(text string, encodable func(r rune) bool) []rune {
+	var runes []rune
+	for i, r := range text {
+		_, n := utf8.DecodeRuneInString(text[i:])
+		invalid := r == utf8.RuneError && n == 1
+		if (invalid || !encodable(r)) && !slices.Contains(runes, r) {
+			runes = append(runes, r)
+		}
+	}
+	return runes
+}
@@ -0,0 +1,35 @@
+// Copyright 2015 go-smpp authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package pdutext
+
+import (
+	"testing"
+	"unicode/utf8"
+)
+
+func TestUnencodable(t *testing.T) {
+	test := []struct {
+		c    Codec
+		want string
+	}{
+		{GSM7("Hello [world] €"), ""},
+		{GSM7("Ça va? ça, çà ☺"), "ç☺"},
+		{GSM7Packed("Ölçü"), "ç"},
+		{Latin1("café €"), ""},
+		{Latin1("Привет"), "Привет"},
+		{ISO88595("Привет, world"), ""},
+		{ISO88595("Привет ☺☺"), "☺"},
+		{UCS2("世界 😀"), ""},
+		{UCS2("a\xffb\xfe"), string(utf8.RuneError)},
+		{Raw("\xff\xfe"), ""},
+		{Binary("\xff\xfe"), ""},
+		{nil, ""},
+	}
+	for _, tc := range test {
+		if have := string(Unencodable(tc.c)); have != tc.want {
+			t.Fatalf("unexpected unencodable characters of %T %q: want %q, have %q", tc.c, tc.c, tc.want, have)
+		}
+	}
+}
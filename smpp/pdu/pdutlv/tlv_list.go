@@ -11,6 +11,8 @@ import (
 )
 
 // DecodeTLV scans the given byte slice to build a Map from binary data.
+// All tags are kept, including unknown and vendor specific ones, as raw
+// Field values that serialize back to the same bytes.
 func DecodeTLV(r *bytes.Buffer) (Map, error) {
 	t := make(Map)
 	for r.Len() >= 4 {
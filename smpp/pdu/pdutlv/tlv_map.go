@@ -5,7 +5,9 @@
 package pdutlv
 
 import (
+	"encoding/binary"
 	"fmt"
+	"reflect"
 )
 
 // Map is a collection of PDU TLV field data indexed by tag.
@@ -15,7 +17,11 @@ type Map map[Tag]Body
 // returns error if the value cannot be converted to type Data.
 //
 // This is a shortcut for m[t] = NewTLV(t, v) converting v properly.
+// A nil pointer, e.g. an unset *CallbackNumAtag, is an error.
 func (m Map) Set(t Tag, v any) error {
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Pointer && rv.IsNil() {
+		return fmt.Errorf("nil Tag-Length-Value field data: %T", v)
+	}
 	switch v := v.(type) {
 	case nil:
 		m[t] = NewTLV(t, nil) // use default value
@@ -23,6 +29,8 @@ func (m Map) Set(t Tag, v any) error {
 		m[t] = NewTLV(t, []byte{v})
 	case int:
 		m[t] = NewTLV(t, []byte{uint8(v)})
+	case uint32:
+		m[t] = NewTLV(t, binary.BigEndian.AppendUint32(nil, v))
 	case string:
 		m[t] = NewTLV(t, []byte(v))
 	case String:
@@ -35,6 +43,34 @@ func (m Map) Set(t Tag, v any) error {
 		m[t] = NewTLV(t, value)
 	case []byte:
 		m[t] = NewTLV(t, []byte(v))
+	case PrivacyIndicator:
+		m[t] = NewTLV(t, []byte{uint8(v)})
+	case PayloadType:
+		m[t] = NewTLV(t, []byte{uint8(v)})
+	case ItsReplyType:
+		m[t] = NewTLV(t, []byte{uint8(v)})
+	case DpfResult:
+		m[t] = NewTLV(t, []byte{uint8(v)})
+	case DeliveryFailureReason:
+		m[t] = NewTLV(t, []byte{uint8(v)})
+	case NetworkType:
+		m[t] = NewTLV(t, []byte{uint8(v)})
+	case BearerType:
+		m[t] = NewTLV(t, []byte{uint8(v)})
+	case MsAvailabilityStatus:
+		m[t] = NewTLV(t, []byte{uint8(v)})
+	case ItsSessionInfo:
+		m[t] = NewTLV(t, v.Bytes())
+	case CallbackNum:
+		m[t] = NewTLV(t, v.Bytes())
+	case NetworkErrorCode:
+		m[t] = NewTLV(t, v.Bytes())
+	case CallbackNumPresInd:
+		m[t] = NewTLV(t, []byte{v.Byte()})
+	case CallbackNumAtag:
+		m[t] = NewTLV(t, v.Bytes())
+	case MsValidity:
+		m[t] = NewTLV(t, v.Bytes())
 	case Body:
 		m[t] = v
 	default:
@@ -42,3 +78,65 @@ func (m Map) Set(t Tag, v any) error {
 	}
 	return nil
 }
+
+// Uint8 returns the value of the single octet TLV t. It returns
+// false if the TLV is not present or is not one octet long.
+func (m Map) Uint8(t Tag) (uint8, bool) {
+	f, ok := m[t]
+	if !ok || f == nil {
+		return 0, false
+	}
+	b := f.Bytes()
+	if len(b) != 1 {
+		return 0, false
+	}
+	return b[0], true
+}
+
+// Uint16 returns the value of the two octet TLV t. It returns
+// false if the TLV is not present or is not two octets long.
+func (m Map) Uint16(t Tag) (uint16, bool) {
+	f, ok := m[t]
+	if !ok || f == nil {
+		return 0, false
+	}
+	b := f.Bytes()
+	if len(b) != 2 {
+		return 0, false
+	}
+	return binary.BigEndian.Uint16(b), true
+}
+
+// Uint32 returns the value of the four octet TLV t. It returns
+// false if the TLV is not present or is not four octets long.
+func (m Map) Uint32(t Tag) (uint32, bool) {
+	f, ok := m[t]
+	if !ok || f == nil {
+		return 0, false
+	}
+	b := f.Bytes()
+	if len(b) != 4 {
+		return 0, false
+	}
+	return binary.BigEndian.Uint32(b), true
+}
+
+// String returns the text of the TLV t, without the null terminator.
+// It returns false if the TLV is not present.
+func (m Map) String(t Tag) (string, bool) {
+	f, ok := m[t]
+	if !ok || f == nil {
+		return "", false
+	}
+	return f.String(), true
+}
+
+// Bytes returns the raw value of the TLV t. It returns false if the
+// TLV is not present.
+func (m Map) Bytes(t Tag) ([]byte, bool) {
+	f, ok := m[t]
+	if !ok || f == nil {
+		return nil, false
+	}
+	return f.Bytes(), true
+}
@@ -26,6 +26,8 @@ func TestMapSet(t *testing.T) {
 		{TagDestBearerType, CString("hello\x00"), true},
 		{TagDestBearerType, CString("hello"), true},
 		{TagDestBearerType, NewTLV(TagDestBearerType, []byte{0x03}), true},
+		{TagCallbackNumAtag, (*CallbackNumAtag)(nil), false},
+		{TagDestBearerType, (*Field)(nil), false},
 	}
 	for _, el := range test {
 		if err := m.Set(el.k, el.v); el.ok && err != nil {
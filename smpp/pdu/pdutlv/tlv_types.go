@@ -5,11 +5,17 @@
 package pdutlv
 
 import (
+	"bytes"
 	"encoding/binary"
 	"encoding/hex"
+	"fmt"
 	"io"
 )
 
+// MaxLen is the maximum length of a TLV value, encoded as a 16-bit
+// length like the tag.
+const MaxLen = 0xFFFF
+
 // Fields is a map of tagged TLV fields
 type Fields map[Tag]any
 
@@ -29,6 +35,17 @@ func (t Tag) Hex() string {
 	return hex.EncodeToString(bin)
 }
 
+// Range of the vendor specific tags, see SMPP 3.4 spec 5.3.2.
+const (
+	TagVendorMin Tag = 0x1400
+	TagVendorMax Tag = 0x3FFF
+)
+
+// IsVendor returns true if t is in the vendor specific range.
+func (t Tag) IsVendor() bool {
+	return t >= TagVendorMin && t <= TagVendorMax
+}
+
 // Common Tag-Length-Value (TLV) tags.
 const (
 	TagDestAddrSubunit          Tag = 0x0005
@@ -55,6 +72,7 @@ const (
 	TagLanguageIndicator        Tag = 0x020D
 	TagSarTotalSegments         Tag = 0x020E
 	TagSarSegmentSeqnum         Tag = 0x020F
+	TagScInterfaceVersion       Tag = 0x0210
 	TagCallbackNumPresInd       Tag = 0x0302
 	TagCallbackNumAtag          Tag = 0x0303
 	TagNumberOfMessages         Tag = 0x0304
@@ -67,6 +85,7 @@ const (
 	TagDeliveryFailureReason    Tag = 0x0425
 	TagMoreMessagesToSend       Tag = 0x0426
 	TagMessageStateOption       Tag = 0x0427
+	TagCongestionState          Tag = 0x0428
 	TagUssdServiceOp            Tag = 0x0501
 	TagDisplayTime              Tag = 0x1201
 	TagSmsSignal                Tag = 0x1203
@@ -76,6 +95,63 @@ const (
 	TagItsSessionInfo           Tag = 0x1383
 )
 
+// Name returns the SMPP name of the tag, e.g. message_payload, or its
+// hexadecimal value if unknown.
+func (t Tag) Name() string {
+	if n, ok := tagName[t]; ok {
+		return n
+	}
+	return fmt.Sprintf("0x%04x", uint16(t))
+}
+
+var tagName = map[Tag]string{
+	TagDestAddrSubunit:          "dest_addr_subunit",
+	TagDestNetworkType:          "dest_network_type",
+	TagDestBearerType:           "dest_bearer_type",
+	TagDestTelematicsID:         "dest_telematics_id",
+	TagSourceAddrSubunit:        "source_addr_subunit",
+	TagSourceNetworkType:        "source_network_type",
+	TagSourceBearerType:         "source_bearer_type",
+	TagSourceTelematicsID:       "source_telematics_id",
+	TagQosTimeToLive:            "qos_time_to_live",
+	TagPayloadType:              "payload_type",
+	TagAdditionalStatusInfoText: "additional_status_info_text",
+	TagReceiptedMessageID:       "receipted_message_id",
+	TagMsMsgWaitFacilities:      "ms_msg_wait_facilities",
+	TagPrivacyIndicator:         "privacy_indicator",
+	TagSourceSubaddress:         "source_subaddress",
+	TagDestSubaddress:           "dest_subaddress",
+	TagUserMessageReference:     "user_message_reference",
+	TagUserResponseCode:         "user_response_code",
+	TagSourcePort:               "source_port",
+	TagDestinationPort:          "destination_port",
+	TagSarMsgRefNum:             "sar_msg_ref_num",
+	TagLanguageIndicator:        "language_indicator",
+	TagSarTotalSegments:         "sar_total_segments",
+	TagSarSegmentSeqnum:         "sar_segment_seqnum",
+	TagScInterfaceVersion:       "sc_interface_version",
+	TagCallbackNumPresInd:       "callback_num_pres_ind",
+	TagCallbackNumAtag:          "callback_num_atag",
+	TagNumberOfMessages:         "number_of_messages",
+	TagCallbackNum:              "callback_num",
+	TagDpfResult:                "dpf_result",
+	TagSetDpf:                   "set_dpf",
+	TagMsAvailabilityStatus:     "ms_availability_status",
+	TagNetworkErrorCode:         "network_error_code",
+	TagMessagePayload:           "message_payload",
+	TagDeliveryFailureReason:    "delivery_failure_reason",
+	TagMoreMessagesToSend:       "more_messages_to_send",
+	TagMessageStateOption:       "message_state",
+	TagCongestionState:          "congestion_state",
+	TagUssdServiceOp:            "ussd_service_op",
+	TagDisplayTime:              "display_time",
+	TagSmsSignal:                "sms_signal",
+	TagMsValidity:               "ms_validity",
+	TagAlertOnMessageDelivery:   "alert_on_message_delivery",
+	TagItsReplyType:             "its_reply_type",
+	TagItsSessionInfo:           "its_session_info",
+}
+
 // Field is a PDU Tag-Length-Value (TLV) field
 type Field struct {
 	Tag  Tag
@@ -105,13 +181,24 @@ func (t *Field) Bytes() []byte {
 	return t.Data
 }
 
-// SerializeTo implements the Data interface.
+// SerializeTo implements the Data interface. It returns an error if
+// the value is longer than MaxLen.
 func (t *Field) SerializeTo(w io.Writer) error {
+	if len(t.Data) > MaxLen {
+		return fmt.Errorf("tlv %s too long: %d octets, max %d", t.Tag.Hex(), len(t.Data), MaxLen)
+	}
+	var hdr [4]byte
+	binary.BigEndian.PutUint16(hdr[0:2], uint16(t.Tag))
+	binary.BigEndian.PutUint16(hdr[2:4], uint16(len(t.Data)))
+	if bw, ok := w.(*bytes.Buffer); ok {
+		// Avoid copying Data when serializing a PDU.
+		bw.Write(hdr[:])
+		bw.Write(t.Data)
+		return nil
+	}
 	b := make([]byte, len(t.Data)+4)
-	binary.BigEndian.PutUint16(b[0:2], uint16(t.Tag))
-	binary.BigEndian.PutUint16(b[2:4], uint16(len(t.Data)))
+	copy(b, hdr[:])
 	copy(b[4:], t.Data)
-
 	_, err := w.Write(b)
 	return err
 }
@@ -7,6 +7,7 @@ package pdutlv
 import (
 	"testing"
 	"bytes"
+	"io"
 )
 
 func TestTag_Hex(t *testing.T) {
@@ -71,4 +72,10 @@ func TestTLVField_NullTerminated(t *testing.T) {
 	if v := b.Bytes(); !bytes.Equal(want, v) {
 		t.Fatalf("unexpected serialized bytes: want %q, have %q", want, v)
 	}
-}
\ No newline at end of file
+}
+func TestTLVField_TooLong(t *testing.T) {
+	f := &Field{Tag: TagMessagePayload, Data: make([]byte, MaxLen+1)}
+	if err := f.SerializeTo(io.Discard); err == nil {
+		t.Fatal("unexpected nil error for a value longer than MaxLen")
+	}
+}
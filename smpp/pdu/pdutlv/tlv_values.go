@@ -0,0 +1,491 @@
+// Copyright 2015 go-smpp authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package pdutlv
+
+import "time"
+
+// PrivacyIndicator is the value of the privacy_indicator TLV.
+type PrivacyIndicator uint8
+
+// Supported privacy indicators, see SMPP 3.4 spec 5.3.2.14.
+const (
+	PrivacyNotRestricted PrivacyIndicator = 0x00
+	PrivacyRestricted    PrivacyIndicator = 0x01
+	PrivacyConfidential  PrivacyIndicator = 0x02
+	PrivacySecret        PrivacyIndicator = 0x03
+)
+
+// PayloadType is the value of the payload_type TLV.
+type PayloadType uint8
+
+// Supported payload types, see SMPP 3.4 spec 5.3.2.10.
+const (
+	PayloadDefault PayloadType = 0x00 // WDP message, or GSM SMS
+	PayloadWCMP    PayloadType = 0x01 // Wireless Control Message Protocol
+)
+
+// NetworkType is the value of the source_network_type and
+// dest_network_type TLVs.
+type NetworkType uint8
+
+// Supported network types, see SMPP 3.4 spec 5.3.2.3.
+const (
+	NetworkUnknown NetworkType = 0x00
+	NetworkGSM     NetworkType = 0x01
+	NetworkTDMA    NetworkType = 0x02 // ANSI-136/TDMA
+	NetworkCDMA    NetworkType = 0x03 // IS-95/CDMA
+	NetworkPDC     NetworkType = 0x04
+	NetworkPHS     NetworkType = 0x05
+	NetworkIDEN    NetworkType = 0x06
+	NetworkAMPS    NetworkType = 0x07
+	NetworkPaging  NetworkType = 0x08
+)
+
+// BearerType is the value of the source_bearer_type and
+// dest_bearer_type TLVs.
+type BearerType uint8
+
+// Supported bearer types, see SMPP 3.4 spec 5.3.2.5.
+const (
+	BearerUnknown       BearerType = 0x00
+	BearerSMS           BearerType = 0x01
+	BearerCSD           BearerType = 0x02 // Circuit Switched Data
+	BearerPacketData    BearerType = 0x03
+	BearerUSSD          BearerType = 0x04
+	BearerCDPD          BearerType = 0x05
+	BearerDataTAC       BearerType = 0x06
+	BearerFLEX          BearerType = 0x07 // FLEX/ReFLEX
+	BearerCellBroadcast BearerType = 0x08
+)
+
+// MsAvailabilityStatus is the value of the ms_availability_status TLV
+// of alert_notification.
+type MsAvailabilityStatus uint8
+
+// Supported MS availability statuses, see SMPP 3.4 spec 5.3.2.30.
+const (
+	MsAvailable   MsAvailabilityStatus = 0x00
+	MsDenied      MsAvailabilityStatus = 0x01 // Suspended, no SMS capability.
+	MsUnavailable MsAvailabilityStatus = 0x02
+)
+
+// DpfResult is the value of the dpf_result TLV, indicating whether a
+// delivery pending flag was set by the SMSC.
+type DpfResult uint8
+
+// Supported delivery pending flag results, see SMPP 3.4 spec 5.3.2.28.
+const (
+	DpfNotSet DpfResult = 0x00
+	DpfSet    DpfResult = 0x01
+)
+
+// DeliveryFailureReason is the value of the delivery_failure_reason
+// TLV, why the SMSC failed to deliver a message.
+type DeliveryFailureReason uint8
+
+// Supported delivery failure reasons, see SMPP 3.4 spec 5.3.2.33.
+const (
+	DeliveryDestUnavailable   DeliveryFailureReason = 0x00
+	DeliveryDestAddrInvalid   DeliveryFailureReason = 0x01 // e.g. SME address invalid.
+	DeliveryPermanentNetError DeliveryFailureReason = 0x02
+	DeliveryTemporaryNetError DeliveryFailureReason = 0x03
+)
+
+// ItsReplyType is the value of the its_reply_type TLV, indicating the
+// reply method expected from the user in interactive teleservice.
+type ItsReplyType uint8
+
+// Supported interactive teleservice reply types, see SMPP 3.4 spec 5.3.2.42.
+const (
+	ItsReplyDigit         ItsReplyType = 0x00
+	ItsReplyNumber        ItsReplyType = 0x01
+	ItsReplyTelephoneNo   ItsReplyType = 0x02
+	ItsReplyPassword      ItsReplyType = 0x03
+	ItsReplyCharacterLine ItsReplyType = 0x04
+	ItsReplyMenu          ItsReplyType = 0x05
+	ItsReplyDate          ItsReplyType = 0x06
+	ItsReplyTime          ItsReplyType = 0x07
+	ItsReplyContinue      ItsReplyType = 0x08
+)
+
+// ItsSessionInfo is the value of the its_session_info TLV, see SMPP 3.4
+// spec 5.3.2.43.
+type ItsSessionInfo struct {
+	Session uint8 // Session number.
+	Seq     uint8 // Sequence number of the dialogue unit, 7 bits.
+	End     bool  // End of session indicator.
+}
+
+// Bytes returns the two octet layout of the TLV value: the session
+// number, then the sequence number in bits 7-1 and the end of session
+// indicator in bit 0.
+func (s ItsSessionInfo) Bytes() []byte {
+	b := s.Seq << 1
+	if s.End {
+		b |= 0x01
+	}
+	return []byte{s.Session, b}
+}
+
+// DigitMode is the digit mode indicator of the callback_num TLV.
+type DigitMode uint8
+
+// Supported digit modes, see SMPP 3.4 spec 5.3.2.36.
+const (
+	DigitModeTBCD  DigitMode = 0x00
+	DigitModeASCII DigitMode = 0x01
+)
+
+// CallbackNum is the value of the callback_num TLV, see SMPP 3.4 spec
+// 5.3.2.36.
+type CallbackNum struct {
+	DigitMode DigitMode
+	TON       uint8
+	NPI       uint8
+	Digits    string
+}
+
+// Bytes returns the layout of the TLV value: the digit mode indicator,
+// the TON and NPI octets, then the number digits.
+func (c CallbackNum) Bytes() []byte {
+	b := make([]byte, 0, 3+len(c.Digits))
+	b = append(b, uint8(c.DigitMode), c.TON, c.NPI)
+	return append(b, c.Digits...)
+}
+
+// NetworkErrorCode is the value of the network_error_code TLV of
+// deliver_sm and data_sm, see SMPP 3.4 spec 5.3.2.31.
+type NetworkErrorCode struct {
+	Network ErrorNetwork
+	Code    uint16
+}
+
+// Bytes returns the layout of the TLV value: the network type octet,
+// then the error code on two octets.
+func (n NetworkErrorCode) Bytes() []byte {
+	return []byte{uint8(n.Network), uint8(n.Code >> 8), uint8(n.Code)}
+}
+
+// ErrorNetwork is the network type of a NetworkErrorCode.
+type ErrorNetwork uint8
+
+// Supported network types of network_error_code.
+const (
+	ErrorNetworkANSI136Access ErrorNetwork = 0x01 // ANSI-136 access denied reason
+	ErrorNetworkIS95Access    ErrorNetwork = 0x02 // IS-95 access denied reason
+	ErrorNetworkGSM           ErrorNetwork = 0x03
+	ErrorNetworkANSI136Cause  ErrorNetwork = 0x04
+	ErrorNetworkIS95Cause     ErrorNetwork = 0x05
+	ErrorNetworkANSI41        ErrorNetwork = 0x06
+	ErrorNetworkSMPP          ErrorNetwork = 0x07
+	ErrorNetworkSMSC          ErrorNetwork = 0x08 // Message center specific
+)
+
+// Presentation is the presentation indicator of the
+// callback_num_pres_ind TLV.
+type Presentation uint8
+
+// Supported presentation indicators, see SMPP 3.4 spec 5.3.2.37.
+const (
+	PresentationAllowed      Presentation = 0x00
+	PresentationRestricted   Presentation = 0x01
+	PresentationNotAvailable Presentation = 0x02
+)
+
+// Screening is the screening indicator of the callback_num_pres_ind TLV.
+type Screening uint8
+
+// Supported screening indicators, see SMPP 3.4 spec 5.3.2.37.
+const (
+	ScreeningNone      Screening = 0x00 // User provided, not screened.
+	ScreeningPassed    Screening = 0x01 // User provided, verified and passed.
+	ScreeningFailed    Screening = 0x02 // User provided, verified and failed.
+	ScreeningByNetwork Screening = 0x03 // Network provided.
+)
+
+// CallbackNumPresInd is the value of the callback_num_pres_ind TLV.
+type CallbackNumPresInd struct {
+	Presentation Presentation
+	Screening    Screening
+}
+
+// Byte returns the single octet layout of the TLV value: the
+// presentation indicator in bits 3-2 and the screening indicator in
+// bits 1-0.
+func (c CallbackNumPresInd) Byte() byte {
+	return uint8(c.Presentation&0x03)<<2 | uint8(c.Screening&0x03)
+}
+
+// CallbackNumAtag is the value of the callback_num_atag TLV, an
+// alphanumeric display tag for the callback number, see SMPP 3.4 spec
+// 5.3.2.38.
+type CallbackNumAtag struct {
+	DataCoding uint8
+	Display    []byte // Encoded with DataCoding, up to 64 octets.
+}
+
+// Bytes returns the layout of the TLV value: the data coding octet,
+// then the display characters.
+func (c CallbackNumAtag) Bytes() []byte {
+	b := make([]byte, 0, 1+len(c.Display))
+	b = append(b, c.DataCoding)
+	return append(b, c.Display...)
+}
+
+// MsValidityBehavior is the behavior octet of the ms_validity TLV.
+type MsValidityBehavior uint8
+
+// Supported ms_validity behaviors, see SMPP 5.0 spec 4.8.4.40.
+const (
+	MsValidityStoreIndefinitely MsValidityBehavior = 0x00
+	MsValidityPowerDown         MsValidityBehavior = 0x01
+	MsValidityRegistrationArea  MsValidityBehavior = 0x02 // Until the registration area changes.
+	MsValidityDisplayOnly       MsValidityBehavior = 0x03
+	MsValidityRelative          MsValidityBehavior = 0x04 // For a relative time period, SMPP 5.0.
+)
+
+// MsValidityUnit is the time unit of a relative ms_validity.
+type MsValidityUnit uint8
+
+// Supported time units of a relative ms_validity.
+const (
+	MsValiditySeconds MsValidityUnit = 0x00
+	MsValidityMinutes MsValidityUnit = 0x01
+	MsValidityHours   MsValidityUnit = 0x02
+	MsValidityDays    MsValidityUnit = 0x03
+	MsValidityWeeks   MsValidityUnit = 0x04
+	MsValidityMonths  MsValidityUnit = 0x05
+	MsValidityYears   MsValidityUnit = 0x06
+)
+
+// MsValidity is the value of the ms_validity TLV, how long the mobile
+// station keeps the message. Unit and Count are only sent with
+// MsValidityRelative.
+type MsValidity struct {
+	Behavior MsValidityBehavior
+	Unit     MsValidityUnit // Time unit of a relative period.
+	Count    uint16         // Number of time units of a relative period.
+}
+
+// Bytes returns the layout of the TLV value: the behavior octet, then
+// for a relative period the time unit octet and the number of units on
+// two octets.
+func (v MsValidity) Bytes() []byte {
+	if v.Behavior != MsValidityRelative {
+		return []byte{uint8(v.Behavior)}
+	}
+	return []byte{uint8(v.Behavior), uint8(v.Unit), uint8(v.Count >> 8), uint8(v.Count)}
+}
+
+// PrivacyIndicator returns the value of the privacy_indicator TLV.
+func (m Map) PrivacyIndicator() (PrivacyIndicator, bool) {
+	v, ok := m.Uint8(TagPrivacyIndicator)
+	return PrivacyIndicator(v), ok
+}
+
+// PayloadType returns the value of the payload_type TLV.
+func (m Map) PayloadType() (PayloadType, bool) {
+	v, ok := m.Uint8(TagPayloadType)
+	return PayloadType(v), ok
+}
+
+// SourceNetworkType returns the value of the source_network_type TLV.
+func (m Map) SourceNetworkType() (NetworkType, bool) {
+	v, ok := m.Uint8(TagSourceNetworkType)
+	return NetworkType(v), ok
+}
+
+// DestNetworkType returns the value of the dest_network_type TLV.
+func (m Map) DestNetworkType() (NetworkType, bool) {
+	v, ok := m.Uint8(TagDestNetworkType)
+	return NetworkType(v), ok
+}
+
+// SourceBearerType returns the value of the source_bearer_type TLV.
+func (m Map) SourceBearerType() (BearerType, bool) {
+	v, ok := m.Uint8(TagSourceBearerType)
+	return BearerType(v), ok
+}
+
+// DestBearerType returns the value of the dest_bearer_type TLV.
+func (m Map) DestBearerType() (BearerType, bool) {
+	v, ok := m.Uint8(TagDestBearerType)
+	return BearerType(v), ok
+}
+
+// SAR returns the segmentation and reassembly TLVs of a concatenated
+// message: sar_msg_ref_num, sar_total_segments and sar_segment_seqnum.
+// It returns false unless all three are present.
+func (m Map) SAR() (ref uint16, total, seq uint8, ok bool) {
+	if ref, ok = m.Uint16(TagSarMsgRefNum); !ok {
+		return
+	}
+	if total, ok = m.Uint8(TagSarTotalSegments); !ok {
+		return
+	}
+	seq, ok = m.Uint8(TagSarSegmentSeqnum)
+	return
+}
+
+// DpfResult returns the value of the dpf_result TLV.
+func (m Map) DpfResult() (DpfResult, bool) {
+	v, ok := m.Uint8(TagDpfResult)
+	return DpfResult(v), ok
+}
+
+// DeliveryFailureReason returns the value of the
+// delivery_failure_reason TLV, e.g. of a deliver_sm receipt.
+func (m Map) DeliveryFailureReason() (DeliveryFailureReason, bool) {
+	v, ok := m.Uint8(TagDeliveryFailureReason)
+	return DeliveryFailureReason(v), ok
+}
+
+// MsAvailabilityStatus returns the value of the ms_availability_status
+// TLV.
+func (m Map) MsAvailabilityStatus() (MsAvailabilityStatus, bool) {
+	v, ok := m.Uint8(TagMsAvailabilityStatus)
+	return MsAvailabilityStatus(v), ok
+}
+
+// SetDpf returns the value of the set_dpf TLV, true if a delivery
+// pending flag is requested.
+func (m Map) SetDpf() (requested, ok bool) {
+	v, ok := m.Uint8(TagSetDpf)
+	return v == 0x01, ok
+}
+
+// MoreMessagesToSend returns the value of the more_messages_to_send
+// TLV, true if more messages follow for the same destination.
+func (m Map) MoreMessagesToSend() (more, ok bool) {
+	v, ok := m.Uint8(TagMoreMessagesToSend)
+	return v == 0x01, ok
+}
+
+// NumberOfMessages returns the value of the number_of_messages TLV, the
+// number of messages stored in a mailbox, 0 to 99.
+func (m Map) NumberOfMessages() (uint8, bool) {
+	return m.Uint8(TagNumberOfMessages)
+}
+
+// ItsReplyType returns the value of the its_reply_type TLV.
+func (m Map) ItsReplyType() (ItsReplyType, bool) {
+	v, ok := m.Uint8(TagItsReplyType)
+	return ItsReplyType(v), ok
+}
+
+// ItsSessionInfo returns the value of the its_session_info TLV.
+func (m Map) ItsSessionInfo() (ItsSessionInfo, bool) {
+	f, ok := m[TagItsSessionInfo]
+	if !ok || f == nil || len(f.Bytes()) != 2 {
+		return ItsSessionInfo{}, false
+	}
+	b := f.Bytes()
+	return ItsSessionInfo{
+		Session: b[0],
+		Seq:     b[1] >> 1,
+		End:     b[1]&0x01 != 0,
+	}, true
+}
+
+// CallbackNum returns the value of the callback_num TLV. It returns
+// false if the TLV is not present or shorter than 4 octets.
+func (m Map) CallbackNum() (CallbackNum, bool) {
+	f, ok := m[TagCallbackNum]
+	if !ok || f == nil || len(f.Bytes()) < 4 {
+		return CallbackNum{}, false
+	}
+	b := f.Bytes()
+	return CallbackNum{
+		DigitMode: DigitMode(b[0]),
+		TON:       b[1],
+		NPI:       b[2],
+		Digits:    string(b[3:]),
+	}, true
+}
+
+// NetworkErrorCode returns the value of the network_error_code TLV. It
+// returns false if the TLV is not present or not 3 octets long.
+func (m Map) NetworkErrorCode() (NetworkErrorCode, bool) {
+	f, ok := m[TagNetworkErrorCode]
+	if !ok || f == nil || len(f.Bytes()) != 3 {
+		return NetworkErrorCode{}, false
+	}
+	b := f.Bytes()
+	return NetworkErrorCode{
+		Network: ErrorNetwork(b[0]),
+		Code:    uint16(b[1])<<8 | uint16(b[2]),
+	}, true
+}
+
+// ReceiptedMessageID returns the value of the receipted_message_id
+// TLV, the message ID of the message a delivery receipt refers to.
+func (m Map) ReceiptedMessageID() (string, bool) {
+	return m.String(TagReceiptedMessageID)
+}
+
+// MessageState returns the value of the message_state TLV, see
+// pdu.MessageState.
+func (m Map) MessageState() (uint8, bool) {
+	return m.Uint8(TagMessageStateOption)
+}
+
+// QosTimeToLive returns the value of the qos_time_to_live TLV, the
+// time to live of the message, sent in milliseconds.
+func (m Map) QosTimeToLive() (time.Duration, bool) {
+	v, ok := m.Uint32(TagQosTimeToLive)
+	return time.Duration(v) * time.Millisecond, ok
+}
+
+// CongestionState returns the value of the SMPP 5.0 congestion_state
+// TLV, the load of the SMSC from 0 (idle) to 100 (congested).
+func (m Map) CongestionState() (uint8, bool) {
+	return m.Uint8(TagCongestionState)
+}
+
+// CallbackNumPresInd returns the value of the callback_num_pres_ind TLV.
+func (m Map) CallbackNumPresInd() (CallbackNumPresInd, bool) {
+	v, ok := m.Uint8(TagCallbackNumPresInd)
+	if !ok {
+		return CallbackNumPresInd{}, false
+	}
+	return CallbackNumPresInd{
+		Presentation: Presentation(v >> 2 & 0x03),
+		Screening:    Screening(v & 0x03),
+	}, true
+}
+
+// CallbackNumAtag returns the value of the callback_num_atag TLV. It
+// returns false if the TLV is not present or empty.
+func (m Map) CallbackNumAtag() (CallbackNumAtag, bool) {
+	f, ok := m[TagCallbackNumAtag]
+	if !ok || f == nil || len(f.Bytes()) < 1 {
+		return CallbackNumAtag{}, false
+	}
+	b := f.Bytes()
+	return CallbackNumAtag{
+		DataCoding: b[0],
+		Display:    append([]byte(nil), b[1:]...),
+	}, true
+}
+
+// MsValidity returns the value of the ms_validity TLV. It returns false
+// if the TLV is not present, or is neither 1 nor 4 octets long.
+func (m Map) MsValidity() (MsValidity, bool) {
+	f, ok := m[TagMsValidity]
+	if !ok || f == nil {
+		return MsValidity{}, false
+	}
+	switch b := f.Bytes(); len(b) {
+	case 1:
+		return MsValidity{Behavior: MsValidityBehavior(b[0])}, true
+	case 4:
+		return MsValidity{
+			Behavior: MsValidityBehavior(b[0]),
+			Unit:     MsValidityUnit(b[1]),
+			Count:    uint16(b[2])<<8 | uint16(b[3]),
+		}, true
+	}
+	return MsValidity{}, false
+}
@@ -0,0 +1,324 @@
+// Copyright 2015 go-smpp authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package pdutlv
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestPrivacyIndicatorPayloadType(t *testing.T) {
+	m := make(Map)
+	if _, ok := m.PrivacyIndicator(); ok {
+		t.Fatal("unexpected privacy_indicator in empty map")
+	}
+	if err := m.Set(TagPrivacyIndicator, PrivacyConfidential); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Set(TagPayloadType, PayloadWCMP); err != nil {
+		t.Fatal(err)
+	}
+	var b bytes.Buffer
+	for _, tag := range []Tag{TagPayloadType, TagPrivacyIndicator} {
+		if err := m[tag].SerializeTo(&b); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := []byte{0x00, 0x19, 0x00, 0x01, 0x01, 0x02, 0x01, 0x00, 0x01, 0x02}
+	if !bytes.Equal(want, b.Bytes()) {
+		t.Fatalf("unexpected serialized bytes: want %x, have %x", want, b.Bytes())
+	}
+	d, err := DecodeTLV(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := d.PrivacyIndicator(); !ok || v != PrivacyConfidential {
+		t.Fatalf("unexpected privacy_indicator: want %d, have %d", PrivacyConfidential, v)
+	}
+	if v, ok := d.PayloadType(); !ok || v != PayloadWCMP {
+		t.Fatalf("unexpected payload_type: want %d, have %d", PayloadWCMP, v)
+	}
+}
+
+func TestDpf(t *testing.T) {
+	m := make(Map)
+	if err := m.Set(TagSetDpf, uint8(0x01)); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Set(TagDpfResult, DpfSet); err != nil {
+		t.Fatal(err)
+	}
+	var b bytes.Buffer
+	for _, tag := range []Tag{TagDpfResult, TagSetDpf} {
+		if err := m[tag].SerializeTo(&b); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := []byte{0x04, 0x20, 0x00, 0x01, 0x01, 0x04, 0x21, 0x00, 0x01, 0x01}
+	if !bytes.Equal(want, b.Bytes()) {
+		t.Fatalf("unexpected serialized bytes: want %x, have %x", want, b.Bytes())
+	}
+	d, err := DecodeTLV(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := d.DpfResult(); !ok || v != DpfSet {
+		t.Fatalf("unexpected dpf_result: want %d, have %d", DpfSet, v)
+	}
+	if v, ok := d.SetDpf(); !ok || !v {
+		t.Fatalf("unexpected set_dpf: want true, have %t", v)
+	}
+}
+
+func TestMoreMessagesToSend(t *testing.T) {
+	for _, want := range []bool{false, true} {
+		var v uint8
+		if want {
+			v = 0x01
+		}
+		m := make(Map)
+		if err := m.Set(TagMoreMessagesToSend, v); err != nil {
+			t.Fatal(err)
+		}
+		var b bytes.Buffer
+		if err := m[TagMoreMessagesToSend].SerializeTo(&b); err != nil {
+			t.Fatal(err)
+		}
+		if raw := []byte{0x04, 0x26, 0x00, 0x01, v}; !bytes.Equal(raw, b.Bytes()) {
+			t.Fatalf("unexpected serialized bytes: want %x, have %x", raw, b.Bytes())
+		}
+		d, err := DecodeTLV(&b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if have, ok := d.MoreMessagesToSend(); !ok || have != want {
+			t.Fatalf("unexpected more_messages_to_send: want %t, have %t (%t)", want, have, ok)
+		}
+	}
+}
+
+func TestDeliveryFailureReason(t *testing.T) {
+	for _, want := range []DeliveryFailureReason{
+		DeliveryDestUnavailable,
+		DeliveryDestAddrInvalid,
+		DeliveryPermanentNetError,
+		DeliveryTemporaryNetError,
+	} {
+		m := make(Map)
+		if err := m.Set(TagDeliveryFailureReason, want); err != nil {
+			t.Fatal(err)
+		}
+		var b bytes.Buffer
+		if err := m[TagDeliveryFailureReason].SerializeTo(&b); err != nil {
+			t.Fatal(err)
+		}
+		if raw := []byte{0x04, 0x25, 0x00, 0x01, uint8(want)}; !bytes.Equal(raw, b.Bytes()) {
+			t.Fatalf("unexpected serialized bytes: want %x, have %x", raw, b.Bytes())
+		}
+		d, err := DecodeTLV(&b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v, ok := d.DeliveryFailureReason(); !ok || v != want {
+			t.Fatalf("unexpected delivery_failure_reason: want %d, have %d (%t)", want, v, ok)
+		}
+	}
+	if _, ok := make(Map).DeliveryFailureReason(); ok {
+		t.Fatal("unexpected delivery_failure_reason in empty map")
+	}
+}
+
+func TestNumberOfMessages(t *testing.T) {
+	m := make(Map)
+	if err := m.Set(TagNumberOfMessages, uint8(42)); err != nil {
+		t.Fatal(err)
+	}
+	var b bytes.Buffer
+	if err := m[TagNumberOfMessages].SerializeTo(&b); err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{0x03, 0x04, 0x00, 0x01, 0x2a}
+	if !bytes.Equal(want, b.Bytes()) {
+		t.Fatalf("unexpected serialized bytes: want %x, have %x", want, b.Bytes())
+	}
+	d, err := DecodeTLV(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := d.NumberOfMessages(); !ok || v != 42 {
+		t.Fatalf("unexpected number_of_messages: want 42, have %d (%t)", v, ok)
+	}
+}
+
+func TestBearerType(t *testing.T) {
+	m := make(Map)
+	if err := m.Set(TagDestBearerType, BearerUSSD); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Set(TagSourceBearerType, BearerCellBroadcast); err != nil {
+		t.Fatal(err)
+	}
+	var b bytes.Buffer
+	for _, tag := range []Tag{TagDestBearerType, TagSourceBearerType} {
+		if err := m[tag].SerializeTo(&b); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := []byte{0x00, 0x07, 0x00, 0x01, 0x04, 0x00, 0x0f, 0x00, 0x01, 0x08}
+	if !bytes.Equal(want, b.Bytes()) {
+		t.Fatalf("unexpected serialized bytes: want %x, have %x", want, b.Bytes())
+	}
+	d, err := DecodeTLV(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := d.DestBearerType(); !ok || v != BearerUSSD {
+		t.Fatalf("unexpected dest_bearer_type: want %d, have %d (%t)", BearerUSSD, v, ok)
+	}
+	if v, ok := d.SourceBearerType(); !ok || v != BearerCellBroadcast {
+		t.Fatalf("unexpected source_bearer_type: want %d, have %d (%t)", BearerCellBroadcast, v, ok)
+	}
+}
+
+func TestMsValidity(t *testing.T) {
+	test := []struct {
+		v    MsValidity
+		want []byte
+	}{
+		{MsValidity{Behavior: MsValidityDisplayOnly}, []byte{0x12, 0x04, 0x00, 0x01, 0x03}},
+		{MsValidity{Behavior: MsValidityPowerDown, Count: 5}, []byte{0x12, 0x04, 0x00, 0x01, 0x01}},
+		{
+			MsValidity{Behavior: MsValidityRelative, Unit: MsValidityHours, Count: 300},
+			[]byte{0x12, 0x04, 0x00, 0x04, 0x04, 0x02, 0x01, 0x2c},
+		},
+	}
+	for _, tc := range test {
+		m := make(Map)
+		if err := m.Set(TagMsValidity, tc.v); err != nil {
+			t.Fatal(err)
+		}
+		var b bytes.Buffer
+		if err := m[TagMsValidity].SerializeTo(&b); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(tc.want, b.Bytes()) {
+			t.Fatalf("unexpected serialized bytes: want %x, have %x", tc.want, b.Bytes())
+		}
+		d, err := DecodeTLV(&b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := tc.v
+		if want.Behavior != MsValidityRelative {
+			want.Unit, want.Count = 0, 0
+		}
+		if v, ok := d.MsValidity(); !ok || v != want {
+			t.Fatalf("unexpected ms_validity: want %+v, have %+v (%t)", want, v, ok)
+		}
+	}
+	m := Map{TagMsValidity: NewTLV(TagMsValidity, []byte{0x04, 0x02})}
+	if v, ok := m.MsValidity(); ok {
+		t.Fatalf("unexpected ms_validity of 2 octets: %+v", v)
+	}
+}
+
+func TestQosTimeToLive(t *testing.T) {
+	m := make(Map)
+	if err := m.Set(TagQosTimeToLive, uint32(90000)); err != nil {
+		t.Fatal(err)
+	}
+	var b bytes.Buffer
+	if err := m[TagQosTimeToLive].SerializeTo(&b); err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{0x00, 0x17, 0x00, 0x04, 0x00, 0x01, 0x5f, 0x90}
+	if !bytes.Equal(want, b.Bytes()) {
+		t.Fatalf("unexpected serialized bytes: want %x, have %x", want, b.Bytes())
+	}
+	d, err := DecodeTLV(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := d.QosTimeToLive(); !ok || v != 90*time.Second {
+		t.Fatalf("unexpected qos_time_to_live: want %s, have %s (%t)", 90*time.Second, v, ok)
+	}
+}
+
+func TestCallbackNum(t *testing.T) {
+	m := make(Map)
+	cb := CallbackNum{DigitMode: DigitModeASCII, TON: 0x01, NPI: 0x01, Digits: "15551234"}
+	ind := CallbackNumPresInd{Presentation: PresentationRestricted, Screening: ScreeningByNetwork}
+	atag := CallbackNumAtag{DataCoding: 0x00, Display: []byte("Help")}
+	if err := m.Set(TagCallbackNum, cb); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Set(TagCallbackNumPresInd, ind); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Set(TagCallbackNumAtag, atag); err != nil {
+		t.Fatal(err)
+	}
+	var b bytes.Buffer
+	for _, tag := range []Tag{TagCallbackNum, TagCallbackNumPresInd, TagCallbackNumAtag} {
+		if err := m[tag].SerializeTo(&b); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := []byte{
+		0x03, 0x81, 0x00, 0x0b, 0x01, 0x01, 0x01, '1', '5', '5', '5', '1', '2', '3', '4',
+		0x03, 0x02, 0x00, 0x01, 0x07,
+		0x03, 0x03, 0x00, 0x05, 0x00, 'H', 'e', 'l', 'p',
+	}
+	if !bytes.Equal(want, b.Bytes()) {
+		t.Fatalf("unexpected serialized bytes: want %x, have %x", want, b.Bytes())
+	}
+	d, err := DecodeTLV(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := d.CallbackNum(); !ok || v != cb {
+		t.Fatalf("unexpected callback_num: want %+v, have %+v", cb, v)
+	}
+	if v, ok := d.CallbackNumPresInd(); !ok || v != ind {
+		t.Fatalf("unexpected callback_num_pres_ind: want %+v, have %+v", ind, v)
+	}
+	v, ok := d.CallbackNumAtag()
+	if !ok || v.DataCoding != atag.DataCoding || !bytes.Equal(v.Display, atag.Display) {
+		t.Fatalf("unexpected callback_num_atag: want %+v, have %+v", atag, v)
+	}
+}
+
+func TestItsSessionInfo(t *testing.T) {
+	m := make(Map)
+	info := ItsSessionInfo{Session: 0x2A, Seq: 5, End: true}
+	if err := m.Set(TagItsSessionInfo, info); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Set(TagItsReplyType, ItsReplyMenu); err != nil {
+		t.Fatal(err)
+	}
+	var b bytes.Buffer
+	if err := m[TagItsSessionInfo].SerializeTo(&b); err != nil {
+		t.Fatal(err)
+	}
+	if err := m[TagItsReplyType].SerializeTo(&b); err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{0x13, 0x83, 0x00, 0x02, 0x2A, 0x0B, 0x13, 0x80, 0x00, 0x01, 0x05}
+	if !bytes.Equal(want, b.Bytes()) {
+		t.Fatalf("unexpected serialized bytes: want %x, have %x", want, b.Bytes())
+	}
+	d, err := DecodeTLV(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := d.ItsSessionInfo(); !ok || v != info {
+		t.Fatalf("unexpected its_session_info: want %+v, have %+v", info, v)
+	}
+	if v, ok := d.ItsReplyType(); !ok || v != ItsReplyMenu {
+		t.Fatalf("unexpected its_reply_type: want %d, have %d", ItsReplyMenu, v)
+	}
+}
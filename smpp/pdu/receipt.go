@@ -0,0 +1,191 @@
+// Copyright 2015 go-smpp authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package pdu
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/florentchauveau/go-smpp/smpp/pdu/pdufield"
+	"github.com/florentchauveau/go-smpp/smpp/pdu/pdutlv"
+)
+
+// MessageState is the state of a short message, as carried in the
+// message_state field or TLV. See SMPP 3.4 spec 5.2.28.
+type MessageState uint8
+
+// Supported message states.
+const (
+	EnrouteState       MessageState = 1
+	DeliveredState     MessageState = 2
+	ExpiredState       MessageState = 3
+	DeletedState       MessageState = 4
+	UndeliverableState MessageState = 5
+	AcceptedState      MessageState = 6
+	UnknownState       MessageState = 7
+	RejectedState      MessageState = 8
+)
+
+// receiptStat maps message states to the stat values used in
+// delivery receipts, see SMPP 3.4 spec Appendix B.
+var receiptStat = map[MessageState]string{
+	EnrouteState:       "ENROUTE",
+	DeliveredState:     "DELIVRD",
+	ExpiredState:       "EXPIRED",
+	DeletedState:       "DELETED",
+	UndeliverableState: "UNDELIV",
+	AcceptedState:      "ACCEPTD",
+	UnknownState:       "UNKNOWN",
+	RejectedState:      "REJECTD",
+}
+
+// String returns the message state as used in delivery receipts.
+func (s MessageState) String() string {
+	if stat, ok := receiptStat[s]; ok {
+		return stat
+	}
+	return fmt.Sprintf("UNKNOWN (%d)", uint8(s))
+}
+
+// receiptDateLayout is the layout of the submit and done dates.
+const receiptDateLayout = "0601021504"
+
+// DeliveryReceipt holds the contents of an SMSC delivery receipt.
+type DeliveryReceipt struct {
+	ID         string       // ID of the original message.
+	Sub        int          // Number of short messages originally submitted.
+	Dlvrd      int          // Number of short messages delivered.
+	SubmitDate time.Time    // Time the original message was submitted.
+	DoneDate   time.Time    // Time the original message reached its final state.
+	State      MessageState // Final state of the original message.
+	Err        int          // Network specific error code.
+	Text       string       // First characters of the original message.
+}
+
+// String returns the receipt text in the conventional format:
+//
+//	id:IIIIIIIIII sub:SSS dlvrd:DDD submit date:YYMMDDhhmm done date:YYMMDDhhmm stat:DDDDDDD err:E text:...
+func (r *DeliveryReceipt) String() string {
+	return fmt.Sprintf("id:%s sub:%03d dlvrd:%03d submit date:%s done date:%s stat:%s err:%03d text:%s",
+		r.ID, r.Sub, r.Dlvrd,
+		r.SubmitDate.Format(receiptDateLayout),
+		r.DoneDate.Format(receiptDateLayout),
+		r.State, r.Err, r.Text)
+}
+
+// receiptKeys are the keys of the receipt text, in conventional order.
+var receiptKeys = []string{"id:", "sub:", "dlvrd:", "submit date:", "done date:", "stat:", "err:", "text:"}
+
+// ParseDeliveryReceipt parses the text of a delivery receipt, as found
+// in the short_message field of a deliver_sm. Keys are matched regardless
+// of case and missing keys are left to their zero value, but the id key
+// is required.
+func ParseDeliveryReceipt(text string) (*DeliveryReceipt, error) {
+	lower := strings.ToLower(text)
+	values := make(map[string]string)
+	for _, k := range receiptKeys {
+		i := strings.Index(lower, k)
+		if i < 0 {
+			continue
+		}
+		v := text[i+len(k):]
+		if k != "text:" {
+			if j := strings.IndexByte(v, ' '); j >= 0 {
+				v = v[:j]
+			}
+		}
+		values[k] = v
+	}
+	id, ok := values["id:"]
+	if !ok {
+		return nil, fmt.Errorf("delivery receipt: missing id in %q", text)
+	}
+	r := &DeliveryReceipt{ID: id, Text: values["text:"]}
+	var err error
+	for k, dst := range map[string]*int{"sub:": &r.Sub, "dlvrd:": &r.Dlvrd, "err:": &r.Err} {
+		v, ok := values[k]
+		if !ok {
+			continue
+		}
+		if *dst, err = strconv.Atoi(v); err != nil {
+			return nil, fmt.Errorf("delivery receipt: invalid %s %q", strings.TrimSuffix(k, ":"), v)
+		}
+	}
+	for k, dst := range map[string]*time.Time{"submit date:": &r.SubmitDate, "done date:": &r.DoneDate} {
+		v, ok := values[k]
+		if !ok {
+			continue
+		}
+		layout := receiptDateLayout
+		if len(v) == len(receiptDateLayout)+2 {
+			layout += "05" // some SMSCs include seconds
+		}
+		if *dst, err = time.Parse(layout, v); err != nil {
+			return nil, fmt.Errorf("delivery receipt: invalid %s %q", strings.TrimSuffix(k, ":"), v)
+		}
+	}
+	if stat, ok := values["stat:"]; ok {
+		for s, v := range receiptStat {
+			if strings.EqualFold(v, stat) {
+				r.State = s
+				break
+			}
+		}
+		if r.State == 0 {
+			r.State = UnknownState
+		}
+	}
+	return r, nil
+}
+
+// BuildDeliveryReceipt creates a DeliverSM PDU carrying the given
+// delivery receipt from src to dst, where src and dst are the
+// destination and source addresses of the original message. The
+// esm_class is set to SMSC delivery receipt, and the receipted_message_id
+// and message_state TLVs are set from the receipt.
+func BuildDeliveryReceipt(src, dst string, r *DeliveryReceipt) Body {
+	p := NewDeliverSM()
+	f := p.Fields()
+	_ = f.Set(pdufield.SourceAddr, src)
+	_ = f.Set(pdufield.DestinationAddr, dst)
+	_ = f.Set(pdufield.ESMClass, pdufield.ESMClassSMSCDeliveryReceipt)
+	_ = f.Set(pdufield.ShortMessage, []byte(r.String()))
+	t := p.TLVFields()
+	_ = t.Set(pdutlv.TagReceiptedMessageID, pdutlv.CString(r.ID))
+	_ = t.Set(pdutlv.TagMessageStateOption, uint8(r.State))
+	return p
+}
+
+// IsDeliveryReceipt returns true if the esm_class of p marks an SMSC
+// delivery receipt, as opposed to a mobile originated message.
+func IsDeliveryReceipt(p Body) bool {
+	esm, ok := p.Fields().ESMClassFlags()
+	return ok && esm.IsDeliveryReceipt()
+}
+
+// ReceiptFrom returns the delivery receipt carried by p. The receipt is
+// parsed from the short message text, or else built from the
+// receipted_message_id and message_state TLVs. It returns false if p is
+// not a delivery receipt, or carries neither a valid receipt text nor a
+// receipted_message_id.
+func ReceiptFrom(p Body) (*DeliveryReceipt, bool) {
+	if !IsDeliveryReceipt(p) {
+		return nil, false
+	}
+	if text, ok := p.Fields().String(pdufield.ShortMessage); ok {
+		if r, err := ParseDeliveryReceipt(text); err == nil {
+			return r, true
+		}
+	}
+	t := p.TLVFields()
+	id, ok := t.String(pdutlv.TagReceiptedMessageID)
+	if !ok || id == "" {
+		return nil, false
+	}
+	st, _ := t.Uint8(pdutlv.TagMessageStateOption)
+	return &DeliveryReceipt{ID: id, State: MessageState(st)}, true
+}
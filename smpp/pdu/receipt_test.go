@@ -0,0 +1,108 @@
+// Copyright 2015 go-smpp authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package pdu
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/florentchauveau/go-smpp/smpp/pdu/pdufield"
+	"github.com/florentchauveau/go-smpp/smpp/pdu/pdutlv"
+)
+
+func TestDeliveryReceipt(t *testing.T) {
+	want := &DeliveryReceipt{
+		ID:         "0123456789",
+		Sub:        1,
+		Dlvrd:      1,
+		SubmitDate: time.Date(2015, 3, 1, 10, 20, 0, 0, time.UTC),
+		DoneDate:   time.Date(2015, 3, 1, 10, 21, 0, 0, time.UTC),
+		State:      DeliveredState,
+		Err:        0,
+		Text:       "Lorem ipsum",
+	}
+	text := "id:0123456789 sub:001 dlvrd:001 submit date:1503011020 done date:1503011021 stat:DELIVRD err:000 text:Lorem ipsum"
+	if have := want.String(); have != text {
+		t.Fatalf("unexpected text:\nwant: %q\nhave: %q", text, have)
+	}
+	p := BuildDeliveryReceipt("foobar", "root", want)
+	var b bytes.Buffer
+	if err := p.SerializeTo(&b); err != nil {
+		t.Fatal(err)
+	}
+	p, err := Decode(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Header().ID != DeliverSMID {
+		t.Fatalf("unexpected ID: want %s, have %s", DeliverSMID, p.Header().ID)
+	}
+	f := p.Fields()
+	if esm := f[pdufield.ESMClass].Bytes()[0]; esm != pdufield.ESMClassSMSCDeliveryReceipt {
+		t.Fatalf("unexpected esm_class: want %#x, have %#x", pdufield.ESMClassSMSCDeliveryReceipt, esm)
+	}
+	tlv := p.TLVFields()
+	if id := tlv[pdutlv.TagReceiptedMessageID].String(); id != want.ID {
+		t.Fatalf("unexpected receipted_message_id: want %q, have %q", want.ID, id)
+	}
+	if st := tlv[pdutlv.TagMessageStateOption].Bytes(); len(st) != 1 || MessageState(st[0]) != DeliveredState {
+		t.Fatalf("unexpected message_state: want %d, have %v", DeliveredState, st)
+	}
+	have, err := ParseDeliveryReceipt(f[pdufield.ShortMessage].String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *have != *want {
+		t.Fatalf("unexpected receipt:\nwant: %#v\nhave: %#v", want, have)
+	}
+}
+
+func TestReceiptFrom(t *testing.T) {
+	want := &DeliveryReceipt{ID: "0123456789", Sub: 1, Dlvrd: 1, State: DeliveredState}
+	p := BuildDeliveryReceipt("foobar", "root", want)
+	if !IsDeliveryReceipt(p) {
+		t.Fatal("receipt not detected")
+	}
+	r, ok := ReceiptFrom(p)
+	if !ok || r.ID != want.ID || r.State != want.State || r.Dlvrd != 1 {
+		t.Fatalf("unexpected receipt: want %#v, have %#v", want, r)
+	}
+
+	// Unparsable text, receipt from the TLVs.
+	_ = p.Fields().Set(pdufield.ShortMessage, []byte("delivered"))
+	r, ok = ReceiptFrom(p)
+	if !ok || r.ID != want.ID || r.State != want.State {
+		t.Fatalf("unexpected receipt: want %#v, have %#v", want, r)
+	}
+
+	mo := NewDeliverSM()
+	f := mo.Fields()
+	_ = f.Set(pdufield.SourceAddr, "root")
+	_ = f.Set(pdufield.DestinationAddr, "foobar")
+	_ = f.Set(pdufield.ShortMessage, []byte("id:0123456789 stat:DELIVRD"))
+	if IsDeliveryReceipt(mo) {
+		t.Fatal("mobile originated message detected as receipt")
+	}
+	if r, ok := ReceiptFrom(mo); ok {
+		t.Fatalf("unexpected receipt: %#v", r)
+	}
+}
+
+func TestParseDeliveryReceipt(t *testing.T) {
+	r, err := ParseDeliveryReceipt("id:abc sub:001 dlvrd:000 submit date:150301102030 done date:150301102130 stat:UNDELIV err:042 Text:hi there")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.ID != "abc" || r.State != UndeliverableState || r.Err != 42 || r.Text != "hi there" {
+		t.Fatalf("unexpected receipt: %#v", r)
+	}
+	if want := time.Date(2015, 3, 1, 10, 20, 30, 0, time.UTC); !r.SubmitDate.Equal(want) {
+		t.Fatalf("unexpected submit date: want %s, have %s", want, r.SubmitDate)
+	}
+	if _, err = ParseDeliveryReceipt("sub:001 dlvrd:000"); err == nil {
+		t.Fatal("unexpected parse of receipt without id")
+	}
+}
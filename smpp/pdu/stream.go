@@ -0,0 +1,44 @@
+// Copyright 2015 go-smpp authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package pdu
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+
+	"github.com/florentchauveau/go-smpp/smpp/pdu/pdufield"
+)
+
+// Reader reads PDUs one at a time off a stream, e.g. a net.Conn, using
+// the command_length of each header for framing. It holds no session
+// state, and is meant as a building block for proxies and gateways.
+type Reader struct {
+	DecodeOptions pdufield.DecodeOptions // PDU decoding options, optional.
+
+	r *bufio.Reader
+}
+
+// NewReader creates a new Reader reading from r.
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: bufio.NewReader(r)}
+}
+
+// Read reads and decodes the next PDU. It returns io.EOF when the stream
+// ends between two PDUs, and io.ErrUnexpectedEOF when it ends within one.
+func (r *Reader) Read() (Body, error) {
+	return DecodeWithOptions(r.r, r.DecodeOptions)
+}
+
+// Write serializes p and writes it to w in a single call, so that
+// concurrent writers to a net.Conn do not interleave partial PDUs.
+func Write(w io.Writer, p Body) error {
+	var b bytes.Buffer
+	if err := p.SerializeTo(&b); err != nil {
+		return err
+	}
+	_, err := w.Write(b.Bytes())
+	return err
+}
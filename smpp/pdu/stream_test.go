@@ -0,0 +1,50 @@
+// Copyright 2015 go-smpp authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package pdu
+
+import (
+	"io"
+	"net"
+	"testing"
+	"testing/iotest"
+
+	"github.com/florentchauveau/go-smpp/smpp/pdu/pdufield"
+)
+
+func TestReader(t *testing.T) {
+	sm := NewSubmitSM(nil)
+	_ = sm.Fields().Set(pdufield.ShortMessage, []byte("hello"))
+	want := []Body{NewEnquireLink(), sm, NewUnbind()}
+
+	c1, c2 := net.Pipe()
+	go func() {
+		for _, p := range want {
+			if err := Write(c1, p); err != nil {
+				t.Error(err)
+			}
+		}
+		c1.Close()
+	}()
+	r := NewReader(iotest.OneByteReader(c2))
+	for _, w := range want {
+		p, err := r.Read()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.Header().ID != w.Header().ID || p.Header().Seq != w.Header().Seq {
+			t.Fatalf("unexpected PDU: want %s seq %d, have %s seq %d",
+				w.Header().ID, w.Header().Seq, p.Header().ID, p.Header().Seq)
+		}
+		if p.Header().ID != SubmitSMID {
+			continue
+		}
+		if text, _ := p.Fields().String(pdufield.ShortMessage); text != "hello" {
+			t.Fatalf("unexpected short message: want %q, have %q", "hello", text)
+		}
+	}
+	if p, err := r.Read(); err != io.EOF {
+		t.Fatalf("unexpected read after close: want %v, have %v, %#v", io.EOF, err, p)
+	}
+}
@@ -288,6 +288,56 @@ func NewSubmitMultiResp() Body {
 	return b
 }
 
+// DataSM PDU. The message is sent in the message_payload TLV.
+type DataSM struct{ *codec }
+
+func newDataSM(hdr *Header) *codec {
+	return &codec{
+		h: hdr,
+		l: pdufield.List{
+			pdufield.ServiceType,
+			pdufield.SourceAddrTON,
+			pdufield.SourceAddrNPI,
+			pdufield.SourceAddr,
+			pdufield.DestAddrTON,
+			pdufield.DestAddrNPI,
+			pdufield.DestinationAddr,
+			pdufield.ESMClass,
+			pdufield.RegisteredDelivery,
+			pdufield.DataCoding,
+		},
+	}
+}
+
+// NewDataSM creates and initializes a new DataSM PDU.
+func NewDataSM(fields pdutlv.Fields) Body {
+	b := newDataSM(&Header{ID: DataSMID})
+	b.init()
+	for tag, value := range fields {
+		_ = b.t.Set(tag, value)
+	}
+	return b
+}
+
+// DataSMResp PDU.
+type DataSMResp struct{ *codec }
+
+func newDataSMResp(hdr *Header) *codec {
+	return &codec{
+		h: hdr,
+		l: pdufield.List{
+			pdufield.MessageID,
+		},
+	}
+}
+
+// NewDataSMResp creates and initializes a new DataSMResp PDU.
+func NewDataSMResp() Body {
+	b := newDataSMResp(&Header{ID: DataSMRespID})
+	b.init()
+	return b
+}
+
 // DeliverSM PDU.
 type DeliverSM struct{ *codec }
 
@@ -380,6 +430,13 @@ func NewUnbindResp() Body {
 	return b
 }
 
+// NewUnbindRespSeq creates and initializes a UnbindResp PDU for a specific seq.
+func NewUnbindRespSeq(seq uint32) Body {
+	b := newUnbindResp(&Header{ID: UnbindRespID, Seq: seq})
+	b.init()
+	return b
+}
+
 // EnquireLink PDU.
 type EnquireLink struct{ *codec }
 
@@ -414,3 +471,27 @@ func NewEnquireLinkRespSeq(seq uint32) Body {
 	b.init()
 	return b
 }
+
+// AlertNotification PDU.
+type AlertNotification struct{ *codec }
+
+func newAlertNotification(hdr *Header) *codec {
+	return &codec{
+		h: hdr,
+		l: pdufield.List{
+			pdufield.SourceAddrTON,
+			pdufield.SourceAddrNPI,
+			pdufield.SourceAddr,
+			pdufield.ESMEAddrTON,
+			pdufield.ESMEAddrNPI,
+			pdufield.ESMEAddr,
+		},
+	}
+}
+
+// NewAlertNotification creates and initializes a AlertNotification PDU.
+func NewAlertNotification() Body {
+	b := newAlertNotification(&Header{ID: AlertNotificationID})
+	b.init()
+	return b
+}
@@ -11,6 +11,7 @@ import (
 	"testing"
 
 	"github.com/florentchauveau/go-smpp/smpp/pdu/pdufield"
+	"github.com/florentchauveau/go-smpp/smpp/pdu/pdutlv"
 )
 
 func TestBind(t *testing.T) {
@@ -82,3 +83,75 @@ func TestBindResp(t *testing.T) {
 	t.Log(tx)
 }
 */
+
+func TestAlertNotification(t *testing.T) {
+	tx := []byte{
+		0x00, 0x00, 0x00, 0x2A, 0x00, 0x00, 0x01, 0x02,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x07,
+		0x01, 0x01, 0x33, 0x33, 0x36, 0x31, 0x32, 0x33,
+		0x34, 0x00, 0x00, 0x00, 0x65, 0x73, 0x6D, 0x65,
+		0x00, 0x04, 0x22, 0x00, 0x01, 0x02,
+	}
+	tx[3] = byte(len(tx))
+	p, err := Decode(bytes.NewReader(tx))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Header().ID != AlertNotificationID {
+		t.Fatalf("unexpected ID: want %s, have %s", AlertNotificationID, p.Header().ID)
+	}
+	f := p.Fields()
+	for n, want := range map[pdufield.Name]string{
+		pdufield.SourceAddrTON: "1",
+		pdufield.SourceAddrNPI: "1",
+		pdufield.SourceAddr:    "3361234",
+		pdufield.ESMEAddrTON:   "0",
+		pdufield.ESMEAddrNPI:   "0",
+		pdufield.ESMEAddr:      "esme",
+	} {
+		if f[n] == nil || f[n].String() != want {
+			t.Fatalf("unexpected value for %q: want %q, have %v", n, want, f[n])
+		}
+	}
+	if v, ok := p.TLVFields().MsAvailabilityStatus(); !ok || v != pdutlv.MsUnavailable {
+		t.Fatalf("unexpected ms_availability_status: want %d, have %d (%t)", pdutlv.MsUnavailable, v, ok)
+	}
+	var b bytes.Buffer
+	if err := p.SerializeTo(&b); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(tx, b.Bytes()) {
+		t.Fatalf("unexpected bytes:\nwant:\n%s\nhave:\n%s", hex.Dump(tx), hex.Dump(b.Bytes()))
+	}
+}
+
+func TestDataSM(t *testing.T) {
+	p := NewDataSM(pdutlv.Fields{pdutlv.TagMessagePayload: []byte("hello")})
+	f := p.Fields()
+	_ = f.Set(pdufield.SourceAddr, "root")
+	_ = f.Set(pdufield.DestinationAddr, "foobar")
+	_ = f.Set(pdufield.RegisteredDelivery, uint8(1))
+	var b bytes.Buffer
+	if err := p.SerializeTo(&b); err != nil {
+		t.Fatal(err)
+	}
+	d, err := Decode(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Header().ID != DataSMID {
+		t.Fatalf("unexpected ID: want %s, have %s", DataSMID, d.Header().ID)
+	}
+	for n, want := range map[pdufield.Name]string{
+		pdufield.SourceAddr:         "root",
+		pdufield.DestinationAddr:    "foobar",
+		pdufield.RegisteredDelivery: "1",
+	} {
+		if v := d.Fields()[n]; v == nil || v.String() != want {
+			t.Fatalf("unexpected value for %q: want %q, have %v", n, want, v)
+		}
+	}
+	if v, ok := d.TLVFields().String(pdutlv.TagMessagePayload); !ok || v != "hello" {
+		t.Fatalf("unexpected message_payload: %q", v)
+	}
+}
@@ -0,0 +1,135 @@
+// Copyright 2015 go-smpp authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package smpp
+
+import (
+	"bytes"
+	"sync"
+	"time"
+
+	"github.com/florentchauveau/go-smpp/smpp/pdu"
+	"github.com/florentchauveau/go-smpp/smpp/pdu/pdufield"
+)
+
+// DefaultReassemblyTimeout is how long a Reassembler keeps the parts of
+// an incomplete message when Timeout is not set.
+const DefaultReassemblyTimeout = time.Minute
+
+// Reassembler reassembles concatenated mobile originated messages, e.g.
+// deliver_sm PDUs received by a Receiver or Transceiver handler. Parts
+// may arrive in any order, and both 8-bit and 16-bit reference numbers
+// are supported.
+//
+// Messages are concatenated either with a UDH, or with the sar_msg_ref_num,
+// sar_total_segments and sar_segment_seqnum TLVs. Both kinds are grouped
+// independently.
+//
+// The zero value is ready to use. A Reassembler is safe for concurrent use.
+type Reassembler struct {
+	Timeout time.Duration // Time to wait for missing parts, default 1 minute.
+
+	mu      sync.Mutex
+	holders map[reassemblyKey]*MergeHolder
+}
+
+// reassemblyKey identifies a concatenated message. The reference number
+// is only unique per sender, so the source address is part of the key.
+type reassemblyKey struct {
+	src   string
+	sar   bool
+	ref   int
+	total int
+}
+
+// Add adds the given PDU to the reassembler. It returns true and the
+// full text when the PDU completes a message, or when it is not part of a
+// concatenated message. It returns false while parts are missing, or if
+// the PDU has no short_message field.
+//
+// Incomplete messages older than Timeout are discarded.
+func (r *Reassembler) Add(p pdu.Body) (complete bool, text string) {
+	f := p.Fields()
+	sm := f[pdufield.ShortMessage]
+	if sm == nil {
+		return false, ""
+	}
+	var (
+		concatenated, sar bool
+		ref, total, part  int
+	)
+	if udh := p.UDH(); udh != nil {
+		concatenated, ref, total, part = udh.IsConcatenated()
+	}
+	if !concatenated {
+		sref, stotal, sseq, ok := p.TLVFields().SAR()
+		if !ok {
+			return true, sm.String()
+		}
+		sar, ref, total, part = true, int(sref), int(stotal), int(sseq)
+	}
+	if part < 1 || part > total {
+		return false, ""
+	}
+	src, _ := f.String(pdufield.SourceAddr)
+	key := reassemblyKey{src: src, sar: sar, ref: ref, total: total}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.expire()
+	mh, ok := r.holders[key]
+	if !ok {
+		mh = &MergeHolder{MessageID: ref, PartsCount: total}
+		r.holders[key] = mh
+	}
+	mh.LastWriteTime = time.Now()
+	for _, mp := range mh.MessageParts {
+		if mp.PartID == part {
+			return false, "" // duplicate
+		}
+	}
+	mh.MessageParts = append(mh.MessageParts, &MessagePart{
+		PartID: part,
+		Data:   bytes.NewBuffer(sm.Bytes()),
+	})
+	if len(mh.MessageParts) != mh.PartsCount {
+		return false, ""
+	}
+	delete(r.holders, key)
+	ordered := make([]*bytes.Buffer, total)
+	for _, mp := range mh.MessageParts {
+		ordered[mp.PartID-1] = mp.Data
+	}
+	var buf bytes.Buffer
+	for _, b := range ordered {
+		buf.Write(b.Bytes())
+	}
+	return true, buf.String()
+}
+
+// Pending returns the number of incomplete messages being held.
+func (r *Reassembler) Pending() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.expire()
+	return len(r.holders)
+}
+
+// expire removes expired incomplete messages. Must be called with the
+// lock held.
+func (r *Reassembler) expire() {
+	if r.holders == nil {
+		r.holders = make(map[reassemblyKey]*MergeHolder)
+		return
+	}
+	timeout := r.Timeout
+	if timeout == 0 {
+		timeout = DefaultReassemblyTimeout
+	}
+	for k, mh := range r.holders {
+		if time.Since(mh.LastWriteTime) > timeout {
+			delete(r.holders, k)
+		}
+	}
+}
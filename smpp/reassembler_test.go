@@ -0,0 +1,126 @@
+// Copyright 2015 go-smpp authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package smpp
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/florentchauveau/go-smpp/smpp/pdu"
+	"github.com/florentchauveau/go-smpp/smpp/pdu/pdufield"
+	"github.com/florentchauveau/go-smpp/smpp/pdu/pdutlv"
+)
+
+func newConcatenatedDeliverSM(src string, ref uint16, total, part int, text string) pdu.Body {
+	p := pdu.NewDeliverSM()
+	f := p.Fields()
+	udh := pdufield.NewUDHConcatenatedShortMessage(ref, total, part)
+	_ = f.Set(pdufield.SourceAddr, src)
+	_ = f.Set(pdufield.ESMClass, pdufield.ESMClassUDHIndicator)
+	_ = f.Set(pdufield.GSMUserData, &udh)
+	_ = f.Set(pdufield.ShortMessage, []byte(text))
+	return p
+}
+
+func TestReassembler(t *testing.T) {
+	for _, ref := range []uint16{0x12, 0x1234} {
+		var r Reassembler
+		parts := []pdu.Body{
+			newConcatenatedDeliverSM("root", ref, 3, 3, "sit amet"),
+			newConcatenatedDeliverSM("root", ref, 3, 1, "Lorem "),
+			newConcatenatedDeliverSM("other", ref, 3, 1, "Other "),
+			newConcatenatedDeliverSM("root", ref, 3, 1, "Lorem "), // duplicate
+			newConcatenatedDeliverSM("root", ref, 3, 2, "ipsum dolor "),
+		}
+		for i, p := range parts[:len(parts)-1] {
+			if complete, _ := r.Add(p); complete {
+				t.Fatalf("ref %#x: unexpected complete message at part %d", ref, i)
+			}
+		}
+		complete, text := r.Add(parts[len(parts)-1])
+		if !complete {
+			t.Fatalf("ref %#x: message not complete", ref)
+		}
+		if want := "Lorem ipsum dolor sit amet"; text != want {
+			t.Fatalf("ref %#x: unexpected text: want %q, have %q", ref, want, text)
+		}
+		if n := r.Pending(); n != 1 {
+			t.Fatalf("ref %#x: unexpected pending messages: want 1, have %d", ref, n)
+		}
+	}
+}
+
+func TestReassemblerSinglePart(t *testing.T) {
+	var r Reassembler
+	p := pdu.NewDeliverSM()
+	_ = p.Fields().Set(pdufield.ShortMessage, []byte("hello"))
+	complete, text := r.Add(p)
+	if !complete || text != "hello" {
+		t.Fatalf("unexpected result: want true %q, have %t %q", "hello", complete, text)
+	}
+}
+
+func TestReassemblerTimeout(t *testing.T) {
+	r := Reassembler{Timeout: 10 * time.Millisecond}
+	r.Add(newConcatenatedDeliverSM("root", 1, 2, 1, "Lorem "))
+	time.Sleep(20 * time.Millisecond)
+	if complete, _ := r.Add(newConcatenatedDeliverSM("root", 1, 2, 2, "ipsum")); complete {
+		t.Fatal("unexpected complete message after timeout")
+	}
+	if n := r.Pending(); n != 1 {
+		t.Fatalf("unexpected pending messages: want 1, have %d", n)
+	}
+}
+
+func newSARDeliverSM(src string, ref uint16, total, part int, text string) pdu.Body {
+	p := pdu.NewDeliverSM()
+	f := p.Fields()
+	_ = f.Set(pdufield.SourceAddr, src)
+	_ = f.Set(pdufield.ShortMessage, []byte(text))
+	tlv := p.TLVFields()
+	_ = tlv.Set(pdutlv.TagSarMsgRefNum, []byte{byte(ref >> 8), byte(ref)})
+	_ = tlv.Set(pdutlv.TagSarTotalSegments, uint8(total))
+	_ = tlv.Set(pdutlv.TagSarSegmentSeqnum, uint8(part))
+	return p
+}
+
+func TestReassemblerSAR(t *testing.T) {
+	var r Reassembler
+	parts := []pdu.Body{
+		newSARDeliverSM("root", 0x1234, 3, 2, "ipsum "),
+		newConcatenatedDeliverSM("root", 0x1234, 3, 2, "UDH part 2 "),
+		newSARDeliverSM("root", 0x1234, 3, 3, "dolor"),
+		newConcatenatedDeliverSM("root", 0x1234, 3, 1, "UDH part 1 "),
+	}
+	for i, p := range parts {
+		if complete, _ := r.Add(p); complete {
+			t.Fatalf("unexpected complete message at part %d", i)
+		}
+	}
+	// Decode the last SAR part off the wire to make sure TLVs are exposed.
+	var b bytes.Buffer
+	if err := newSARDeliverSM("root", 0x1234, 3, 1, "Lorem ").SerializeTo(&b); err != nil {
+		t.Fatal(err)
+	}
+	p, err := pdu.Decode(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	complete, text := r.Add(p)
+	if !complete {
+		t.Fatal("SAR message not complete")
+	}
+	if want := "Lorem ipsum dolor"; text != want {
+		t.Fatalf("unexpected text: want %q, have %q", want, text)
+	}
+	complete, text = r.Add(newConcatenatedDeliverSM("root", 0x1234, 3, 3, "UDH part 3"))
+	if !complete {
+		t.Fatal("UDH message not complete")
+	}
+	if want := "UDH part 1 UDH part 2 UDH part 3"; text != want {
+		t.Fatalf("unexpected text: want %q, have %q", want, text)
+	}
+}
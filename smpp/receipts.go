@@ -0,0 +1,152 @@
+// Copyright 2015 go-smpp authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package smpp
+
+import (
+	"sync"
+	"time"
+
+	"github.com/florentchauveau/go-smpp/smpp/pdu"
+	"github.com/florentchauveau/go-smpp/smpp/pdu/pdufield"
+	"github.com/florentchauveau/go-smpp/smpp/pdu/pdutlv"
+)
+
+// DefaultReceiptTTL is how long a ReceiptTracker waits for the delivery
+// receipt of a message when TTL is not set.
+const DefaultReceiptTTL = 24 * time.Hour
+
+// orphanReceiptTTL is how long unmatched receipts are kept.
+const orphanReceiptTTL = 10 * time.Second
+
+// ReceiptFunc is called by a ReceiptTracker when a delivery receipt
+// matches a submitted message. The receipt is nil if the short message
+// of p could not be parsed.
+type ReceiptFunc func(sm *ShortMessage, r *pdu.DeliveryReceipt, p pdu.Body)
+
+// ReceiptTracker correlates delivery receipts with the messages they
+// refer to, by the message ID returned by the SMSC on submit.
+//
+// When set on a Transmitter or Transceiver, messages submitted with a
+// registered delivery are tracked, and deliver_sm receipts are matched
+// before being passed to the handler. Unmatched receipts are kept for a
+// few seconds, in case they arrive before the message is tracked.
+//
+// The zero value is ready to use. A ReceiptTracker is safe for
+// concurrent use.
+type ReceiptTracker struct {
+	TTL     time.Duration // Time to wait for receipts, default 24 hours.
+	Handler ReceiptFunc   // Called for matching receipts.
+
+	mu      sync.Mutex
+	entries map[string]*receiptEntry
+	orphans map[string]*receiptEntry
+	swept   time.Time
+}
+
+type receiptEntry struct {
+	sm      *ShortMessage
+	r       *pdu.DeliveryReceipt
+	p       pdu.Body
+	expires time.Time
+}
+
+// Track adds the given message, which must have a response, to the
+// tracker. Messages without a message ID are ignored.
+func (rt *ReceiptTracker) Track(sm *ShortMessage) {
+	id := sm.RespID()
+	if id == "" {
+		return
+	}
+	rt.mu.Lock()
+	rt.expire()
+	if o := rt.orphans[id]; o != nil && time.Now().Before(o.expires) {
+		delete(rt.orphans, id)
+		rt.mu.Unlock()
+		rt.handle(sm, o.r, o.p)
+		return
+	}
+	if rt.entries == nil {
+		rt.entries = make(map[string]*receiptEntry)
+	}
+	rt.entries[id] = &receiptEntry{sm: sm, expires: time.Now().Add(rt.ttl())}
+	rt.mu.Unlock()
+}
+
+// Match looks up the message the given delivery receipt refers to, by
+// its receipted_message_id TLV or the id of the receipt text. If found,
+// the message is removed from the tracker, Handler is called and Match
+// returns true.
+func (rt *ReceiptTracker) Match(p pdu.Body) bool {
+	if !pdu.IsDeliveryReceipt(p) {
+		return false
+	}
+	f := p.Fields()
+	var r *pdu.DeliveryReceipt
+	if text, ok := f.String(pdufield.ShortMessage); ok {
+		r, _ = pdu.ParseDeliveryReceipt(text)
+	}
+	id, _ := p.TLVFields().String(pdutlv.TagReceiptedMessageID)
+	if id == "" && r != nil {
+		id = r.ID
+	}
+	if id == "" {
+		return false
+	}
+	now := time.Now()
+	rt.mu.Lock()
+	rt.expire()
+	e := rt.entries[id]
+	delete(rt.entries, id)
+	if e == nil || now.After(e.expires) {
+		if rt.orphans == nil {
+			rt.orphans = make(map[string]*receiptEntry)
+		}
+		rt.orphans[id] = &receiptEntry{r: r, p: p, expires: now.Add(orphanReceiptTTL)}
+		rt.mu.Unlock()
+		return false
+	}
+	rt.mu.Unlock()
+	rt.handle(e.sm, r, p)
+	return true
+}
+
+func (rt *ReceiptTracker) handle(sm *ShortMessage, r *pdu.DeliveryReceipt, p pdu.Body) {
+	if rt.Handler != nil {
+		rt.Handler(sm, r, p)
+	}
+}
+
+// Pending returns the number of messages waiting for a receipt.
+func (rt *ReceiptTracker) Pending() int {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	rt.swept = time.Time{}
+	rt.expire()
+	return len(rt.entries)
+}
+
+func (rt *ReceiptTracker) ttl() time.Duration {
+	if rt.TTL > 0 {
+		return rt.TTL
+	}
+	return DefaultReceiptTTL
+}
+
+// expire discards expired entries and orphans, sweeping at most every
+// few seconds. Must be called with mu held.
+func (rt *ReceiptTracker) expire() {
+	now := time.Now()
+	if now.Sub(rt.swept) < min(rt.ttl(), orphanReceiptTTL) {
+		return
+	}
+	rt.swept = now
+	for _, m := range []map[string]*receiptEntry{rt.entries, rt.orphans} {
+		for id, e := range m {
+			if now.After(e.expires) {
+				delete(m, id)
+			}
+		}
+	}
+}
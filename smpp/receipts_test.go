@@ -0,0 +1,206 @@
+// Copyright 2015 go-smpp authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package smpp
+
+import (
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/florentchauveau/go-smpp/smpp/pdu"
+	"github.com/florentchauveau/go-smpp/smpp/pdu/pdufield"
+	"github.com/florentchauveau/go-smpp/smpp/pdu/pdutext"
+	"github.com/florentchauveau/go-smpp/smpp/smpptest"
+)
+
+func newTrackedMessage(id string) *ShortMessage {
+	sm := &ShortMessage{Register: pdufield.FinalDeliveryReceipt}
+	r := pdu.NewSubmitSMResp()
+	_ = r.Fields().Set(pdufield.MessageID, id)
+	sm.resp.p = r
+	return sm
+}
+
+func TestReceiptTracker(t *testing.T) {
+	var matched []*ShortMessage
+	rt := &ReceiptTracker{
+		Handler: func(sm *ShortMessage, r *pdu.DeliveryReceipt, p pdu.Body) {
+			if r == nil || r.State != pdu.DeliveredState {
+				t.Fatalf("unexpected receipt: %#v", r)
+			}
+			matched = append(matched, sm)
+		},
+	}
+	sm := newTrackedMessage("abc")
+	rt.Track(sm)
+	rt.Track(newTrackedMessage("def"))
+	if n := rt.Pending(); n != 2 {
+		t.Fatalf("unexpected pending: want 2, have %d", n)
+	}
+	receipt := &pdu.DeliveryReceipt{ID: "abc", State: pdu.DeliveredState}
+	if !rt.Match(pdu.BuildDeliveryReceipt("foobar", "root", receipt)) {
+		t.Fatal("receipt not matched")
+	}
+	if len(matched) != 1 || matched[0] != sm {
+		t.Fatalf("unexpected matched messages: %v", matched)
+	}
+	if rt.Match(pdu.BuildDeliveryReceipt("foobar", "root", receipt)) {
+		t.Fatal("receipt matched twice")
+	}
+	if n := rt.Pending(); n != 1 {
+		t.Fatalf("unexpected pending: want 1, have %d", n)
+	}
+	// Receipt before the message is tracked.
+	receipt.ID = "ghi"
+	rt.Match(pdu.BuildDeliveryReceipt("foobar", "root", receipt))
+	rt.Track(newTrackedMessage("ghi"))
+	if len(matched) != 2 {
+		t.Fatalf("early receipt not matched: have %d matches", len(matched))
+	}
+}
+
+func TestReceiptTrackerTTL(t *testing.T) {
+	rt := &ReceiptTracker{TTL: 10 * time.Millisecond}
+	rt.Track(newTrackedMessage("abc"))
+	time.Sleep(20 * time.Millisecond)
+	if n := rt.Pending(); n != 0 {
+		t.Fatalf("unexpected pending: want 0, have %d", n)
+	}
+	receipt := &pdu.DeliveryReceipt{ID: "abc", State: pdu.DeliveredState}
+	if rt.Match(pdu.BuildDeliveryReceipt("foobar", "root", receipt)) {
+		t.Fatal("expired message matched")
+	}
+}
+
+func TestTransceiverReceipts(t *testing.T) {
+	s := smpptest.NewUnstartedServer()
+	s.Handler = func(c smpptest.Conn, p pdu.Body) {
+		switch p.Header().ID {
+		case pdu.SubmitSMID:
+			r := pdu.NewSubmitSMResp()
+			r.Header().Seq = p.Header().Seq
+			_ = r.Fields().Set(pdufield.MessageID, "foobar")
+			_ = c.Write(r)
+			pf := p.Fields()
+			_ = c.Write(pdu.BuildDeliveryReceipt(
+				pf[pdufield.DestinationAddr].String(),
+				pf[pdufield.SourceAddr].String(),
+				&pdu.DeliveryReceipt{ID: "foobar", Sub: 1, Dlvrd: 1, State: pdu.DeliveredState},
+			))
+		default:
+			smpptest.EchoHandler(c, p)
+		}
+	}
+	s.Start()
+	defer s.Close()
+	done := make(chan *ShortMessage, 1)
+	tc := &Transceiver{
+		Addr:   s.Addr(),
+		User:   smpptest.DefaultUser,
+		Passwd: smpptest.DefaultPasswd,
+		Receipts: &ReceiptTracker{
+			Handler: func(sm *ShortMessage, r *pdu.DeliveryReceipt, p pdu.Body) {
+				done <- sm
+			},
+		},
+	}
+	defer tc.Close()
+	conn := <-tc.Bind()
+	switch conn.Status() {
+	case Connected:
+	default:
+		t.Fatal(conn.Error())
+	}
+	sm, err := tc.Submit(&ShortMessage{
+		Src:      "root",
+		Dst:      "foobar",
+		Text:     pdutext.Raw("Lorem ipsum"),
+		Register: pdufield.FinalDeliveryReceipt,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case have := <-done:
+		if have != sm {
+			t.Fatalf("unexpected message: want %p, have %p", sm, have)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("receipt not correlated")
+	}
+}
+
+func TestSubmitLongMsgReceiptsEncodeFallback(t *testing.T) {
+	var ids atomic.Int32
+	s := smpptest.NewUnstartedServer()
+	s.Handler = func(c smpptest.Conn, p pdu.Body) {
+		switch p.Header().ID {
+		case pdu.SubmitSMID:
+			id := strconv.Itoa(int(ids.Add(1)))
+			r := pdu.NewSubmitSMResp()
+			r.Header().Seq = p.Header().Seq
+			_ = r.Fields().Set(pdufield.MessageID, id)
+			_ = c.Write(r)
+			pf := p.Fields()
+			_ = c.Write(pdu.BuildDeliveryReceipt(
+				pf[pdufield.DestinationAddr].String(),
+				pf[pdufield.SourceAddr].String(),
+				&pdu.DeliveryReceipt{ID: id, Sub: 1, Dlvrd: 1, State: pdu.DeliveredState},
+			))
+		default:
+			smpptest.EchoHandler(c, p)
+		}
+	}
+	s.Start()
+	defer s.Close()
+	done := make(chan *ShortMessage, 2)
+	tc := &Transceiver{
+		Addr:   s.Addr(),
+		User:   smpptest.DefaultUser,
+		Passwd: smpptest.DefaultPasswd,
+		Receipts: &ReceiptTracker{
+			Handler: func(sm *ShortMessage, r *pdu.DeliveryReceipt, p pdu.Body) {
+				done <- sm
+			},
+		},
+	}
+	tc.EncodeFallback = []pdutext.DataCoding{pdutext.UCS2Type}
+	defer tc.Close()
+	conn := <-tc.Bind()
+	switch conn.Status() {
+	case Connected:
+	default:
+		t.Fatal(conn.Error())
+	}
+	parts, err := tc.SubmitLongMsg(&ShortMessage{
+		Src:      "root",
+		Dst:      "foobar",
+		Text:     pdutext.GSM7(strings.Repeat("x", 80) + "\U0001F600"),
+		Register: pdufield.FinalDeliveryReceipt,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parts) != 2 {
+		t.Fatalf("unexpected number of parts: want 2, have %d", len(parts))
+	}
+	for i := range parts {
+		if want, have := strconv.Itoa(i+1), parts[i].RespID(); have != want {
+			t.Fatalf("unexpected message id of part %d: want %q, have %q", i, want, have)
+		}
+	}
+	for range parts {
+		select {
+		case sm := <-done:
+			if sm.PartText() == "" || sm.RespID() == "" {
+				t.Fatalf("unexpected message: part %q, id %q", sm.PartText(), sm.RespID())
+			}
+		case <-time.After(time.Second):
+			t.Fatal("receipt not correlated")
+		}
+	}
+}
@@ -13,22 +13,31 @@ import (
 
 	"github.com/florentchauveau/go-smpp/smpp/pdu"
 	"github.com/florentchauveau/go-smpp/smpp/pdu/pdufield"
+	"github.com/florentchauveau/go-smpp/smpp/pdu/pdutlv"
 )
 
 // Receiver implements an SMPP client receiver.
 type Receiver struct {
 	Addr                 string
+	Addrs                []string // Failover server addresses, tried in order after Addr.
 	User                 string
 	Passwd               string
 	SystemType           string
-	EnquireLink          time.Duration
+	BindTLVs             pdutlv.Fields // Optional parameters of the bind request, e.g. vendor-specific.
+	EnquireLink          time.Duration // Enquire link interval, default 10s, negative to disable.
 	EnquireLinkTimeout   time.Duration // Time after last EnquireLink response when connection considered down
 	BindInterval         time.Duration // Binding retry interval
+	BindTimeout          time.Duration // Bind response timeout, optional.
+	ConnectTimeout       time.Duration // TCP connection timeout, optional.
 	MergeInterval        time.Duration // Time in which Receiver waits for the parts of the long messages
 	MergeCleanupInterval time.Duration // How often to cleanup expired message parts
 	TLS                  *tls.Config
+	Dialer               Dialer // Opens connections instead of TCP, ignoring ConnectTimeout, optional.
 	Handler              HandlerFunc
+	HandlerWorkers       int // Goroutines calling Handler concurrently, so that a slow one does not block reading, optional.
+	HandlerQueue         int // PDUs waiting for a HandlerWorkers goroutine before reading waits, default HandlerWorkers.
 	SkipAutoRespondIDs   []pdu.ID
+	DecodeOptions        pdufield.DecodeOptions // PDU decoding options, optional.
 
 	chanClose chan struct{}
 
@@ -80,12 +89,17 @@ func (r *Receiver) Bind() <-chan ConnStatus {
 
 	c := &client{
 		Addr:               r.Addr,
+		Addrs:              r.Addrs,
 		TLS:                r.TLS,
+		Dialer:             r.Dialer,
 		EnquireLink:        r.EnquireLink,
 		EnquireLinkTimeout: r.EnquireLinkTimeout,
 		Status:             make(chan ConnStatus, 1),
 		BindFunc:           r.bindFunc,
 		BindInterval:       r.BindInterval,
+		BindTimeout:        r.BindTimeout,
+		ConnectTimeout:     r.ConnectTimeout,
+		DecodeOptions:      r.DecodeOptions,
 	}
 	r.cl.client = c
 
@@ -111,7 +125,7 @@ func (r *Receiver) bindFunc(c Conn) error {
 	_ = f.Set(pdufield.SystemID, r.User)
 	_ = f.Set(pdufield.Password, r.Passwd)
 	_ = f.Set(pdufield.SystemType, r.SystemType)
-	resp, err := bind(c, p)
+	resp, err := bind(c, p, r.BindTLVs)
 	if err != nil {
 		return err
 	}
@@ -119,6 +133,7 @@ func (r *Receiver) bindFunc(c Conn) error {
 		return fmt.Errorf("unexpected response for BindReceiver: %s",
 			resp.Header().ID)
 	}
+	r.cl.setPeer(resp)
 
 	// Clean the map in case of rebind, because message id numbering resets after reconnection
 	// and older IDs are no longer valid
@@ -155,6 +170,12 @@ func (r *Receiver) handlePDU() {
 		orderedBodies    []*bytes.Buffer
 	)
 	autoRespondDeliver := !idInList(pdu.DeliverSMID, r.SkipAutoRespondIDs)
+	handler := r.Handler
+	if r.HandlerWorkers > 0 {
+		hp := newHandlerPool(r.Handler, r.HandlerWorkers, r.HandlerQueue)
+		defer hp.close()
+		handler = hp.handle
+	}
 
 loop:
 	for {
@@ -169,7 +190,7 @@ loop:
 		}
 
 		if r.MergeInterval == 0 { // Handle the PDU if merging is not needed
-			r.Handler(p)
+			handler(p)
 			continue
 		}
 
@@ -181,11 +202,11 @@ loop:
 
 		udh = p.UDH()
 		if udh == nil { // Check if GSMUserData is present inside the PDU, do not try to merge if it's not
-			r.Handler(p)
+			handler(p)
 			continue
 		}
 		if concatenated, ref, total, part = udh.IsConcatenated(); !concatenated {
-			r.Handler(p)
+			handler(p)
 			continue
 		}
 
@@ -228,7 +249,7 @@ loop:
 		_ = p.Fields().Set(pdufield.ShortMessage, buf.Bytes())
 
 		// Handle
-		r.Handler(p)
+		handler(p)
 	}
 }
 
@@ -262,3 +283,38 @@ func (r *Receiver) Close() error {
 	close(r.chanClose)
 	return r.cl.Close()
 }
+
+// State returns the current connection state, or Disconnected if
+// Bind has not been called.
+func (r *Receiver) State() ConnStatusID {
+	r.cl.Lock()
+	defer r.cl.Unlock()
+	if r.cl.client == nil {
+		return Disconnected
+	}
+	return r.cl.State()
+}
+
+// PeerInterfaceVersion returns the SMPP version advertised by the server
+// in its bind response, e.g. 0x34 for 3.4. It returns false if the server
+// did not send the sc_interface_version TLV, or if not bound yet.
+func (r *Receiver) PeerInterfaceVersion() (uint8, bool) {
+	r.cl.Lock()
+	defer r.cl.Unlock()
+	if r.cl.client == nil {
+		return 0, false
+	}
+	return r.cl.PeerInterfaceVersion()
+}
+
+// PeerSystemID returns the system_id the server identified itself with
+// in its bind response, e.g. for logging. It returns an empty string if
+// not bound yet.
+func (r *Receiver) PeerSystemID() string {
+	r.cl.Lock()
+	defer r.cl.Unlock()
+	if r.cl.client == nil {
+		return ""
+	}
+	return r.cl.PeerSystemID()
+}
@@ -9,6 +9,8 @@ import (
 	"time"
 
 	"github.com/florentchauveau/go-smpp/smpp/pdu"
+	"github.com/florentchauveau/go-smpp/smpp/pdu/pdufield"
+	"github.com/florentchauveau/go-smpp/smpp/pdu/pdutlv"
 	"github.com/florentchauveau/go-smpp/smpp/smpptest"
 )
 
@@ -44,3 +46,112 @@ func TestReceiver(t *testing.T) {
 		t.Fatal("timeout waiting for server to echo")
 	}
 }
+
+func TestReceiverDeliverTLVs(t *testing.T) {
+	s := smpptest.NewServer()
+	defer s.Close()
+	rc := make(chan pdu.Body)
+	r := &Receiver{
+		Addr:    s.Addr(),
+		User:    smpptest.DefaultUser,
+		Passwd:  smpptest.DefaultPasswd,
+		Handler: func(p pdu.Body) { rc <- p },
+	}
+	defer r.Close()
+	conn := <-r.Bind()
+	switch conn.Status() {
+	case Connected:
+	default:
+		t.Fatal(conn.Error())
+	}
+	nec := pdutlv.NetworkErrorCode{Network: pdutlv.ErrorNetworkGSM, Code: 0x0022}
+	p := pdu.NewDeliverSM()
+	f := p.Fields()
+	_ = f.Set(pdufield.SourceAddr, "root")
+	_ = f.Set(pdufield.DestinationAddr, "foobar")
+	_ = f.Set(pdufield.ESMClass, pdufield.ESMClassSMSCDeliveryReceipt)
+	_ = f.Set(pdufield.ShortMessage, "Lorem ipsum")
+	tlv := p.TLVFields()
+	_ = tlv.Set(pdutlv.TagReceiptedMessageID, pdutlv.CString("foobar"))
+	_ = tlv.Set(pdutlv.TagMessageStateOption, uint8(pdu.UndeliverableState))
+	_ = tlv.Set(pdutlv.TagNetworkErrorCode, nec)
+	_ = tlv.Set(pdutlv.TagDeliveryFailureReason, pdutlv.DeliveryPermanentNetError)
+	s.BroadcastMessage(p)
+	var m pdu.Body
+	select {
+	case m = <-rc:
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for deliver_sm")
+	}
+	tlv = m.TLVFields()
+	if v, ok := tlv.ReceiptedMessageID(); !ok || v != "foobar" {
+		t.Fatalf("unexpected receipted_message_id: want foobar, have %q (%t)", v, ok)
+	}
+	if v, ok := tlv.MessageState(); !ok || pdu.MessageState(v) != pdu.UndeliverableState {
+		t.Fatalf("unexpected message_state: want %d, have %d (%t)", pdu.UndeliverableState, v, ok)
+	}
+	if v, ok := tlv.NetworkErrorCode(); !ok || v != nec {
+		t.Fatalf("unexpected network_error_code: want %+v, have %+v (%t)", nec, v, ok)
+	}
+	if v, ok := tlv.DeliveryFailureReason(); !ok || v != pdutlv.DeliveryPermanentNetError {
+		t.Fatalf("unexpected delivery_failure_reason: want %d, have %d (%t)", pdutlv.DeliveryPermanentNetError, v, ok)
+	}
+}
+
+func TestReceiverHandlerWorkers(t *testing.T) {
+	resps := make(chan uint32, 3)
+	s := smpptest.NewUnstartedServer()
+	s.Handlers = map[pdu.ID]smpptest.HandlerFunc{
+		pdu.DeliverSMRespID: func(c smpptest.Conn, p pdu.Body) {
+			resps <- p.Header().Seq
+		},
+	}
+	s.Start()
+	defer s.Close()
+	release := make(chan struct{})
+	defer close(release)
+	rc := make(chan string, 3)
+	r := &Receiver{
+		Addr:   s.Addr(),
+		User:   smpptest.DefaultUser,
+		Passwd: smpptest.DefaultPasswd,
+		Handler: func(p pdu.Body) {
+			src, _ := p.Fields().String(pdufield.SourceAddr)
+			if src == "slow" {
+				<-release
+			}
+			rc <- src
+		},
+		HandlerWorkers: 2,
+	}
+	defer r.Close()
+	conn := <-r.Bind()
+	switch conn.Status() {
+	case Connected:
+	default:
+		t.Fatal(conn.Error())
+	}
+	for _, src := range []string{"slow", "foo", "bar"} {
+		p := pdu.NewDeliverSM()
+		_ = p.Fields().Set(pdufield.SourceAddr, src)
+		_ = p.Fields().Set(pdufield.ShortMessage, "Lorem ipsum")
+		s.BroadcastMessage(p)
+	}
+	for _, want := range []string{"foo", "bar"} {
+		select {
+		case have := <-rc:
+			if have != want {
+				t.Fatalf("unexpected PDU handled: want %q, have %q", want, have)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timeout waiting for %q behind a slow handler", want)
+		}
+	}
+	for range 3 {
+		select {
+		case <-resps:
+		case <-time.After(time.Second):
+			t.Fatal("timeout waiting for deliver_sm_resp")
+		}
+	}
+}
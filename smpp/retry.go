@@ -0,0 +1,93 @@
+// Copyright 2015 go-smpp authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package smpp
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/florentchauveau/go-smpp/smpp/pdu"
+)
+
+// RetryPolicy configures the retries of SubmitWithRetry.
+//
+// The delay before each retry starts at Backoff and doubles on every
+// attempt, up to MaxBackoff.
+type RetryPolicy struct {
+	MaxAttempts int           // Attempts in total, default 3.
+	Backoff     time.Duration // Delay before the first retry, default 1s.
+	MaxBackoff  time.Duration // Max delay between attempts, optional.
+
+	// Retry reports whether a failed submit is retried, default
+	// IsTemporary.
+	Retry func(err error) bool
+}
+
+// IsTemporary reports whether err is a command status the SMSC uses to
+// reject a message for now, ESME_RTHROTTLED or ESME_RMSGQFUL, so the
+// submit may succeed later.
+func IsTemporary(err error) bool {
+	return errors.Is(err, pdu.StatusThrottled) || errors.Is(err, pdu.StatusMsgQFul)
+}
+
+// attempts returns the attempts in total, or the default.
+func (p RetryPolicy) attempts() int {
+	if p.MaxAttempts <= 0 {
+		return 3
+	}
+	return p.MaxAttempts
+}
+
+// retry reports whether err is retried.
+func (p RetryPolicy) retry(err error) bool {
+	if p.Retry == nil {
+		return IsTemporary(err)
+	}
+	return p.Retry(err)
+}
+
+// delay returns the delay before the given retry, starting at 1.
+func (p RetryPolicy) delay(retry int) time.Duration {
+	d := p.Backoff
+	if d <= 0 {
+		d = time.Second
+	}
+	for i := 1; i < retry && (p.MaxBackoff <= 0 || d < p.MaxBackoff); i++ {
+		d *= 2
+	}
+	if p.MaxBackoff > 0 && d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+	return d
+}
+
+// SubmitWithRetry is like Submit, and submits sm again after a delay
+// when the SMSC rejects it with a temporary error, as configured by
+// policy. Permanent errors, e.g. an invalid destination address, are
+// returned right away, and so is the error of the last attempt.
+//
+// It returns ctx.Err() if ctx is done while waiting to retry.
+func (t *Transmitter) SubmitWithRetry(ctx context.Context, sm *ShortMessage, policy RetryPolicy) (*ShortMessage, error) {
+	attempts := policy.attempts()
+	dstList := sm.DstList // Submit adds Dst to it for submit_multi
+	for attempt := 1; ; attempt++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		resp, err := t.Submit(sm)
+		if err == nil || attempt == attempts || !policy.retry(err) {
+			return resp, err
+		}
+		sm.DstList = dstList
+		timer := time.NewTimer(policy.delay(attempt))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		}
+	}
+}
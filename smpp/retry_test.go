@@ -0,0 +1,115 @@
+// Copyright 2015 go-smpp authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package smpp
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/florentchauveau/go-smpp/smpp/pdu"
+	"github.com/florentchauveau/go-smpp/smpp/pdu/pdufield"
+	"github.com/florentchauveau/go-smpp/smpp/pdu/pdutext"
+	"github.com/florentchauveau/go-smpp/smpp/smpptest"
+)
+
+// newRetryServer returns a bound Transmitter to a server replying to
+// submits with the given statuses in order, then ESME_ROK.
+func newRetryServer(t *testing.T, statuses ...pdu.Status) (*Transmitter, *atomic.Int32) {
+	var n atomic.Int32
+	s := smpptest.NewUnstartedServer()
+	s.Handler = func(c smpptest.Conn, p pdu.Body) {
+		if p.Header().ID != pdu.SubmitSMID {
+			smpptest.EchoHandler(c, p)
+			return
+		}
+		r := pdu.NewSubmitSMResp()
+		r.Header().Seq = p.Header().Seq
+		if i := int(n.Add(1)) - 1; i < len(statuses) {
+			r.Header().Status = statuses[i]
+		} else {
+			_ = r.Fields().Set(pdufield.MessageID, "foobar")
+		}
+		_ = c.Write(r)
+	}
+	s.Start()
+	t.Cleanup(s.Close)
+	tx := &Transmitter{
+		Addr:   s.Addr(),
+		User:   smpptest.DefaultUser,
+		Passwd: smpptest.DefaultPasswd,
+	}
+	t.Cleanup(func() { tx.Close() })
+	conn := <-tx.Bind()
+	switch conn.Status() {
+	case Connected:
+	default:
+		t.Fatal(conn.Error())
+	}
+	return tx, &n
+}
+
+func TestSubmitWithRetry(t *testing.T) {
+	tx, n := newRetryServer(t, pdu.StatusThrottled, pdu.StatusMsgQFul)
+	policy := RetryPolicy{MaxAttempts: 3, Backoff: time.Millisecond}
+	sm, err := tx.SubmitWithRetry(context.Background(), &ShortMessage{
+		Src:  "root",
+		Dst:  "foobar",
+		Text: pdutext.Raw("Lorem ipsum"),
+	}, policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id := sm.RespID(); id != "foobar" {
+		t.Fatalf("unexpected message id: want %q, have %q", "foobar", id)
+	}
+	if have := n.Load(); have != 3 {
+		t.Fatalf("unexpected attempts: want 3, have %d", have)
+	}
+}
+
+func TestSubmitWithRetryPermanent(t *testing.T) {
+	tx, n := newRetryServer(t, pdu.StatusThrottled, pdu.StatusInvDstAdr)
+	policy := RetryPolicy{MaxAttempts: 5, Backoff: time.Millisecond}
+	_, err := tx.SubmitWithRetry(context.Background(), &ShortMessage{
+		Src:  "root",
+		Dst:  "foobar",
+		Text: pdutext.Raw("Lorem ipsum"),
+	}, policy)
+	if !errors.Is(err, pdu.StatusInvDstAdr) {
+		t.Fatalf("unexpected error: want %v, have %v", pdu.StatusInvDstAdr, err)
+	}
+	if have := n.Load(); have != 2 {
+		t.Fatalf("unexpected attempts: want 2, have %d", have)
+	}
+}
+
+func TestSubmitWithRetryContext(t *testing.T) {
+	tx, n := newRetryServer(t, pdu.StatusThrottled, pdu.StatusThrottled)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := tx.SubmitWithRetry(ctx, &ShortMessage{
+		Src:  "root",
+		Dst:  "foobar",
+		Text: pdutext.Raw("Lorem ipsum"),
+	}, RetryPolicy{Backoff: time.Minute})
+	if err != context.DeadlineExceeded {
+		t.Fatalf("unexpected error: want %v, have %v", context.DeadlineExceeded, err)
+	}
+	if have := n.Load(); have != 1 {
+		t.Fatalf("unexpected attempts: want 1, have %d", have)
+	}
+}
+
+func TestRetryPolicyDelay(t *testing.T) {
+	p := RetryPolicy{Backoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+	for retry, want := range []time.Duration{100, 200, 400, 800, 1000, 1000} {
+		if have := p.delay(retry + 1); have != want*time.Millisecond {
+			t.Fatalf("unexpected delay for retry %d: want %s, have %s", retry+1, want*time.Millisecond, have)
+		}
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"bytes"
 	"io"
 	"net"
+	"sync"
 
 	"github.com/florentchauveau/go-smpp/smpp/pdu"
 )
@@ -30,6 +31,7 @@ type conn struct {
 	rwc net.Conn
 	r   *bufio.Reader
 	w   *bufio.Writer
+	mu  sync.Mutex // serializes writes of handlers and BroadcastMessage
 }
 
 func newConn(c net.Conn) *conn {
@@ -57,6 +59,8 @@ func (c *conn) Write(p pdu.Body) error {
 	if err != nil {
 		return err
 	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	_, err = io.Copy(c.w, &b)
 	if err != nil {
 		return err
@@ -11,9 +11,11 @@ import (
 	"io"
 	"log"
 	"net"
+	"sync"
 
 	"github.com/florentchauveau/go-smpp/smpp/pdu"
 	"github.com/florentchauveau/go-smpp/smpp/pdu/pdufield"
+	"github.com/florentchauveau/go-smpp/smpp/pdu/pdutlv"
 )
 
 // Default settings.
@@ -29,13 +31,36 @@ type HandlerFunc func(c Conn, m pdu.Body)
 
 // Server is an SMPP server for testing purposes. By default it authenticate
 // clients with the configured credentials, and echoes any other PDUs
-// back to the client.
+// back to the client. PDUs sent before bind, and submits on a receiver
+// bind, are rejected with ESME_RINVBNDSTS.
 type Server struct {
 	User    string
 	Passwd  string
 	TLS     *tls.Config
 	Handler HandlerFunc
 
+	// Handlers, when set, are called instead of Handler for PDUs with
+	// their command ID, e.g. pdu.SubmitSMID. Handler is called for
+	// other PDUs.
+	Handlers map[pdu.ID]HandlerFunc
+
+	// SystemID is sent as the system_id of bind responses, or
+	// DefaultSystemID if empty.
+	SystemID string
+
+	// InterfaceVersion is sent as the sc_interface_version TLV of bind
+	// responses when set.
+	InterfaceVersion uint8
+
+	// BindStatus, when set, is the command status of all bind responses,
+	// rejecting clients regardless of their credentials.
+	BindStatus pdu.Status
+
+	// OnBind, when set, is called with the bind PDU of each client
+	// before it is authenticated, e.g. to inspect its TLVs.
+	OnBind func(p pdu.Body)
+
+	mu    sync.Mutex
 	conns []Conn
 	l     net.Listener
 }
@@ -92,8 +117,8 @@ func (srv *Server) Close() {
 	srv.l.Close()
 }
 
-// Serve accepts new clients and handle them by authenticating the
-// first PDU, expected to be a Bind PDU, then echoing all other PDUs.
+// Serve accepts new clients and handle them by authenticating their
+// Bind PDU, then passing all other PDUs to Handlers or Handler.
 func (srv *Server) Serve() {
 	for {
 		cli, err := srv.l.Accept()
@@ -102,27 +127,31 @@ func (srv *Server) Serve() {
 		}
 
 		c := newConn(cli)
+		srv.mu.Lock()
 		srv.conns = append(srv.conns, c)
+		srv.mu.Unlock()
 		go srv.handle(c)
 	}
 }
 
 // BroadcastMessage broadcasts a test PDU to the all bound clients
 func (srv *Server) BroadcastMessage(p pdu.Body) {
-	for i := range srv.conns {
-		_ = srv.conns[i].Write(p)
+	srv.mu.Lock()
+	conns := append([]Conn(nil), srv.conns...)
+	srv.mu.Unlock()
+	for _, c := range conns {
+		_ = c.Write(p)
 	}
 }
 
-// handle new clients.
+// handle new clients, keeping track of their bind state: PDUs other
+// than bind are rejected with ESME_RINVBNDSTS until the client is
+// bound, and so are submits from receivers. Other PDUs of bound
+// clients are passed to Handlers or Handler, and an unbind returns
+// the client to the unbound state.
 func (srv *Server) handle(c *conn) {
 	defer c.Close()
-	if err := srv.auth(c); err != nil {
-		if err != io.EOF {
-			log.Println("smpptest: server auth failed:", err)
-		}
-		return
-	}
+	var bind pdu.ID // bind command of the client, zero when unbound
 	for {
 		p, err := c.Read()
 		if err != nil {
@@ -131,40 +160,133 @@ func (srv *Server) handle(c *conn) {
 			}
 			break
 		}
-		srv.Handler(c, p)
+		id := p.Header().ID
+		switch {
+		case isBind(id) && bind != 0:
+			_ = c.Write(bindResp(p, pdu.StatusAlyBnd))
+		case isBind(id):
+			if srv.OnBind != nil {
+				srv.OnBind(p)
+			}
+			if err := srv.auth(c, p); err != nil {
+				log.Println("smpptest: server auth failed:", err)
+				return
+			}
+			bind = id
+		case id&pdu.GenericNACKID != 0:
+			if bind != 0 {
+				srv.dispatch(c, p)
+			}
+		case bind == 0, bind == pdu.BindReceiverID && isSubmit(id):
+			_ = c.Write(invBndStsResp(p))
+		default:
+			srv.dispatch(c, p)
+			if id == pdu.UnbindID {
+				bind = 0
+			}
+		}
 	}
 }
 
-// auth authenticate new clients.
-func (srv *Server) auth(c *conn) error {
-	p, err := c.Read()
-	if err != nil {
-		return err
+// dispatch calls the handler of p, from Handlers or Handler.
+func (srv *Server) dispatch(c Conn, p pdu.Body) {
+	if h, ok := srv.Handlers[p.Header().ID]; ok {
+		h(c, p)
+		return
+	}
+	srv.Handler(c, p)
+}
+
+// isBind reports whether id is one of the bind commands.
+func isBind(id pdu.ID) bool {
+	switch id {
+	case pdu.BindTransmitterID, pdu.BindReceiverID, pdu.BindTransceiverID:
+		return true
 	}
+	return false
+}
+
+// isSubmit reports whether id is a command only transmitters and
+// transceivers may send.
+func isSubmit(id pdu.ID) bool {
+	switch id {
+	case pdu.SubmitSMID, pdu.SubmitMultiID, pdu.DataSMID,
+		pdu.QuerySMID, pdu.ReplaceSMID, pdu.CancelSMID:
+		return true
+	}
+	return false
+}
+
+// bindResp returns the response to the bind p with the given status.
+func bindResp(p pdu.Body, status pdu.Status) pdu.Body {
 	var resp pdu.Body
 	switch p.Header().ID {
 	case pdu.BindTransmitterID:
 		resp = pdu.NewBindTransmitterResp()
 	case pdu.BindReceiverID:
 		resp = pdu.NewBindReceiverResp()
-	case pdu.BindTransceiverID:
+	default:
 		resp = pdu.NewBindTransceiverResp()
+	}
+	resp.Header().Seq = p.Header().Seq
+	resp.Header().Status = status
+	return resp
+}
+
+// invBndStsResp returns the ESME_RINVBNDSTS response to p, a
+// generic_nack for commands without a response of their own here.
+func invBndStsResp(p pdu.Body) pdu.Body {
+	var resp pdu.Body
+	switch p.Header().ID {
+	case pdu.SubmitSMID:
+		resp = pdu.NewSubmitSMResp()
+	case pdu.SubmitMultiID:
+		resp = pdu.NewSubmitMultiResp()
+	case pdu.DataSMID:
+		resp = pdu.NewDataSMResp()
+	case pdu.QuerySMID:
+		resp = pdu.NewQuerySMResp()
 	default:
-		return errors.New("unexpected pdu, want bind")
+		resp = pdu.NewGenericNACK()
 	}
+	resp.Header().Seq = p.Header().Seq
+	resp.Header().Status = pdu.StatusInvBndSts
+	return resp
+}
+
+// auth authenticates new clients with the bind p.
+func (srv *Server) auth(c *conn, p pdu.Body) error {
 	f := p.Fields()
 	user := f[pdufield.SystemID]
 	passwd := f[pdufield.Password]
 	if user == nil || passwd == nil {
 		return errors.New("malformed pdu, missing system_id/password")
 	}
-	if user.String() != srv.User {
-		return errors.New("invalid user")
+	var err error
+	resp := bindResp(p, 0)
+	switch {
+	case srv.BindStatus != 0:
+		resp.Header().Status = srv.BindStatus
+		err = srv.BindStatus
+	case user.String() != srv.User:
+		resp.Header().Status = pdu.StatusInvSysID
+		err = errors.New("invalid user")
+	case passwd.String() != srv.Passwd:
+		resp.Header().Status = pdu.StatusInvPaswd
+		err = errors.New("invalid passwd")
+	}
+	if err != nil {
+		_ = c.Write(resp)
+		return err
+	}
+	id := srv.SystemID
+	if id == "" {
+		id = DefaultSystemID
 	}
-	if passwd.String() != srv.Passwd {
-		return errors.New("invalid passwd")
+	_ = resp.Fields().Set(pdufield.SystemID, id)
+	if srv.InterfaceVersion != 0 {
+		_ = resp.TLVFields().Set(pdutlv.TagScInterfaceVersion, srv.InterfaceVersion)
 	}
-	_ = resp.Fields().Set(pdufield.SystemID, DefaultSystemID)
 
 	return c.Write(resp)
 }
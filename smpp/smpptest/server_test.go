@@ -104,3 +104,122 @@ func TestServer(t *testing.T) {
 		}
 	}
 }
+
+func TestServerBindState(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	c, err := net.Dial("tcp", s.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	rw := newConn(c)
+	roundTrip := func(p pdu.Body, id pdu.ID, status pdu.Status) {
+		t.Helper()
+		if err := rw.Write(p); err != nil {
+			t.Fatal(err)
+		}
+		r, err := rw.Read()
+		if err != nil {
+			t.Fatal(err)
+		}
+		h := r.Header()
+		if h.ID != id || h.Status != status || h.Seq != p.Header().Seq {
+			t.Fatalf("unexpected response to %s: want %s %s seq %d, have %s %s seq %d",
+				p.Header().ID, id, status, p.Header().Seq, h.ID, h.Status, h.Seq)
+		}
+	}
+	bind := func(p pdu.Body) pdu.Body {
+		f := p.Fields()
+		_ = f.Set(pdufield.SystemID, DefaultUser)
+		_ = f.Set(pdufield.Password, DefaultPasswd)
+		_ = f.Set(pdufield.InterfaceVersion, 0x34)
+		return p
+	}
+	submit := func() pdu.Body {
+		p := pdu.NewSubmitSM(nil)
+		f := p.Fields()
+		_ = f.Set(pdufield.SourceAddr, "foobar")
+		_ = f.Set(pdufield.DestinationAddr, "bozo")
+		_ = f.Set(pdufield.ShortMessage, pdutext.Latin1("Lorem ipsum"))
+		return p
+	}
+	// before bind
+	roundTrip(submit(), pdu.SubmitSMRespID, pdu.StatusInvBndSts)
+	roundTrip(pdu.NewDataSM(nil), pdu.DataSMRespID, pdu.StatusInvBndSts)
+	roundTrip(pdu.NewEnquireLink(), pdu.GenericNACKID, pdu.StatusInvBndSts)
+	// receiver
+	roundTrip(bind(pdu.NewBindReceiver()), pdu.BindReceiverRespID, 0)
+	roundTrip(submit(), pdu.SubmitSMRespID, pdu.StatusInvBndSts)
+	roundTrip(pdu.NewDataSM(nil), pdu.DataSMRespID, pdu.StatusInvBndSts)
+	roundTrip(bind(pdu.NewBindTransmitter()), pdu.BindTransmitterRespID, pdu.StatusAlyBnd)
+	roundTrip(pdu.NewEnquireLink(), pdu.EnquireLinkID, 0) // echoed
+	roundTrip(pdu.NewUnbind(), pdu.UnbindID, 0)           // echoed
+	// unbound again
+	roundTrip(submit(), pdu.SubmitSMRespID, pdu.StatusInvBndSts)
+	// transmitter
+	roundTrip(bind(pdu.NewBindTransmitter()), pdu.BindTransmitterRespID, 0)
+	roundTrip(submit(), pdu.SubmitSMID, 0) // echoed
+}
+
+func TestServerHandlers(t *testing.T) {
+	s := NewUnstartedServer()
+	s.Handlers = map[pdu.ID]HandlerFunc{
+		pdu.SubmitSMID: func(c Conn, p pdu.Body) {
+			r := pdu.NewSubmitSMResp()
+			r.Header().Seq = p.Header().Seq
+			_ = r.Fields().Set(pdufield.MessageID, "foobar")
+			_ = c.Write(r)
+		},
+	}
+	s.Start()
+	defer s.Close()
+	c, err := net.Dial("tcp", s.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	rw := newConn(c)
+	p := pdu.NewBindTransmitter()
+	f := p.Fields()
+	_ = f.Set(pdufield.SystemID, DefaultUser)
+	_ = f.Set(pdufield.Password, DefaultPasswd)
+	_ = f.Set(pdufield.InterfaceVersion, 0x34)
+	if err = rw.Write(p); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = rw.Read(); err != nil {
+		t.Fatal(err)
+	}
+	// submit_sm, from Handlers
+	p = pdu.NewSubmitSM(nil)
+	f = p.Fields()
+	_ = f.Set(pdufield.SourceAddr, "foobar")
+	_ = f.Set(pdufield.DestinationAddr, "bozo")
+	_ = f.Set(pdufield.ShortMessage, pdutext.Latin1("Lorem ipsum"))
+	if err = rw.Write(p); err != nil {
+		t.Fatal(err)
+	}
+	r, err := rw.Read()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Header().ID != pdu.SubmitSMRespID || r.Header().Seq != p.Header().Seq {
+		t.Fatalf("unexpected response: want %s seq %d, have %s seq %d",
+			pdu.SubmitSMRespID, p.Header().Seq, r.Header().ID, r.Header().Seq)
+	}
+	if id := r.Fields()[pdufield.MessageID]; id == nil || id.String() != "foobar" {
+		t.Fatalf("unexpected message_id: want foobar, have %v", id)
+	}
+	// enquire_link, echoed by Handler
+	p = pdu.NewEnquireLink()
+	if err = rw.Write(p); err != nil {
+		t.Fatal(err)
+	}
+	if r, err = rw.Read(); err != nil {
+		t.Fatal(err)
+	}
+	if r.Header().ID != pdu.EnquireLinkID {
+		t.Fatalf("unexpected response: want %s, have %s", pdu.EnquireLinkID, r.Header().ID)
+	}
+}
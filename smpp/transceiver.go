@@ -11,6 +11,7 @@ import (
 
 	"github.com/florentchauveau/go-smpp/smpp/pdu"
 	"github.com/florentchauveau/go-smpp/smpp/pdu/pdufield"
+	"github.com/florentchauveau/go-smpp/smpp/pdu/pdutlv"
 )
 
 // Transceiver implements an SMPP transceiver.
@@ -18,17 +19,33 @@ import (
 // The API is a combination of the Transmitter and Receiver.
 type Transceiver struct {
 	Addr               string        // Server address in form of host:port.
+	Addrs              []string      // Failover server addresses, tried in order after Addr.
 	User               string        // Username.
 	Passwd             string        // Password.
 	SystemType         string        // System type, default empty.
-	EnquireLink        time.Duration // Enquire link interval, default 10s.
+	BindTLVs           pdutlv.Fields // Optional parameters of the bind request, e.g. vendor-specific.
+	EnquireLink        time.Duration // Enquire link interval, default 10s, negative to disable.
 	EnquireLinkTimeout time.Duration // Time after last EnquireLink response when connection considered down
 	RespTimeout        time.Duration // Response timeout, default 1s.
+	ReadTimeout        time.Duration // Read deadline for each PDU, optional. Should exceed EnquireLink.
+	WriteTimeout       time.Duration // Write deadline for each PDU, optional.
 	BindInterval       time.Duration // Binding retry interval
+	BindTimeout        time.Duration // Bind response timeout, optional.
+	ConnectTimeout     time.Duration // TCP connection timeout, optional.
 	TLS                *tls.Config   // TLS client settings, optional.
+	Dialer             Dialer        // Opens connections instead of TCP, ignoring ConnectTimeout, optional.
 	Handler            HandlerFunc   // Receiver handler, optional.
+	HandlerWorkers     int           // Goroutines calling Handler concurrently, so that a slow one does not block reading, optional.
+	HandlerQueue       int           // PDUs waiting for a HandlerWorkers goroutine before reading waits, default HandlerWorkers.
 	RateLimiter        RateLimiter   // Rate limiter, optional.
 	WindowSize         uint
+	MaxInFlight        uint                   // Max concurrent submits, beyond which callers wait, optional.
+	FailOnMaxInFlight  bool                   // Return ErrMaxInFlight instead of waiting, optional.
+	SeqStart           uint32                 // First sequence number of requests, renumbering them, optional.
+	DecodeOptions      pdufield.DecodeOptions // PDU decoding options, optional.
+	OnWrite            WriteFunc              // Called with the raw bytes of each PDU sent, optional.
+	Receipts           *ReceiptTracker        // Delivery receipt correlation, optional.
+	OnUnmatched        HandlerFunc            // Called with responses matching no pending request, optional.
 
 	Transmitter
 }
@@ -45,15 +62,28 @@ func (t *Transceiver) Bind() <-chan ConnStatus {
 	t.tx.Unlock()
 	c := &client{
 		Addr:               t.Addr,
+		Addrs:              t.Addrs,
 		TLS:                t.TLS,
+		Dialer:             t.Dialer,
 		Status:             make(chan ConnStatus, 1),
 		BindFunc:           t.bindFunc,
 		EnquireLink:        t.EnquireLink,
 		EnquireLinkTimeout: t.EnquireLinkTimeout,
 		RespTimeout:        t.RespTimeout,
+		ReadTimeout:        t.ReadTimeout,
+		WriteTimeout:       t.WriteTimeout,
+		DecodeOptions:      t.DecodeOptions,
+		OnWrite:            t.OnWrite,
+		Receipts:           t.Receipts,
+		OnUnmatched:        t.OnUnmatched,
 		WindowSize:         t.WindowSize,
+		MaxInFlight:        t.MaxInFlight,
+		FailOnMaxInFlight:  t.FailOnMaxInFlight,
+		SeqStart:           t.SeqStart,
 		RateLimiter:        t.RateLimiter,
 		BindInterval:       t.BindInterval,
+		BindTimeout:        t.BindTimeout,
+		ConnectTimeout:     t.ConnectTimeout,
 	}
 	t.cl.client = c
 	c.init()
@@ -67,7 +97,7 @@ func (t *Transceiver) bindFunc(c Conn) error {
 	_ = f.Set(pdufield.SystemID, t.User)
 	_ = f.Set(pdufield.Password, t.Passwd)
 	_ = f.Set(pdufield.SystemType, t.SystemType)
-	resp, err := bind(c, p)
+	resp, err := bind(c, p, t.BindTLVs)
 	if err != nil {
 		return err
 	}
@@ -75,6 +105,17 @@ func (t *Transceiver) bindFunc(c Conn) error {
 		return fmt.Errorf("unexpected response for BindTransceiver: %s",
 			resp.Header().ID)
 	}
-	go t.handlePDU(t.Handler)
+	t.cl.setPeer(resp)
+	handler := t.Handler
+	if t.HandlerWorkers > 0 && handler != nil {
+		hp := newHandlerPool(handler, t.HandlerWorkers, t.HandlerQueue)
+		handler = hp.handle
+		go func() {
+			defer hp.close()
+			t.handlePDU(handler)
+		}()
+		return nil
+	}
+	go t.handlePDU(handler)
 	return nil
 }
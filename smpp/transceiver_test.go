@@ -89,3 +89,49 @@ func TestTransceiver(t *testing.T) {
 		t.Fatal("timeout waiting for ack")
 	}
 }
+
+func TestTransceiverHandlerWorkers(t *testing.T) {
+	resps := make(chan uint32, 3)
+	s := smpptest.NewUnstartedServer()
+	s.Handlers = map[pdu.ID]smpptest.HandlerFunc{
+		pdu.DeliverSMRespID: func(c smpptest.Conn, p pdu.Body) {
+			resps <- p.Header().Seq
+		},
+	}
+	s.Start()
+	defer s.Close()
+	release := make(chan struct{})
+	defer close(release)
+	tc := &Transceiver{
+		Addr:   s.Addr(),
+		User:   smpptest.DefaultUser,
+		Passwd: smpptest.DefaultPasswd,
+		Handler: func(p pdu.Body) {
+			<-release
+		},
+		HandlerWorkers: 1,
+		HandlerQueue:   1,
+	}
+	defer tc.Close()
+	conn := <-tc.Bind()
+	switch conn.Status() {
+	case Connected:
+	default:
+		t.Fatal(conn.Error())
+	}
+	// The first deliver_sm blocks the worker and the second fills the
+	// queue, so reading waits on the third, which must be acked anyway.
+	for range 3 {
+		p := pdu.NewDeliverSM()
+		_ = p.Fields().Set(pdufield.SourceAddr, "root")
+		_ = p.Fields().Set(pdufield.ShortMessage, "Lorem ipsum")
+		s.BroadcastMessage(p)
+	}
+	for range 3 {
+		select {
+		case <-resps:
+		case <-time.After(time.Second):
+			t.Fatal("timeout waiting for deliver_sm_resp")
+		}
+	}
+}
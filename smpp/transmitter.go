@@ -6,9 +6,9 @@ package smpp
 
 import (
 	"crypto/tls"
-	"encoding/binary"
 	"errors"
 	"fmt"
+	"math"
 	"math/rand/v2"
 	"strconv"
 	"sync"
@@ -25,6 +25,101 @@ import (
 // the maximum window size configured for the Transmitter or Transceiver.
 var ErrMaxWindowSize = errors.New("reached max window size")
 
+// ErrMaxInFlight is returned by submits when MaxInFlight submits are
+// already in progress and FailOnMaxInFlight is set.
+var ErrMaxInFlight = errors.New("reached max in-flight submits")
+
+// MaxQosTimeToLive is the maximum QosTimeToLive of a ShortMessage, the
+// qos_time_to_live TLV counting milliseconds on four octets.
+const MaxQosTimeToLive = math.MaxUint32 * time.Millisecond
+
+// ErrQosTimeToLive is returned by submits when QosTimeToLive is
+// negative or over MaxQosTimeToLive.
+var ErrQosTimeToLive = errors.New("qos_time_to_live out of range")
+
+// MaxConcatenatedParts is the maximum number of parts of a
+// concatenated message, the concatenation IE counting them on one octet.
+const MaxConcatenatedParts = 255
+
+// PartsError is returned by SubmitLongMsg when the message would be
+// split into more parts than allowed and TruncateParts is not set.
+type PartsError struct {
+	Parts int // Parts needed to send the message.
+	Max   int // Parts allowed.
+}
+
+// Error implements the Error interface.
+func (e *PartsError) Error() string {
+	return fmt.Sprintf("message needs %d parts, max %d", e.Parts, e.Max)
+}
+
+// PartError is a part of a long message rejected by the SMSC.
+type PartError struct {
+	Index int      // Index of the part, from 0.
+	PDU   pdu.Body // submit_sm of the part, e.g. to resend with SubmitPDU.
+	Err   error    // Command status of the response.
+}
+
+// LongMsgError is returned by SubmitLongMsg when the SMSC rejects
+// some parts of a long message, after the other parts are sent.
+type LongMsgError struct {
+	Parts  int         // Parts of the message.
+	Failed []PartError // Parts rejected, in order.
+}
+
+// Error implements the Error interface.
+func (e *LongMsgError) Error() string {
+	f := e.Failed[0]
+	return fmt.Sprintf("%d of %d parts failed, part %d: %v", len(e.Failed), e.Parts, f.Index, f.Err)
+}
+
+// Unwrap returns the errors of the failed parts.
+func (e *LongMsgError) Unwrap() []error {
+	errs := make([]error, len(e.Failed))
+	for i, f := range e.Failed {
+		errs[i] = f.Err
+	}
+	return errs
+}
+
+// StatusError is returned by submits when the SMSC replies with a
+// non-zero command status and an additional_status_info_text TLV.
+// Without the TLV, the pdu.Status is returned as is.
+type StatusError struct {
+	Status pdu.Status // Command status, e.g. pdu.StatusThrottled.
+	Text   string     // Reason given by the SMSC.
+}
+
+// Error implements the Error interface.
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Status, e.Text)
+}
+
+// Unwrap returns the command status.
+func (e *StatusError) Unwrap() error {
+	return e.Status
+}
+
+// statusError returns the command status of resp as error, or nil if
+// zero. The status is wrapped in a *StatusError if resp has an
+// additional_status_info_text TLV.
+func statusError(resp pdu.Body) error {
+	s := resp.Header().Status
+	if s == 0 {
+		return nil
+	}
+	if text, ok := resp.TLVFields().String(pdutlv.TagAdditionalStatusInfoText); ok && text != "" {
+		return &StatusError{Status: s, Text: text}
+	}
+	return s
+}
+
+// ErrClosing is returned by submits once CloseGracefully is called.
+var ErrClosing = errors.New("closing")
+
+// ErrNilPDU is returned when a Middleware returns no PDU to send.
+var ErrNilPDU = errors.New("middleware returned a nil PDU")
+
 // MaxDestinationAddress is the maximum number of destination addresses allowed
 // in the submit_multi operation.
 const MaxDestinationAddress = 254
@@ -32,26 +127,56 @@ const MaxDestinationAddress = 254
 // Transmitter implements an SMPP client transmitter.
 type Transmitter struct {
 	Addr               string        // Server address in form of host:port.
+	Addrs              []string      // Failover server addresses, tried in order after Addr.
 	User               string        // Username.
 	Passwd             string        // Password.
 	SystemType         string        // System type, default empty.
-	EnquireLink        time.Duration // Enquire link interval, default 10s.
+	BindTLVs           pdutlv.Fields // Optional parameters of the bind request, e.g. vendor-specific.
+	EnquireLink        time.Duration // Enquire link interval, default 10s, negative to disable.
 	EnquireLinkTimeout time.Duration // Time after last EnquireLink response when connection considered down
 	RespTimeout        time.Duration // Response timeout, default 1s.
+	ReadTimeout        time.Duration // Read deadline for each PDU, optional. Should exceed EnquireLink.
+	WriteTimeout       time.Duration // Write deadline for each PDU, optional.
 	BindInterval       time.Duration // Binding retry interval
+	BindTimeout        time.Duration // Bind response timeout, optional.
+	ConnectTimeout     time.Duration // TCP connection timeout, optional.
 	TLS                *tls.Config   // TLS client settings, optional.
+	Dialer             Dialer        // Opens connections instead of TCP, ignoring ConnectTimeout, optional.
 	RateLimiter        RateLimiter   // Rate limiter, optional.
 	WindowSize         uint
+	MaxInFlight        uint                   // Max concurrent submits, beyond which callers wait, optional.
+	FailOnMaxInFlight  bool                   // Return ErrMaxInFlight instead of waiting, optional.
+	SeqStart           uint32                 // First sequence number of requests, renumbering them, optional.
+	StrictValidation   bool                   // Validate addresses before sending, optional.
+	NormalizeE164      bool                   // Normalize destination numbers before sending, optional.
+	AutoSourceTON      bool                   // Infer the source TON and NPI from Src when unset, optional.
+	PackGSM7           bool                   // Send GSM7 text packed, as GSM7Packed, optional.
+	EncodeFallback     []pdutext.DataCoding   // Codecs for text its codec can not encode, e.g. UCS2Type, optional.
+	MaxParts           int                    // Max parts of a long message, default and at most 255.
+	TruncateParts      bool                   // Send the first MaxParts parts instead of failing, optional.
+	PartRetry          *RetryPolicy           // Retries of long message parts rejected by the SMSC, optional.
+	RateLimitMessages  bool                   // Wait on RateLimiter once per long message instead of per part, optional.
+	DecodeOptions      pdufield.DecodeOptions // PDU decoding options, optional.
+	OnWrite            WriteFunc              // Called with the raw bytes of each PDU sent, optional.
+	Receipts           *ReceiptTracker        // Delivery receipt correlation, optional.
+	OnUnmatched        HandlerFunc            // Called with responses matching no pending request, optional.
+	OnCongestion       func(state uint8)      // Called with the congestion_state of responses carrying it, optional.
+	OnUnexpected       HandlerFunc            // Called with unsolicited PDUs, e.g. deliver_sm, optional.
+
+	congestion atomic.Int32 // Last congestion_state plus one, zero if none.
 
 	cl struct {
 		sync.Mutex
 		*client
+		mw []Middleware // set by Use
 	}
 
 	tx struct {
 		count int32
 		sync.Mutex
 		inflight map[string]chan *tx
+		closing  bool           // set by CloseGracefully
+		wg       sync.WaitGroup // requests in flight
 	}
 }
 
@@ -75,15 +200,28 @@ func (t *Transmitter) Bind() <-chan ConnStatus {
 	t.tx.Unlock()
 	c := &client{
 		Addr:               t.Addr,
+		Addrs:              t.Addrs,
 		TLS:                t.TLS,
+		Dialer:             t.Dialer,
 		Status:             make(chan ConnStatus, 1),
 		BindFunc:           t.bindFunc,
 		EnquireLink:        t.EnquireLink,
 		EnquireLinkTimeout: t.EnquireLinkTimeout,
 		RespTimeout:        t.RespTimeout,
+		ReadTimeout:        t.ReadTimeout,
+		WriteTimeout:       t.WriteTimeout,
+		DecodeOptions:      t.DecodeOptions,
+		OnWrite:            t.OnWrite,
+		Receipts:           t.Receipts,
+		OnUnmatched:        t.OnUnmatched,
 		WindowSize:         t.WindowSize,
+		MaxInFlight:        t.MaxInFlight,
+		FailOnMaxInFlight:  t.FailOnMaxInFlight,
+		SeqStart:           t.SeqStart,
 		RateLimiter:        t.RateLimiter,
 		BindInterval:       t.BindInterval,
+		BindTimeout:        t.BindTimeout,
+		ConnectTimeout:     t.ConnectTimeout,
 	}
 	t.cl.client = c
 	c.init()
@@ -97,7 +235,7 @@ func (t *Transmitter) bindFunc(c Conn) error {
 	_ = f.Set(pdufield.SystemID, t.User)
 	_ = f.Set(pdufield.Password, t.Passwd)
 	_ = f.Set(pdufield.SystemType, t.SystemType)
-	resp, err := bind(c, p)
+	resp, err := bind(c, p, t.BindTLVs)
 	if err != nil {
 		return err
 	}
@@ -105,38 +243,99 @@ func (t *Transmitter) bindFunc(c Conn) error {
 		return fmt.Errorf("unexpected response for BindTransmitter: %s",
 			resp.Header().ID)
 	}
+	t.cl.setPeer(resp)
 	go t.handlePDU(nil)
 	return nil
 }
 
 // f is only set on transceiver.
+//
+// Responses are matched to pending requests by sequence number. Those
+// matching no request, e.g. late or duplicate responses, are passed to
+// OnUnmatched if set, or else to f. Other PDUs are passed to f, or to
+// OnUnexpected on a transmitter, deliver_sm being answered first
+// so that a handler blocking reading does not delay its response.
 func (t *Transmitter) handlePDU(f HandlerFunc) {
 	for {
 		p, err := t.cl.Read()
 		if err != nil || p == nil {
 			break
 		}
-		key := p.Header().Key()
-		t.tx.Lock()
-		rc := t.tx.inflight[key]
-		t.tx.Unlock()
-		if rc != nil {
-			rc <- &tx{PDU: p}
-		} else if f != nil {
-			f(p)
+		if p.Header().ID.IsResponse() {
+			t.updateCongestion(p)
+			if !t.deliverResp(p) {
+				if t.cl.OnUnmatched != nil {
+					t.cl.OnUnmatched(p)
+				} else if f != nil {
+					f(p)
+				}
+			}
+			continue
 		}
 		if p.Header().ID == pdu.DeliverSMID { // Send DeliverSMResp
 			pResp := pdu.NewDeliverSMRespSeq(p.Header().Seq)
 			_ = t.cl.Write(pResp)
+			if t.cl.Receipts != nil {
+				t.cl.Receipts.Match(p)
+			}
+		}
+		if f != nil {
+			f(p)
+		} else if t.OnUnexpected != nil {
+			t.OnUnexpected(p)
 		}
 	}
 	t.tx.Lock()
-	for _, rc := range t.tx.inflight {
+	for key, rc := range t.tx.inflight {
+		delete(t.tx.inflight, key)
 		rc <- &tx{Err: ErrNotConnected}
 	}
 	t.tx.Unlock()
 }
 
+// updateCongestion records the congestion_state TLV of the response p,
+// if any, and passes it to OnCongestion.
+func (t *Transmitter) updateCongestion(p pdu.Body) {
+	state, ok := p.TLVFields().CongestionState()
+	if !ok {
+		return
+	}
+	t.congestion.Store(int32(state) + 1)
+	if t.OnCongestion != nil {
+		t.OnCongestion(state)
+	}
+}
+
+// CongestionState returns the last congestion_state reported by the
+// SMSC in a response, from 0 (idle) to 100 (congested), e.g. to lower
+// the submit rate when it exceeds 80. It returns false if the SMSC has
+// not sent one, as SMPP 3.4 SMSCs do not.
+func (t *Transmitter) CongestionState() (uint8, bool) {
+	v := t.congestion.Load()
+	if v == 0 {
+		return 0, false
+	}
+	return uint8(v - 1), true
+}
+
+// deliverResp passes the response p to the pending request with the
+// same sequence number, which is then no longer pending. It returns
+// false if there is none. It never blocks. A duplicate response for an
+// already answered sequence number finds no pending request, so it is
+// never delivered twice nor releases a window slot twice.
+func (t *Transmitter) deliverResp(p pdu.Body) bool {
+	key := p.Header().Key()
+	t.tx.Lock()
+	defer t.tx.Unlock()
+	rc := t.tx.inflight[key]
+	if rc == nil {
+		return false
+	}
+	delete(t.tx.inflight, key)
+	rc <- &tx{PDU: p} // buffered, and only sent once
+	return true
+}
+
 // Close implements the ClientConn interface.
 func (t *Transmitter) Close() error {
 	t.cl.Lock()
@@ -147,6 +346,81 @@ func (t *Transmitter) Close() error {
 	return t.cl.Close()
 }
 
+// CloseGracefully stops accepting new requests, which fail with
+// ErrClosing, and waits up to timeout for the responses of the requests
+// in flight before closing the connection as Close does. Requests still
+// pending after the timeout fail with ErrTimeout.
+func (t *Transmitter) CloseGracefully(timeout time.Duration) error {
+	t.cl.Lock()
+	notbound := t.cl.client == nil
+	t.cl.Unlock()
+	if notbound {
+		return ErrNotConnected
+	}
+	t.tx.Lock()
+	t.tx.closing = true
+	t.tx.Unlock()
+	done := make(chan struct{})
+	go func() {
+		t.tx.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		t.tx.Lock()
+		for key, rc := range t.tx.inflight {
+			delete(t.tx.inflight, key)
+			rc <- &tx{Err: ErrTimeout}
+		}
+		t.tx.Unlock()
+	}
+	return t.Close()
+}
+
+// State returns the current connection state, or Disconnected if
+// Bind has not been called.
+func (t *Transmitter) State() ConnStatusID {
+	t.cl.Lock()
+	defer t.cl.Unlock()
+	if t.cl.client == nil {
+		return Disconnected
+	}
+	return t.cl.State()
+}
+
+// Outstanding returns the number of requests awaiting a response, as
+// counted against WindowSize. It is safe for concurrent use.
+func (t *Transmitter) Outstanding() int {
+	t.tx.Lock()
+	defer t.tx.Unlock()
+	return len(t.tx.inflight)
+}
+
+// PeerInterfaceVersion returns the SMPP version advertised by the server
+// in its bind response, e.g. 0x34 for 3.4. It returns false if the server
+// did not send the sc_interface_version TLV, or if not bound yet.
+func (t *Transmitter) PeerInterfaceVersion() (uint8, bool) {
+	t.cl.Lock()
+	defer t.cl.Unlock()
+	if t.cl.client == nil {
+		return 0, false
+	}
+	return t.cl.PeerInterfaceVersion()
+}
+
+// PeerSystemID returns the system_id the server identified itself with
+// in its bind response, e.g. for logging. It returns an empty string if
+// not bound yet.
+func (t *Transmitter) PeerSystemID() string {
+	t.cl.Lock()
+	defer t.cl.Unlock()
+	if t.cl.client == nil {
+		return ""
+	}
+	return t.cl.PeerSystemID()
+}
+
 // UnsucessDest contains information about unsuccessful delivery to an address
 // when submit multi is used
 type UnsucessDest struct {
@@ -161,11 +435,33 @@ func newUnsucessDest(p pdufield.UnSme) UnsucessDest {
 	unDest := UnsucessDest{}
 	unDest.AddrTON, _ = p.Ton.Raw().(uint8) // if there is an error default value will be set
 	unDest.AddrNPI, _ = p.Npi.Raw().(uint8)
-	unDest.Address = string(p.DestAddr.Bytes())
-	unDest.Error = pdu.Status(binary.BigEndian.Uint32(p.ErrCode.Bytes()))
+	unDest.Address = p.DestAddr.String()
+	unDest.Error = pdu.Status(p.ErrorCode())
 	return unDest
 }
 
+// Destination is an SME address of submit multi with its own
+// numbering plan. Addresses in DstList use DestAddrTON and DestAddrNPI.
+type Destination struct {
+	Addr string
+	TON  uint8
+	NPI  uint8
+}
+
+// ValidityMode is the format of the validity_period of a ShortMessage.
+type ValidityMode uint8
+
+// Supported validity modes.
+//
+// With a zero Validity, or ValiditySMSCDefault, validity_period is left
+// empty and the SMSC applies its default validity. Some SMSCs treat an
+// empty validity_period as never expiring instead.
+const (
+	ValidityAbsolute    ValidityMode = iota // Now plus Validity, in UTC.
+	ValidityRelative                        // Validity relative to the SMSC time.
+	ValiditySMSCDefault                     // Empty, even if Validity is set.
+)
+
 // ShortMessage configures a short message that can be submitted via
 // the Transmitter. When returned from Submit, the ShortMessage
 // provides Resp and RespID.
@@ -193,10 +489,91 @@ type ShortMessage struct {
 	SMDefaultMsgID       uint8
 	NumberDests          uint8
 
+	// ShiftTables sets the GSM 7-bit national language shift tables
+	// announced in the UDH of long messages sent with SubmitLongMsg.
+	ShiftTables pdutext.ShiftTables
+
+	// Dsts sets destination addresses for submit multi, each with
+	// its own TON/NPI, in addition to DstList and DLs.
+	Dsts []Destination
+
+	// UDH sets extra IEs added to the UDH of each part of long
+	// messages sent with SubmitLongMsg, e.g. application ports.
+	UDH []pdufield.UDHIE
+
+	// MessageClass sets the message class bits of data_coding, e.g.
+	// Class0 for flash messages. MWI sets a message waiting indication
+	// instead. Both keep the alphabet of Text.
+	MessageClass pdutext.MessageClass
+	MWI          *pdutext.MWI
+
+	// SetDPF sets the set_dpf TLV of submit_sm when not nil, requesting
+	// a delivery pending flag on delivery failure if true.
+	SetDPF *bool
+
+	// MoreMessagesToSend sets the more_messages_to_send TLV of submit_sm
+	// when not nil, telling the SMSC that more messages follow for the
+	// same destination if true.
+	MoreMessagesToSend *bool
+
+	// NumberOfMessages sets the number_of_messages TLV of submit_sm
+	// when not nil, e.g. the count of messages waiting for a voicemail
+	// indication. The value must be 0 to 99.
+	NumberOfMessages *uint8
+
+	// CallbackNum sets the callback_num TLV of submit_sm when not nil,
+	// with CallbackNumPresInd and CallbackNumAtag setting its
+	// presentation indicator and display tag.
+	CallbackNum        *pdutlv.CallbackNum
+	CallbackNumPresInd *pdutlv.CallbackNumPresInd
+	CallbackNumAtag    *pdutlv.CallbackNumAtag
+
+	// DestNetworkType sets the dest_network_type TLV of submit_sm when
+	// not nil, the network type of the destination address.
+	DestNetworkType *pdutlv.NetworkType
+
+	// DestBearerType sets the dest_bearer_type TLV of submit_sm when
+	// not nil, the bearer used to deliver the message.
+	DestBearerType *pdutlv.BearerType
+
+	// MsValidity sets the ms_validity TLV of submit_sm when not nil,
+	// how long the handset keeps the message, e.g. MsValidityDisplayOnly.
+	MsValidity *pdutlv.MsValidity
+
+	// PrivacyIndicator sets the privacy_indicator TLV of submit_sm when
+	// not nil, e.g. PrivacyConfidential.
+	PrivacyIndicator *pdutlv.PrivacyIndicator
+
+	// PayloadType sets the payload_type TLV of submit_sm when not nil,
+	// e.g. PayloadWCMP for a Wireless Control Message Protocol payload.
+	PayloadType *pdutlv.PayloadType
+
+	// ItsReplyType and ItsSessionInfo set the its_reply_type and
+	// its_session_info TLVs of submit_sm when not nil, for interactive
+	// teleservice.
+	ItsReplyType   *pdutlv.ItsReplyType
+	ItsSessionInfo *pdutlv.ItsSessionInfo
+
+	// QosTimeToLive sets the qos_time_to_live TLV of submit_sm when not
+	// zero, in milliseconds, a time to live some SMSCs accept instead
+	// of Validity. It must not exceed MaxQosTimeToLive.
+	QosTimeToLive time.Duration
+
+	// ValidityMode sets how Validity is sent in validity_period, as an
+	// absolute time by default.
+	ValidityMode ValidityMode
+
+	// MessageMode sets the messaging mode bits of esm_class when not
+	// DefaultMode, e.g. DatagramMode, overriding those of ESMClass. The
+	// UDH indicator of long message parts is kept.
+	MessageMode pdufield.MessageMode
+
 	resp struct {
 		sync.Mutex
 		p pdu.Body
 	}
+	partText string
+	isPart   bool
 }
 
 // Resp returns the response PDU, or nil if not set.
@@ -206,6 +583,50 @@ func (sm *ShortMessage) Resp() pdu.Body {
 	return sm.resp.p
 }
 
+// DpfResult returns the dpf_result TLV of the response, true if a
+// delivery pending flag was set. It returns false as second value if the
+// response has no dpf_result.
+func (sm *ShortMessage) DpfResult() (set, ok bool) {
+	resp := sm.Resp()
+	if resp == nil {
+		return false, false
+	}
+	v, ok := resp.TLVFields().DpfResult()
+	return v == pdutlv.DpfSet, ok
+}
+
+// SourceText returns the text of the message before encoding.
+func (sm *ShortMessage) SourceText() string {
+	switch v := sm.Text.(type) {
+	case nil:
+		return ""
+	case pdutext.GSM7:
+		return string(v)
+	case pdutext.GSM7Packed:
+		return string(v)
+	case pdutext.Latin1:
+		return string(v)
+	case pdutext.ISO88595:
+		return string(v)
+	case pdutext.UCS2:
+		return string(v)
+	case pdutext.Raw:
+		return string(v)
+	case pdutext.Binary:
+		return string(v)
+	}
+	return string(sm.Text.Decode())
+}
+
+// PartText returns the text carried by a part returned by SubmitLongMsg,
+// or the whole text of other messages.
+func (sm *ShortMessage) PartText() string {
+	if sm.isPart {
+		return sm.partText
+	}
+	return sm.SourceText()
+}
+
 // RespID is a shortcut to Resp().Fields()[pdufield.MessageID].
 // Returns empty if the response PDU is not available, or does
 // not contain the MessageID field.
@@ -276,8 +697,12 @@ func (sm *ShortMessage) Clone() *ShortMessage {
 	copy(clone.DstList, sm.DstList)
 	clone.DLs = make([]string, len(sm.DLs))
 	copy(clone.DLs, sm.DLs)
+	clone.Dsts = make([]Destination, len(sm.Dsts))
+	copy(clone.Dsts, sm.Dsts)
 	clone.Text = sm.Text
 	clone.Validity = sm.Validity
+	clone.QosTimeToLive = sm.QosTimeToLive
+	clone.ValidityMode = sm.ValidityMode
 	clone.Register = sm.Register
 	clone.TLVFields = make(pdutlv.Fields)
 	for k, v := range sm.TLVFields {
@@ -289,17 +714,194 @@ func (sm *ShortMessage) Clone() *ShortMessage {
 	clone.DestAddrTON = sm.DestAddrTON
 	clone.DestAddrNPI = sm.DestAddrNPI
 	clone.ESMClass = sm.ESMClass
+	clone.MessageMode = sm.MessageMode
 	clone.ProtocolID = sm.ProtocolID
 	clone.PriorityFlag = sm.PriorityFlag
 	clone.ScheduleDeliveryTime = sm.ScheduleDeliveryTime
 	clone.ReplaceIfPresentFlag = sm.ReplaceIfPresentFlag
 	clone.SMDefaultMsgID = sm.SMDefaultMsgID
 	clone.NumberDests = sm.NumberDests
+	clone.ShiftTables = sm.ShiftTables
+	clone.MessageClass = sm.MessageClass
+	if sm.SetDPF != nil {
+		dpf := *sm.SetDPF
+		clone.SetDPF = &dpf
+	}
+	if sm.MoreMessagesToSend != nil {
+		more := *sm.MoreMessagesToSend
+		clone.MoreMessagesToSend = &more
+	}
+	if sm.NumberOfMessages != nil {
+		n := *sm.NumberOfMessages
+		clone.NumberOfMessages = &n
+	}
+	if sm.DestNetworkType != nil {
+		nt := *sm.DestNetworkType
+		clone.DestNetworkType = &nt
+	}
+	if sm.DestBearerType != nil {
+		bt := *sm.DestBearerType
+		clone.DestBearerType = &bt
+	}
+	if sm.MsValidity != nil {
+		v := *sm.MsValidity
+		clone.MsValidity = &v
+	}
+	if sm.PrivacyIndicator != nil {
+		pi := *sm.PrivacyIndicator
+		clone.PrivacyIndicator = &pi
+	}
+	if sm.PayloadType != nil {
+		pt := *sm.PayloadType
+		clone.PayloadType = &pt
+	}
+	if sm.ItsReplyType != nil {
+		rt := *sm.ItsReplyType
+		clone.ItsReplyType = &rt
+	}
+	if sm.ItsSessionInfo != nil {
+		si := *sm.ItsSessionInfo
+		clone.ItsSessionInfo = &si
+	}
+	if sm.CallbackNum != nil {
+		cb := *sm.CallbackNum
+		clone.CallbackNum = &cb
+	}
+	if sm.CallbackNumPresInd != nil {
+		ind := *sm.CallbackNumPresInd
+		clone.CallbackNumPresInd = &ind
+	}
+	if sm.CallbackNumAtag != nil {
+		atag := *sm.CallbackNumAtag
+		atag.Display = append([]byte(nil), atag.Display...)
+		clone.CallbackNumAtag = &atag
+	}
+	if sm.MWI != nil {
+		mwi := *sm.MWI
+		clone.MWI = &mwi
+	}
+	clone.UDH = make([]pdufield.UDHIE, len(sm.UDH))
+	copy(clone.UDH, sm.UDH)
 	clone.resp.p = sm.Resp()
+	clone.partText = sm.partText
+	clone.isPart = sm.isPart
 	return clone
 }
 
+// clonePart returns a copy of sm for the part of a long message with
+// the given encoded payload.
+func (sm *ShortMessage) clonePart(payload []byte) *ShortMessage {
+	part := sm.Clone()
+	part.isPart = true
+	switch sm.Text.(type) {
+	case pdutext.GSM7:
+		part.partText = string(pdutext.GSM7(payload).Decode())
+	case pdutext.Latin1:
+		part.partText = string(pdutext.Latin1(payload).Decode())
+	case pdutext.ISO88595:
+		part.partText = string(pdutext.ISO88595(payload).Decode())
+	case pdutext.UCS2:
+		part.partText = string(pdutext.UCS2(payload).Decode())
+	default:
+		part.partText = string(payload)
+	}
+	return part
+}
+
+// trackReceipt adds sm to the receipt tracker, if any, when an SMSC
+// delivery receipt was requested.
+func (t *Transmitter) trackReceipt(sm *ShortMessage) {
+	t.cl.Lock()
+	rt := t.cl.Receipts
+	t.cl.Unlock()
+	if rt != nil && sm.Register.Receipt() != pdufield.NoDeliveryReceipt {
+		rt.Track(sm)
+	}
+}
+
+// acquire takes one of the MaxInFlight slots, if set, waiting for one
+// to be free unless FailOnMaxInFlight is set. The returned function
+// releases the slot.
+func (t *Transmitter) acquire() (func(), error) {
+	t.cl.Lock()
+	c := t.cl.client
+	t.cl.Unlock()
+	if c == nil || c.sem == nil {
+		return func() {}, nil
+	}
+	select {
+	case c.sem <- struct{}{}:
+	default:
+		if c.FailOnMaxInFlight {
+			return nil, ErrMaxInFlight
+		}
+		c.sem <- struct{}{}
+	}
+	return func() { <-c.sem }, nil
+}
+
+// nextSeq returns the next sequence number of requests, from SeqStart
+// if set.
+func (t *Transmitter) nextSeq() uint32 {
+	if t.cl.seq != nil {
+		return t.cl.seq.Next()
+	}
+	return pdu.NextSeq()
+}
+
+// Middleware is called by a Transmitter with each request before it
+// is sent, e.g. submit_sm, and returns the PDU to send instead, p
+// itself if only inspected or mutated. Returning an error aborts the
+// request, the error being returned to the caller, and so does
+// returning a nil PDU, with ErrNilPDU.
+type Middleware func(p pdu.Body) (pdu.Body, error)
+
+// Use appends m to the middlewares of t, called in order with each
+// request sent, such as submit_sm, submit_multi, data_sm or query_sm.
+// Each is passed the PDU returned by the previous one. Enquire links,
+// binds and unbinds are not passed to middlewares. Middlewares run
+// once per request: a long message part resent as configured by
+// PartRetry is not passed to them again.
+func (t *Transmitter) Use(m ...Middleware) {
+	t.cl.Lock()
+	defer t.cl.Unlock()
+	t.cl.mw = append(t.cl.mw, m...)
+}
+
+// do passes p to the middlewares of t, and sends the PDU they return.
 func (t *Transmitter) do(p pdu.Body) (*tx, error) {
+	p, err := t.middleware(p)
+	if err != nil {
+		return nil, err
+	}
+	return t.send(p, true)
+}
+
+// middleware passes p to the middlewares of t, and returns the PDU to
+// send.
+func (t *Transmitter) middleware(p pdu.Body) (pdu.Body, error) {
+	t.cl.Lock()
+	notbound := t.cl.client == nil
+	mw := t.cl.mw
+	t.cl.Unlock()
+	if notbound {
+		return nil, ErrNotBound
+	}
+	for _, m := range mw {
+		var err error
+		if p, err = m(p); err != nil {
+			return nil, err
+		}
+		if p == nil {
+			return nil, ErrNilPDU
+		}
+	}
+	return p, nil
+}
+
+// send sends p and waits for its response, waiting on the RateLimiter
+// first if limit is set.
+func (t *Transmitter) send(p pdu.Body, limit bool) (*tx, error) {
 	t.cl.Lock()
 	notbound := t.cl.client == nil
 	t.cl.Unlock()
@@ -313,17 +915,37 @@ func (t *Transmitter) do(p pdu.Body) (*tx, error) {
 			return nil, ErrMaxWindowSize
 		}
 	}
+	if t.cl.seq != nil {
+		p.Header().Seq = t.cl.seq.Next()
+	}
 	rc := make(chan *tx, 1)
-	key := p.Header().Key()
 	t.tx.Lock()
+	if t.tx.closing {
+		t.tx.Unlock()
+		return nil, ErrClosing
+	}
+	// Once the sequence wraps after pdu.MaxSeq, never reuse a number
+	// still pending a response, skip it instead.
+	key := p.Header().Key()
+	for t.tx.inflight[key] != nil {
+		p.Header().Seq = t.nextSeq()
+		key = p.Header().Key()
+	}
+	t.tx.wg.Add(1)
 	t.tx.inflight[key] = rc
 	t.tx.Unlock()
 	defer func() {
 		t.tx.Lock()
 		delete(t.tx.inflight, key)
 		t.tx.Unlock()
+		t.tx.wg.Done()
 	}()
-	err := t.cl.Write(p)
+	var err error
+	if limit {
+		err = t.cl.Write(p)
+	} else {
+		err = t.cl.conn.Write(p)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -340,179 +962,532 @@ func (t *Transmitter) do(p pdu.Body) (*tx, error) {
 
 // Submit sends a short message and returns and updates the given
 // sm with the response status. It returns the same sm object.
+//
+// If NormalizeE164 is set, Dst, DstList and the addresses of Dsts are
+// sent normalized with NormalizeE164, setting the international TON
+// and ISDN NPI for numbers starting with '+', sm itself being left
+// unchanged. An *AddressError is returned without sending anything if a
+// number is invalid, or if Dst and DstList, which share their TON and
+// NPI, mix international and national numbers.
+//
+// If AutoSourceTON is set and neither SourceAddrTON nor SourceAddrNPI
+// is, they are inferred from Src with SourceTON for the message sent,
+// e.g. alphanumeric for "BANK", sm itself being left unchanged.
+//
+// If StrictValidation is set, the addresses of sm are checked first and
+// an *AddressError is returned without sending anything if invalid, or
+// ErrNumberOfMessages if NumberOfMessages is over 99, or an
+// *EncodingError if Text has characters its codec can not encode.
+//
+// If EncodeFallback is set and Text has characters its codec can not
+// encode, the text is sent encoded with the first codec of
+// EncodeFallback that can, as by pdutext.Fallback, e.g. GSM7 text with
+// an emoji is sent as UCS2 with EncodeFallback set to
+// []pdutext.DataCoding{pdutext.UCS2Type}. Text itself is not replaced.
+//
+// If PackGSM7 is set and Text is GSM7, the text is sent as GSM7Packed,
+// for SMSCs expecting short_message packed 8 septets per 7 octets,
+// leaving Text unpacked. The data_coding is 0x00 either way. sm_length
+// always counts octets: one per septet unpacked, or the septets times
+// 7/8 rounded up packed, e.g. 140 octets for 160 septets.
 func (t *Transmitter) Submit(sm *ShortMessage) (*ShortMessage, error) {
-	if len(sm.DstList) > 0 || len(sm.DLs) > 0 {
+	release, err := t.acquire()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	multi := sm.multi()
+	msg, err := t.normalize(sm)
+	if err != nil {
+		return nil, err
+	}
+	msg = t.encode(msg, true)
+	if t.StrictValidation {
+		if err := validateShortMessage(msg, multi); err != nil {
+			return nil, err
+		}
+	}
+	if err := msg.checkQosTimeToLive(); err != nil {
+		return nil, err
+	}
+	if multi {
 		// if we have a single destination address add it to the list
-		if sm.Dst != "" {
-			sm.DstList = append(sm.DstList, sm.Dst)
+		if msg.Dst != "" {
+			msg.DstList = append(msg.DstList, msg.Dst)
 		}
-		p := pdu.NewSubmitMulti(sm.TLVFields)
-		return t.submitMsgMulti(sm, p, uint8(sm.Text.Type()))
+		p, err := msg.submitMultiPDU()
+		if err != nil {
+			return nil, err
+		}
+		return t.submitMsg(sm, p, pdu.SubmitMultiRespID)
 	}
-	p := pdu.NewSubmitSM(sm.TLVFields)
-	return t.submitMsg(sm, p, uint8(sm.Text.Type()))
+	return t.submitMsg(sm, msg.submitPDU(), pdu.SubmitSMRespID)
 }
 
 // SubmitLongMsg sends a long message (more than 140 bytes)
 // and returns and updates the given sm with the response status.
-// It returns the same sm object.
+// It returns a copy of sm for each part sent, with the text of the
+// part available from PartText. EncodeFallback, NormalizeE164,
+// AutoSourceTON and StrictValidation apply as for Submit, the text
+// being split according to the codec it ends up with. PackGSM7 does
+// not: the parts are always sent unpacked, as packing them would
+// require fill bits after the UDH.
+//
+// If the message needs more than MaxParts parts, nothing is sent and a
+// *PartsError is returned, or only the first MaxParts parts are sent if
+// TruncateParts is set.
+//
+// A part rejected by the SMSC is retried as configured by PartRetry,
+// if set. Parts still rejected do not stop the others from being sent,
+// and are listed in the returned *LongMsgError so that they can be
+// resent.
+//
+// Each part sent waits on RateLimiter, as any PDU does, unless
+// RateLimitMessages is set, in which case only the first one does.
 func (t *Transmitter) SubmitLongMsg(sm *ShortMessage) ([]ShortMessage, error) {
-	maxLen := pdutext.MaxConcatenatedShortMessageLenEncoded
-	switch sm.Text.(type) {
-	case pdutext.GSM7:
-		maxLen = pdutext.MaxGSM7ConcatenatedShortMessageLenEncoded
-	case pdutext.UCS2:
-		maxLen = pdutext.MaxUCS2ConcatenatedShortMessageLenEncoded
+	release, err := t.acquire()
+	if err != nil {
+		return nil, err
 	}
-	rawMsg := sm.Text.Encode()
-	countParts := int((len(rawMsg)-1)/maxLen) + 1
-
-	parts := make([]ShortMessage, 0, countParts)
-
-	rn := uint16(rand.IntN(0xFFFF))
-	for i := range countParts {
-		udh := pdufield.NewUDHConcatenatedShortMessage(rn, countParts, i+1)
-		p := pdu.NewSubmitSM(sm.TLVFields)
-		f := p.Fields()
-		_ = f.Set(pdufield.SourceAddr, sm.Src)
-		_ = f.Set(pdufield.DestinationAddr, sm.Dst)
-		if i != countParts-1 {
-			_ = f.Set(pdufield.ShortMessage, pdutext.Raw(rawMsg[i*maxLen:(i+1)*maxLen]))
-		} else {
-			_ = f.Set(pdufield.ShortMessage, pdutext.Raw(rawMsg[i*maxLen:]))
+	defer release()
+	msg, err := t.normalize(sm)
+	if err != nil {
+		return nil, err
+	}
+	msg = t.encode(msg, false)
+	if t.StrictValidation {
+		if err := validateShortMessage(msg, false); err != nil {
+			return nil, err
 		}
-		_ = f.Set(pdufield.RegisteredDelivery, uint8(sm.Register))
-		if sm.Validity != 0 {
-			_ = f.Set(pdufield.ValidityPeriod, convertValidity(sm.Validity))
+	}
+	if err := msg.checkQosTimeToLive(); err != nil {
+		return nil, err
+	}
+	payloads, err := msg.longPayloads()
+	if err != nil {
+		return nil, err
+	}
+	maxParts := t.MaxParts
+	if maxParts <= 0 || maxParts > MaxConcatenatedParts {
+		maxParts = MaxConcatenatedParts
+	}
+	if len(payloads) > maxParts {
+		if !t.TruncateParts {
+			return nil, &PartsError{Parts: len(payloads), Max: maxParts}
 		}
-		_ = f.Set(pdufield.ServiceType, sm.ServiceType)
-		_ = f.Set(pdufield.SourceAddrTON, sm.SourceAddrTON)
-		_ = f.Set(pdufield.SourceAddrNPI, sm.SourceAddrNPI)
-		_ = f.Set(pdufield.DestAddrTON, sm.DestAddrTON)
-		_ = f.Set(pdufield.DestAddrNPI, sm.DestAddrNPI)
-		_ = f.Set(pdufield.ESMClass, pdufield.ESMClassUDHIndicator)
-		_ = f.Set(pdufield.ProtocolID, sm.ProtocolID)
-		_ = f.Set(pdufield.PriorityFlag, sm.PriorityFlag)
-		_ = f.Set(pdufield.ScheduleDeliveryTime, sm.ScheduleDeliveryTime)
-		_ = f.Set(pdufield.ReplaceIfPresentFlag, sm.ReplaceIfPresentFlag)
-		_ = f.Set(pdufield.SMDefaultMsgID, sm.SMDefaultMsgID)
-		_ = f.Set(pdufield.DataCoding, uint8(sm.Text.Type()))
-		_ = f.Set(pdufield.UDHLength, uint8(udh.Len()))
-		_ = f.Set(pdufield.GSMUserData, &udh)
-		_ = f.Set(pdufield.SMLength, uint8(f[pdufield.ShortMessage].Len()+udh.Len()+1)) // +1 for UDHLength octet
-		resp, err := t.do(p)
+		payloads = payloads[:maxParts]
+	}
+	pdus := msg.longPDUs(payloads)
+	parts := make([]ShortMessage, 0, len(pdus))
+	var failed []PartError
+	for i, p := range pdus {
+		p, err := t.middleware(p)
 		if err != nil {
 			return nil, err
 		}
-		sm.resp.Lock()
-		sm.resp.p = resp.PDU
-		sm.resp.Unlock()
-		if resp.PDU == nil {
-			return parts, fmt.Errorf("unexpected empty PDU")
+		var rejected error
+		for attempt := 1; ; attempt++ {
+			limit := !t.RateLimitMessages || (i == 0 && attempt == 1)
+			resp, err := t.send(p, limit)
+			if err != nil {
+				return nil, err
+			}
+			sm.resp.Lock()
+			sm.resp.p = resp.PDU
+			sm.resp.Unlock()
+			if msg != sm { // parts are cloned from msg
+				msg.resp.Lock()
+				msg.resp.p = resp.PDU
+				msg.resp.Unlock()
+			}
+			if resp.PDU == nil {
+				return parts, fmt.Errorf("unexpected empty PDU")
+			}
+			if id := resp.PDU.Header().ID; id != pdu.SubmitSMRespID {
+				return parts, fmt.Errorf("unexpected PDU ID: %s", id)
+			}
+			rejected = statusError(resp.PDU)
+			if rejected == nil || t.PartRetry == nil ||
+				attempt >= t.PartRetry.attempts() || !t.PartRetry.retry(rejected) {
+				break
+			}
+			time.Sleep(t.PartRetry.delay(attempt))
+			p.Header().Seq = pdu.NextSeq()
 		}
-		if id := resp.PDU.Header().ID; id != pdu.SubmitSMRespID {
-			return parts, fmt.Errorf("unexpected PDU ID: %s", id)
-		}
-		if s := resp.PDU.Header().Status; s != 0 {
-			return parts, s
-		}
-		if resp.Err != nil {
-			return parts, resp.Err
+		if rejected != nil {
+			failed = append(failed, PartError{Index: i, PDU: p, Err: rejected})
+			continue
 		}
-		parts = append(parts, *sm.Clone())
+		payload := p.Fields()[pdufield.ShortMessage].Bytes()
+		t.trackReceipt(msg.clonePart(payload))
+		parts = append(parts, *msg.clonePart(payload))
+	}
+	if len(failed) > 0 {
+		return parts, &LongMsgError{Parts: len(pdus), Failed: failed}
 	}
 	return parts, nil
 }
 
-func (t *Transmitter) submitMsg(sm *ShortMessage, p pdu.Body, dataCoding uint8) (*ShortMessage, error) {
+// SubmitAuto sends sm with Submit if it fits in a single short message,
+// or else with SubmitLongMsg, as reported by NeedsConcatenation, so
+// that callers never pass an over-length message to Submit. Messages
+// with UDH or ShiftTables set, which only SubmitLongMsg sends, are sent
+// with it too, in a single part if short enough, and submit_multi
+// messages with Submit. The text is re-encoded as configured by
+// EncodeFallback before its length is checked.
+//
+// Either way, it returns a copy of sm for each message sent, one for a
+// short message, with the text of each available from PartText. Errors
+// are those of Submit or SubmitLongMsg.
+func (t *Transmitter) SubmitAuto(sm *ShortMessage) ([]ShortMessage, error) {
+	msg := t.encode(sm, false)
+	if !msg.multi() && (msg.NeedsConcatenation() || len(msg.UDH) > 0 || msg.shiftTables().UDHLen() > 0) {
+		return t.SubmitLongMsg(sm)
+	}
+	resp, err := t.Submit(sm)
+	if err != nil {
+		return nil, err
+	}
+	return []ShortMessage{*resp.Clone()}, nil
+}
+
+// normalize returns sm with its addresses normalized as set by
+// NormalizeE164 and AutoSourceTON: sm itself if neither is set, or else
+// a normalized copy, sm being left unchanged on error too.
+func (t *Transmitter) normalize(sm *ShortMessage) (*ShortMessage, error) {
+	if !t.NormalizeE164 && !t.AutoSourceTON {
+		return sm, nil
+	}
+	msg := sm.Clone()
+	if t.NormalizeE164 {
+		if err := normalizeShortMessage(msg); err != nil {
+			return nil, err
+		}
+	}
+	if t.AutoSourceTON {
+		autoSourceTON(msg)
+	}
+	return msg, nil
+}
+
+// encode returns sm, or a copy of sm with its text re-encoded as
+// configured by EncodeFallback, and packed if pack and PackGSM7 are
+// set, so that the Text of sm is left untouched.
+func (t *Transmitter) encode(sm *ShortMessage, pack bool) *ShortMessage {
+	text := sm.Text
+	if len(t.EncodeFallback) > 0 {
+		text = pdutext.Fallback(text, t.EncodeFallback...)
+	}
+	gsm7, packed := text.(pdutext.GSM7)
+	packed = packed && pack && t.PackGSM7
+	if packed {
+		text = pdutext.GSM7Packed(gsm7)
+	}
+	if len(t.EncodeFallback) == 0 && !packed {
+		return sm
+	}
+	msg := sm.Clone()
+	msg.Text = text
+	return msg
+}
+
+// NeedsConcatenation reports whether the text of sm does not fit in a
+// single short message, and must be sent with SubmitLongMsg. A short
+// message holds 160 GSM 7-bit septets, escaped characters counting for
+// two, or 140 octets with other codecs, less the UDH of UDH and
+// ShiftTables if set. MessageClass and MWI only change data_coding,
+// and do not count.
+func (sm *ShortMessage) NeedsConcatenation() bool {
+	if sm.Text == nil {
+		return false
+	}
+	udh := pdufield.NewUDH(sm.UDH...)
+	udhLen := udh.Len() + sm.shiftTables().UDHLen()
+	n := len(sm.Text.Encode())
+	if _, ok := sm.Text.(pdutext.GSM7); ok {
+		return n > pdutext.MaxGSM7WithUDH(udhLen)
+	}
+	if udhLen > 0 {
+		udhLen++ // UDH length octet
+	}
+	return n > 140-udhLen
+}
+
+// shiftTables returns the ShiftTables of sm, which only apply to GSM7
+// text.
+func (sm *ShortMessage) shiftTables() pdutext.ShiftTables {
+	if _, ok := sm.Text.(pdutext.GSM7); ok {
+		return sm.ShiftTables
+	}
+	return pdutext.ShiftTables{}
+}
+
+// BuildPDUs returns the submit_sm or submit_multi PDU that Submit would
+// send for sm, without sending it. The PDU can be serialized, e.g. to
+// be queued and sent later with SubmitPDU. sm is not modified.
+func (sm *ShortMessage) BuildPDUs() ([]pdu.Body, error) {
+	if err := sm.checkQosTimeToLive(); err != nil {
+		return nil, err
+	}
+	if !sm.multi() {
+		return []pdu.Body{sm.submitPDU()}, nil
+	}
+	sm = sm.Clone()
+	if sm.Dst != "" {
+		sm.DstList = append(sm.DstList, sm.Dst)
+	}
+	p, err := sm.submitMultiPDU()
+	if err != nil {
+		return nil, err
+	}
+	return []pdu.Body{p}, nil
+}
+
+// checkQosTimeToLive returns ErrQosTimeToLive if QosTimeToLive does
+// not fit in the qos_time_to_live TLV.
+func (sm *ShortMessage) checkQosTimeToLive() error {
+	if sm.QosTimeToLive < 0 || sm.QosTimeToLive > MaxQosTimeToLive {
+		return ErrQosTimeToLive
+	}
+	return nil
+}
+
+// multi reports whether sm is sent with submit_multi.
+func (sm *ShortMessage) multi() bool {
+	return len(sm.DstList) > 0 || len(sm.DLs) > 0 || len(sm.Dsts) > 0
+}
+
+// esmClass returns the esm_class of sm, with the messaging mode of
+// MessageMode if set.
+func (sm *ShortMessage) esmClass() pdufield.ESMClassFlags {
+	e := pdufield.ESMClassFlags(sm.ESMClass)
+	if sm.MessageMode != pdufield.DefaultMode {
+		e = e.WithMode(sm.MessageMode)
+	}
+	return e
+}
+
+// dataCoding returns the data_coding of sm.
+func (sm *ShortMessage) dataCoding() pdutext.DataCoding {
+	if sm.MWI != nil {
+		return sm.Text.Type().WithMWI(*sm.MWI)
+	}
+	return pdutext.BuildDataCoding(sm.Text, sm.MessageClass, false)
+}
+
+// submitPDU returns the submit_sm PDU of sm.
+func (sm *ShortMessage) submitPDU() pdu.Body {
+	p := pdu.NewSubmitSM(sm.TLVFields)
 	f := p.Fields()
 	_ = f.Set(pdufield.SourceAddr, sm.Src)
 	_ = f.Set(pdufield.DestinationAddr, sm.Dst)
 	_ = f.Set(pdufield.ShortMessage, sm.Text)
 	_ = f.Set(pdufield.RegisteredDelivery, uint8(sm.Register))
-	// Check if the message has validity set.
-	if sm.Validity != time.Duration(0) {
-		_ = f.Set(pdufield.ValidityPeriod, convertValidity(sm.Validity))
+	if v, ok := sm.validityPeriod(); ok {
+		_ = f.Set(pdufield.ValidityPeriod, v)
 	}
 	_ = f.Set(pdufield.ServiceType, sm.ServiceType)
 	_ = f.Set(pdufield.SourceAddrTON, sm.SourceAddrTON)
 	_ = f.Set(pdufield.SourceAddrNPI, sm.SourceAddrNPI)
 	_ = f.Set(pdufield.DestAddrTON, sm.DestAddrTON)
 	_ = f.Set(pdufield.DestAddrNPI, sm.DestAddrNPI)
-	_ = f.Set(pdufield.ESMClass, sm.ESMClass)
+	_ = f.Set(pdufield.ESMClass, sm.esmClass())
 	_ = f.Set(pdufield.ProtocolID, sm.ProtocolID)
 	_ = f.Set(pdufield.PriorityFlag, sm.PriorityFlag)
 	_ = f.Set(pdufield.ScheduleDeliveryTime, sm.ScheduleDeliveryTime)
 	_ = f.Set(pdufield.ReplaceIfPresentFlag, sm.ReplaceIfPresentFlag)
 	_ = f.Set(pdufield.SMDefaultMsgID, sm.SMDefaultMsgID)
-	_ = f.Set(pdufield.DataCoding, dataCoding)
-	resp, err := t.do(p)
-	if err != nil {
-		return nil, err
+	_ = f.Set(pdufield.DataCoding, uint8(sm.dataCoding()))
+	sm.setTLVs(p)
+	return p
+}
+
+// dataPDU returns the data_sm PDU of sm.
+func (sm *ShortMessage) dataPDU() pdu.Body {
+	p := pdu.NewDataSM(sm.TLVFields)
+	f := p.Fields()
+	_ = f.Set(pdufield.ServiceType, sm.ServiceType)
+	_ = f.Set(pdufield.SourceAddrTON, sm.SourceAddrTON)
+	_ = f.Set(pdufield.SourceAddrNPI, sm.SourceAddrNPI)
+	_ = f.Set(pdufield.SourceAddr, sm.Src)
+	_ = f.Set(pdufield.DestAddrTON, sm.DestAddrTON)
+	_ = f.Set(pdufield.DestAddrNPI, sm.DestAddrNPI)
+	_ = f.Set(pdufield.DestinationAddr, sm.Dst)
+	_ = f.Set(pdufield.ESMClass, sm.esmClass())
+	_ = f.Set(pdufield.RegisteredDelivery, uint8(sm.Register))
+	var payload []byte
+	if sm.Text != nil {
+		_ = f.Set(pdufield.DataCoding, uint8(sm.dataCoding()))
+		payload = sm.Text.Encode()
 	}
-	sm.resp.Lock()
-	sm.resp.p = resp.PDU
-	sm.resp.Unlock()
-	if resp.PDU == nil {
-		return nil, fmt.Errorf("unexpected empty PDU")
+	_ = p.TLVFields().Set(pdutlv.TagMessagePayload, payload)
+	sm.setTLVs(p)
+	return p
+}
+
+// setTLVs sets the TLVs of p from the optional fields of sm, e.g.
+// set_dpf if SetDPF is set.
+func (sm *ShortMessage) setTLVs(p pdu.Body) {
+	tlv := p.TLVFields()
+	if sm.SetDPF != nil {
+		var v uint8
+		if *sm.SetDPF {
+			v = 0x01
+		}
+		_ = tlv.Set(pdutlv.TagSetDpf, v)
 	}
-	if id := resp.PDU.Header().ID; id != pdu.SubmitSMRespID {
-		return sm, fmt.Errorf("unexpected PDU ID: %s", id)
+	if sm.MoreMessagesToSend != nil {
+		var v uint8
+		if *sm.MoreMessagesToSend {
+			v = 0x01
+		}
+		_ = tlv.Set(pdutlv.TagMoreMessagesToSend, v)
 	}
-	if s := resp.PDU.Header().Status; s != 0 {
-		return sm, s
+	if sm.NumberOfMessages != nil {
+		_ = tlv.Set(pdutlv.TagNumberOfMessages, *sm.NumberOfMessages)
+	}
+	if sm.DestNetworkType != nil {
+		_ = tlv.Set(pdutlv.TagDestNetworkType, *sm.DestNetworkType)
+	}
+	if sm.DestBearerType != nil {
+		_ = tlv.Set(pdutlv.TagDestBearerType, *sm.DestBearerType)
+	}
+	if sm.MsValidity != nil {
+		_ = tlv.Set(pdutlv.TagMsValidity, *sm.MsValidity)
+	}
+	if sm.PrivacyIndicator != nil {
+		_ = tlv.Set(pdutlv.TagPrivacyIndicator, *sm.PrivacyIndicator)
+	}
+	if sm.PayloadType != nil {
+		_ = tlv.Set(pdutlv.TagPayloadType, *sm.PayloadType)
+	}
+	if sm.ItsReplyType != nil {
+		_ = tlv.Set(pdutlv.TagItsReplyType, *sm.ItsReplyType)
+	}
+	if sm.ItsSessionInfo != nil {
+		_ = tlv.Set(pdutlv.TagItsSessionInfo, *sm.ItsSessionInfo)
+	}
+	if sm.QosTimeToLive != 0 {
+		_ = tlv.Set(pdutlv.TagQosTimeToLive, uint32(sm.QosTimeToLive/time.Millisecond))
+	}
+	if sm.CallbackNum != nil {
+		_ = tlv.Set(pdutlv.TagCallbackNum, *sm.CallbackNum)
+	}
+	if sm.CallbackNumPresInd != nil {
+		_ = tlv.Set(pdutlv.TagCallbackNumPresInd, *sm.CallbackNumPresInd)
+	}
+	if sm.CallbackNumAtag != nil {
+		_ = tlv.Set(pdutlv.TagCallbackNumAtag, *sm.CallbackNumAtag)
 	}
-	return sm, resp.Err
 }
 
-func (t *Transmitter) submitMsgMulti(sm *ShortMessage, p pdu.Body, dataCoding uint8) (*ShortMessage, error) {
-	numberOfDest := len(sm.DstList) + len(sm.DLs) // TODO: Validate numbers and lists according to size
+// submitMultiPDU returns the submit_multi PDU of sm.
+func (sm *ShortMessage) submitMultiPDU() (pdu.Body, error) {
+	numberOfDest := len(sm.DstList) + len(sm.Dsts) + len(sm.DLs) // TODO: Validate numbers and lists according to size
 	if numberOfDest > MaxDestinationAddress {
 		return nil, fmt.Errorf("Error: Max number of destination addresses allowed is %d, trying to send to %d",
 			MaxDestinationAddress, numberOfDest)
 	}
-	// Put destination addresses and lists inside an byte array
-	var bArray []byte
-	// destination addresses
-	for _, destAddr := range sm.DstList {
-		// 1 - SME Address
-		bArray = append(bArray, byte(0x01))
-		bArray = append(bArray, byte(sm.DestAddrTON))
-		bArray = append(bArray, byte(sm.DestAddrNPI))
-		bArray = append(bArray, []byte(destAddr)...)
-		// null terminator
-		bArray = append(bArray, byte(0x00))
-	}
-
-	// distribution lists
-	for _, destList := range sm.DLs {
-		// 2 - Distribution List
-		bArray = append(bArray, byte(0x02))
-		bArray = append(bArray, []byte(destList)...)
-		// null terminator
-		bArray = append(bArray, byte(0x00))
-	}
+	bArray := destAddresses(sm)
 
+	p := pdu.NewSubmitMulti(sm.TLVFields)
 	f := p.Fields()
 	_ = f.Set(pdufield.SourceAddr, sm.Src)
 	_ = f.Set(pdufield.DestinationList, bArray)
 	_ = f.Set(pdufield.ShortMessage, sm.Text)
 	_ = f.Set(pdufield.NumberDests, uint8(numberOfDest))
 	_ = f.Set(pdufield.RegisteredDelivery, uint8(sm.Register))
-	// Check if the message has validity set.
-	if sm.Validity != time.Duration(0) {
-		_ = f.Set(pdufield.ValidityPeriod, convertValidity(sm.Validity))
+	if v, ok := sm.validityPeriod(); ok {
+		_ = f.Set(pdufield.ValidityPeriod, v)
 	}
 	_ = f.Set(pdufield.ServiceType, sm.ServiceType)
 	_ = f.Set(pdufield.SourceAddrTON, sm.SourceAddrTON)
 	_ = f.Set(pdufield.SourceAddrNPI, sm.SourceAddrNPI)
-	_ = f.Set(pdufield.ESMClass, sm.ESMClass)
+	_ = f.Set(pdufield.ESMClass, sm.esmClass())
 	_ = f.Set(pdufield.ProtocolID, sm.ProtocolID)
 	_ = f.Set(pdufield.PriorityFlag, sm.PriorityFlag)
 	_ = f.Set(pdufield.ScheduleDeliveryTime, sm.ScheduleDeliveryTime)
 	_ = f.Set(pdufield.ReplaceIfPresentFlag, sm.ReplaceIfPresentFlag)
 	_ = f.Set(pdufield.SMDefaultMsgID, sm.SMDefaultMsgID)
-	_ = f.Set(pdufield.DataCoding, dataCoding)
+	_ = f.Set(pdufield.DataCoding, uint8(sm.dataCoding()))
+	return p, nil
+}
+
+// BuildLongPDUs returns the submit_sm PDUs that SubmitLongMsg would
+// send for sm, one per part, without sending them. An error is returned
+// if the UDH of sm leaves too little room for text, or a *PartsError if
+// the message needs more than MaxConcatenatedParts parts.
+func (sm *ShortMessage) BuildLongPDUs() ([]pdu.Body, error) {
+	payloads, err := sm.longPayloads()
+	if err != nil {
+		return nil, err
+	}
+	if len(payloads) > MaxConcatenatedParts {
+		return nil, &PartsError{Parts: len(payloads), Max: MaxConcatenatedParts}
+	}
+	return sm.longPDUs(payloads), nil
+}
+
+// longPayloads returns the encoded text of sm split into the payloads
+// of a concatenated message, or pdutext.ErrUDHTooLong.
+func (sm *ShortMessage) longPayloads() ([][]byte, error) {
+	extraUDH := pdufield.NewUDH(sm.UDH...)
+	udhLen := 6 + extraUDH.Len() // 6 for the concatenation IE
+	if _, ok := sm.Text.(pdutext.GSM7); ok {
+		udhLen += sm.ShiftTables.UDHLen()
+	}
+	return pdutext.SplitPayloads(sm.Text.Encode(), sm.Text, udhLen)
+}
+
+// longPDUs returns the submit_sm PDUs of a concatenated message with
+// the given payloads.
+func (sm *ShortMessage) longPDUs(payloads [][]byte) []pdu.Body {
+	countParts := len(payloads)
+
+	pdus := make([]pdu.Body, 0, countParts)
+
+	// The reference is always 16-bit, the payloads being sized for the
+	// 6 octets of its IE.
+	rn := uint16(0x100 + rand.IntN(0x10000-0x100))
+	for i := range countParts {
+		udh := pdufield.NewUDH(pdufield.NewIEConcatenatedShortMessage(rn, countParts, i+1))
+		if _, ok := sm.Text.(pdutext.GSM7); ok {
+			udh.Append(pdufield.NewIENationalLanguageShift(sm.ShiftTables)...)
+		}
+		udh.Append(sm.UDH...)
+		p := pdu.NewSubmitSM(sm.TLVFields)
+		f := p.Fields()
+		_ = f.Set(pdufield.SourceAddr, sm.Src)
+		_ = f.Set(pdufield.DestinationAddr, sm.Dst)
+		_ = f.Set(pdufield.ShortMessage, pdutext.Raw(payloads[i]))
+		_ = f.Set(pdufield.RegisteredDelivery, uint8(sm.Register))
+		if v, ok := sm.validityPeriod(); ok {
+			_ = f.Set(pdufield.ValidityPeriod, v)
+		}
+		_ = f.Set(pdufield.ServiceType, sm.ServiceType)
+		_ = f.Set(pdufield.SourceAddrTON, sm.SourceAddrTON)
+		_ = f.Set(pdufield.SourceAddrNPI, sm.SourceAddrNPI)
+		_ = f.Set(pdufield.DestAddrTON, sm.DestAddrTON)
+		_ = f.Set(pdufield.DestAddrNPI, sm.DestAddrNPI)
+		_ = f.Set(pdufield.ESMClass, sm.esmClass().WithUDH())
+		_ = f.Set(pdufield.ProtocolID, sm.ProtocolID)
+		_ = f.Set(pdufield.PriorityFlag, sm.PriorityFlag)
+		_ = f.Set(pdufield.ScheduleDeliveryTime, sm.ScheduleDeliveryTime)
+		_ = f.Set(pdufield.ReplaceIfPresentFlag, sm.ReplaceIfPresentFlag)
+		_ = f.Set(pdufield.SMDefaultMsgID, sm.SMDefaultMsgID)
+		_ = f.Set(pdufield.DataCoding, uint8(sm.dataCoding()))
+		_ = f.Set(pdufield.UDHLength, uint8(udh.Len()))
+		_ = f.Set(pdufield.GSMUserData, &udh)
+		_ = f.Set(pdufield.SMLength, uint8(f[pdufield.ShortMessage].Len()+udh.Len()+1)) // +1 for UDHLength octet
+		sm.setTLVs(p)
+		pdus = append(pdus, p)
+	}
+	return pdus
+}
+
+// submitMsg sends the submit_sm or submit_multi PDU p of sm, and
+// updates sm with the response, expected to have the given ID.
+func (t *Transmitter) submitMsg(sm *ShortMessage, p pdu.Body, respID pdu.ID) (*ShortMessage, error) {
 	resp, err := t.do(p)
 	if err != nil {
 		return nil, err
@@ -523,15 +1498,73 @@ func (t *Transmitter) submitMsgMulti(sm *ShortMessage, p pdu.Body, dataCoding ui
 	if resp.PDU == nil {
 		return nil, fmt.Errorf("unexpected empty PDU")
 	}
-	if id := resp.PDU.Header().ID; id != pdu.SubmitMultiRespID {
+	if id := resp.PDU.Header().ID; id != respID {
 		return sm, fmt.Errorf("unexpected PDU ID: %s", id)
 	}
-	if s := resp.PDU.Header().Status; s != 0 {
-		return sm, s
+	if err := statusError(resp.PDU); err != nil {
+		return sm, err
+	}
+	if resp.Err == nil {
+		t.trackReceipt(sm)
 	}
 	return sm, resp.Err
 }
 
+// destAddresses returns the dest_addresses of submit multi for the
+// SME addresses and distribution lists of sm.
+func destAddresses(sm *ShortMessage) []byte {
+	var bArray []byte
+	// destination addresses
+	for _, destAddr := range sm.DstList {
+		ds := pdufield.NewDestSme(sm.DestAddrTON, sm.DestAddrNPI, destAddr)
+		bArray = append(bArray, ds.Bytes()...)
+	}
+	for _, dst := range sm.Dsts {
+		ds := pdufield.NewDestSme(dst.TON, dst.NPI, dst.Addr)
+		bArray = append(bArray, ds.Bytes()...)
+	}
+
+	// distribution lists
+	for _, destList := range sm.DLs {
+		bArray = append(bArray, byte(pdufield.DistributionListFlag))
+		bArray = append(bArray, []byte(destList)...)
+		// null terminator
+		bArray = append(bArray, byte(0x00))
+	}
+	return bArray
+}
+
+// SubmitPDU sends the given PDU as is and returns the matching response.
+// The PDU is assigned a new sequence number if it has none, or always
+// if SeqStart is set, and counts against the window size like any other
+// request.
+//
+// SubmitPDU is meant for advanced use, such as hand crafted submit_sm with
+// vendor specific fields. The caller owns the correctness of the PDU fields,
+// no validation or encoding is performed. If the response has a non-zero
+// command status, it is returned along with the status as error.
+func (t *Transmitter) SubmitPDU(p pdu.Body) (pdu.Body, error) {
+	release, err := t.acquire()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	if p.Header().Seq == 0 {
+		p.Header().Seq = pdu.NextSeq()
+	}
+	resp, err := t.do(p)
+	if err != nil {
+		return nil, err
+	}
+	if resp.PDU == nil {
+		return nil, fmt.Errorf("unexpected empty PDU")
+	}
+	if err := statusError(resp.PDU); err != nil {
+		return resp.PDU, err
+	}
+	return resp.PDU, nil
+}
+
 // QueryResp contains the parsed the response of a QuerySM request.
 type QueryResp struct {
 	MsgID     string
@@ -557,8 +1590,8 @@ func (t *Transmitter) QuerySM(src, msgid string, srcTON, srcNPI uint8) (*QueryRe
 	if id := resp.PDU.Header().ID; id != pdu.QuerySMRespID {
 		return nil, fmt.Errorf("unexpected PDU ID: %s", id)
 	}
-	if s := resp.PDU.Header().Status; s != 0 {
-		return nil, s
+	if err := statusError(resp.PDU); err != nil {
+		return nil, err
 	}
 	f = resp.PDU.Fields()
 	ms := f[pdufield.MessageState]
@@ -599,6 +1632,124 @@ func (t *Transmitter) QuerySM(src, msgid string, srcTON, srcNPI uint8) (*QueryRe
 	return qr, nil
 }
 
+// DataResp contains the parsed data_sm_resp of a SubmitData request.
+// The optional TLVs are nil if not sent by the SMSC.
+type DataResp struct {
+	MsgID                 string
+	DeliveryFailureReason *pdutlv.DeliveryFailureReason
+	NetworkErrorCode      *pdutlv.NetworkErrorCode
+	AdditionalStatusInfo  string
+	DpfResult             *pdutlv.DpfResult
+}
+
+// newDataResp returns the DataResp of the data_sm_resp p.
+func newDataResp(p pdu.Body) *DataResp {
+	dr := &DataResp{}
+	if f := p.Fields()[pdufield.MessageID]; f != nil {
+		dr.MsgID = f.String()
+	}
+	tlv := p.TLVFields()
+	if v, ok := tlv.DeliveryFailureReason(); ok {
+		dr.DeliveryFailureReason = &v
+	}
+	if v, ok := tlv.NetworkErrorCode(); ok {
+		dr.NetworkErrorCode = &v
+	}
+	if v, ok := tlv.String(pdutlv.TagAdditionalStatusInfoText); ok {
+		dr.AdditionalStatusInfo = v
+	}
+	if v, ok := tlv.DpfResult(); ok {
+		dr.DpfResult = &v
+	}
+	return dr
+}
+
+// SubmitData sends sm with data_sm, its text in the message_payload
+// TLV, and returns the parsed response. sm is updated with the response
+// as with Submit. EncodeFallback, NormalizeE164, AutoSourceTON and
+// StrictValidation apply as for Submit, other than ProtocolID, Validity
+// and the other fields data_sm lacks being ignored.
+//
+// If the response has a non-zero command status, it is returned along
+// with the status as error, with the failure TLVs the SMSC sent, e.g.
+// delivery_failure_reason.
+func (t *Transmitter) SubmitData(sm *ShortMessage) (*DataResp, error) {
+	release, err := t.acquire()
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	msg, err := t.normalize(sm)
+	if err != nil {
+		return nil, err
+	}
+	msg = t.encode(msg, false)
+	if t.StrictValidation {
+		if err := validateShortMessage(msg, false); err != nil {
+			return nil, err
+		}
+	}
+	if err := msg.checkQosTimeToLive(); err != nil {
+		return nil, err
+	}
+	resp, err := t.do(msg.dataPDU())
+	if err != nil {
+		return nil, err
+	}
+	sm.resp.Lock()
+	sm.resp.p = resp.PDU
+	sm.resp.Unlock()
+	if resp.PDU == nil {
+		return nil, fmt.Errorf("unexpected empty PDU")
+	}
+	if id := resp.PDU.Header().ID; id != pdu.DataSMRespID {
+		return nil, fmt.Errorf("unexpected PDU ID: %s", id)
+	}
+	dr := newDataResp(resp.PDU)
+	if err := statusError(resp.PDU); err != nil {
+		return dr, err
+	}
+	t.trackReceipt(sm)
+	return dr, nil
+}
+
+// validityPeriod returns the validity_period of sm, and false if it
+// is left empty for the SMSC default.
+func (sm *ShortMessage) validityPeriod() (string, bool) {
+	switch {
+	case sm.Validity == 0 || sm.ValidityMode == ValiditySMSCDefault:
+		return "", false
+	case sm.ValidityMode == ValidityRelative:
+		return relativeValidity(sm.Validity), true
+	}
+	return convertValidity(sm.Validity), true
+}
+
+// maxRelativeValidity is the longest relative validity period,
+// 99 years, 11 months, 34 days, 23:59:59.
+const maxRelativeValidity = (99*365+11*30+34)*24*time.Hour + 24*time.Hour - time.Second
+
+// relativeValidity returns d in the relative time format
+// YYMMDDhhmmss000R, see SMPP3.4 spec 7.1.1. A year counts 365 days and
+// a month 30 days, so e.g. 400 days is 1 year, 1 month and 5 days.
+// Durations are rounded down to the second, and clamped to 0 and
+// maxRelativeValidity.
+func relativeValidity(d time.Duration) string {
+	const day = 24 * time.Hour
+	d = min(max(d, 0), maxRelativeValidity)
+	years := d / (365 * day)
+	d -= years * 365 * day
+	months := min(d/(30*day), 11)
+	d -= months * 30 * day
+	days := d / day
+	d -= days * day
+	return fmt.Sprintf("%02d%02d%02d%02d%02d%02d000R",
+		int(years), int(months), int(days),
+		int(d/time.Hour), int(d%time.Hour/time.Minute), int(d%time.Minute/time.Second))
+}
+
+// convertValidity returns now plus d in the absolute time format
+// YYMMDDhhmmss000+, in UTC. See relativeValidity for the relative format.
 func convertValidity(d time.Duration) string {
 	validity := time.Now().UTC().Add(d)
 	// Absolute time format YYMMDDhhmmsstnnp, see SMPP3.4 spec 7.1.1.
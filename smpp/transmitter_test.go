@@ -6,8 +6,15 @@ package smpp
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"math/rand/v2"
+	"net"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -16,6 +23,7 @@ import (
 	"github.com/florentchauveau/go-smpp/smpp/pdu"
 	"github.com/florentchauveau/go-smpp/smpp/pdu/pdufield"
 	"github.com/florentchauveau/go-smpp/smpp/pdu/pdutext"
+	"github.com/florentchauveau/go-smpp/smpp/pdu/pdutlv"
 	"github.com/florentchauveau/go-smpp/smpp/smpptest"
 )
 
@@ -66,11 +74,24 @@ func TestShortMessage(t *testing.T) {
 	}
 }
 
-func TestShortMessageWindowSize(t *testing.T) {
+func serializePDU(t *testing.T, p pdu.Body) []byte {
+	t.Helper()
+	var b bytes.Buffer
+	if err := p.SerializeTo(&b); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+func TestBuildPDUs(t *testing.T) {
+	received := make(chan pdu.Body, 10)
 	s := smpptest.NewUnstartedServer()
 	s.Handler = func(c smpptest.Conn, p pdu.Body) {
-		time.Sleep(200 * time.Millisecond)
+		received <- p
 		r := pdu.NewSubmitSMResp()
+		if p.Header().ID == pdu.SubmitMultiID {
+			r = pdu.NewSubmitMultiResp()
+		}
 		r.Header().Seq = p.Header().Seq
 		_ = r.Fields().Set(pdufield.MessageID, "foobar")
 		_ = c.Write(r)
@@ -78,11 +99,9 @@ func TestShortMessageWindowSize(t *testing.T) {
 	s.Start()
 	defer s.Close()
 	tx := &Transmitter{
-		Addr:        s.Addr(),
-		User:        smpptest.DefaultUser,
-		Passwd:      smpptest.DefaultPasswd,
-		WindowSize:  2,
-		RespTimeout: time.Second,
+		Addr:   s.Addr(),
+		User:   smpptest.DefaultUser,
+		Passwd: smpptest.DefaultPasswd,
 	}
 	defer tx.Close()
 	conn := <-tx.Bind()
@@ -91,192 +110,112 @@ func TestShortMessageWindowSize(t *testing.T) {
 	default:
 		t.Fatal(conn.Error())
 	}
-	msgc := make(chan *ShortMessage, 3)
-	defer close(msgc)
-	errc := make(chan error, 3)
-	for i := 0; i < 3; i++ {
-		go func(msgc chan *ShortMessage, errc chan error) {
-			m := <-msgc
-			if m == nil {
-				return
-			}
-			_, err := tx.Submit(m)
-			errc <- err
-		}(msgc, errc)
-		msgc <- &ShortMessage{
-			Src:      "root",
-			Dst:      "foobar",
-			Text:     pdutext.Raw("Lorem ipsum"),
-			Validity: 10 * time.Minute,
-			Register: pdufield.NoDeliveryReceipt,
+	for _, sm := range []*ShortMessage{
+		{Src: "root", Dst: "foobar", Text: pdutext.Latin1("Lorem ipsum"), Validity: 10 * time.Minute},
+		{Src: "root", Dst: "foobar", DstList: []string{"123"}, DLs: []string{"list"}, Text: pdutext.Raw("Lorem ipsum")},
+	} {
+		built, err := sm.BuildPDUs()
+		if err != nil {
+			t.Fatal(err)
 		}
-	}
-	nerr := 0
-	for i := 0; i < 3; i++ {
-		if <-errc == ErrMaxWindowSize {
-			nerr++
+		if len(built) != 1 {
+			t.Fatalf("unexpected PDUs: want 1, have %d", len(built))
 		}
-	}
-	if nerr != 1 {
-		t.Fatalf("unexpected # of errors. want 1, have %d", nerr)
-	}
-}
-
-func TestLongMessage(t *testing.T) {
-	s := smpptest.NewUnstartedServer()
-	count := 0
-	s.Handler = func(c smpptest.Conn, p pdu.Body) {
-		switch p.Header().ID {
-		case pdu.SubmitSMID:
-			r := pdu.NewSubmitSMResp()
-			r.Header().Seq = p.Header().Seq
-			_ = r.Fields().Set(pdufield.MessageID, fmt.Sprintf("foobar%d", count))
-			count++
-			_ = c.Write(r)
-		default:
-			smpptest.EchoHandler(c, p)
+		if _, err := tx.Submit(sm); err != nil {
+			t.Fatal(err)
+		}
+		have := <-received
+		built[0].Header().Seq = have.Header().Seq
+		want := serializePDU(t, built[0])
+		if b := serializePDU(t, have); !bytes.Equal(want, b) {
+			t.Fatalf("unexpected PDU:\nwant % x\nhave % x", want, b)
 		}
 	}
-	s.Start()
-	defer s.Close()
-	tx := &Transmitter{
-		Addr:   s.Addr(),
-		User:   smpptest.DefaultUser,
-		Passwd: smpptest.DefaultPasswd,
+
+	sm := &ShortMessage{
+		Src:  "root",
+		Dst:  "foobar",
+		Text: pdutext.GSM7(strings.Repeat("Lorem ipsum ", 30)),
 	}
-	defer tx.Close()
-	conn := <-tx.Bind()
-	switch conn.Status() {
-	case Connected:
-	default:
-		t.Fatal(conn.Error())
+	built, err := sm.BuildLongPDUs()
+	if err != nil {
+		t.Fatal(err)
 	}
-	parts, err := tx.SubmitLongMsg(&ShortMessage{
-		Src:      "root",
-		Dst:      "foobar",
-		Text:     pdutext.Raw("Lorem ipsum dolor sit amet, consectetur adipiscing elit. Nam consequat nisl enim, vel finibus neque aliquet sit amet. Interdum et malesuada fames ac ante ipsum primis in faucibus."),
-		Validity: 10 * time.Minute,
-		Register: pdufield.NoDeliveryReceipt,
-	})
+	parts, err := tx.SubmitLongMsg(sm)
 	if err != nil {
 		t.Fatal(err)
 	}
-	if len(parts) != 2 {
-		t.Fatalf("expected %d responses, but received %d", 2, len(parts))
+	if len(built) != len(parts) {
+		t.Fatalf("unexpected PDUs: want %d, have %d", len(parts), len(built))
 	}
-	for index := range parts {
-		msgid := parts[index].RespID()
-		if msgid == "" {
-			t.Fatalf("pdu does not contain msgid: %#v", parts[index].Resp())
-		}
-		if msgid != fmt.Sprintf("foobar%d", index) {
-			t.Fatalf("unexpected msgid: want foobar%d, have %q", index, msgid)
+	for _, p := range built {
+		have := <-received
+		p.Header().Seq = have.Header().Seq
+		// The concatenation reference number is random.
+		udh := p.Fields()[pdufield.GSMUserData].(*pdufield.UDH)
+		udh.IE[0] = have.Fields()[pdufield.GSMUserData].(*pdufield.UDH).IE[0]
+		want := serializePDU(t, p)
+		if b := serializePDU(t, have); !bytes.Equal(want, b) {
+			t.Fatalf("unexpected PDU:\nwant % x\nhave % x", want, b)
 		}
 	}
 }
 
-func TestLongMessageEncode(t *testing.T) {
+func TestBuildLongPDUsParts(t *testing.T) {
+	// 133 octets per part: 140, minus the 6 octets of the concatenation
+	// UDH and its length octet.
 	sm := &ShortMessage{
-		Src:      "root",
-		Dst:      "foobar",
-		Text:     pdutext.GSM7("Lorem ipsum dolor sit amet, consectetur adipiscing elit. Nam consequat nisl enim, vel finibus neque aliquet sit amet. Interdum et malesuada fames ac ante ipsum primis in faucibus."),
-		Validity: 10 * time.Minute,
-		Register: pdufield.NoDeliveryReceipt,
+		Src:  "root",
+		Dst:  "foobar",
+		Text: pdutext.Raw(bytes.Repeat([]byte{0x42}, MaxConcatenatedParts*133)),
 	}
-
-	maxLen := 133 // 140-7 (UDH with 2 byte reference number)
-	switch sm.Text.(type) {
-	case pdutext.GSM7:
-		maxLen = 152 // to avoid an escape character being split between payloads
-	case pdutext.GSM7Packed:
-		maxLen = 132 // to avoid an escape character being split between payloads
-	case pdutext.UCS2:
-		maxLen = 132 // to avoid a character being split between payloads
+	pdus, err := sm.BuildLongPDUs()
+	if err != nil {
+		t.Fatal(err)
 	}
-	rawMsg := sm.Text.Encode()
-	countParts := int((len(rawMsg)-1)/maxLen) + 1
-	ref := uint16(rand.IntN(0xFFFF))
-	for i := range countParts {
-		udh := pdufield.NewUDHConcatenatedShortMessage(ref, countParts, i+1)
-		p := pdu.NewSubmitSM(sm.TLVFields)
-		f := p.Fields()
-		_ = f.Set(pdufield.SourceAddr, sm.Src)
-		_ = f.Set(pdufield.DestinationAddr, sm.Dst)
-		if i != countParts-1 {
-			_ = f.Set(pdufield.ShortMessage, pdutext.Raw(rawMsg[i*maxLen:(i+1)*maxLen]))
-		} else {
-			_ = f.Set(pdufield.ShortMessage, pdutext.Raw(rawMsg[i*maxLen:]))
-		}
-		_ = f.Set(pdufield.RegisteredDelivery, uint8(sm.Register))
-		if sm.Validity != 0 {
-			_ = f.Set(pdufield.ValidityPeriod, convertValidity(sm.Validity))
-		}
-		_ = f.Set(pdufield.ServiceType, sm.ServiceType)
-		_ = f.Set(pdufield.SourceAddrTON, sm.SourceAddrTON)
-		_ = f.Set(pdufield.SourceAddrNPI, sm.SourceAddrNPI)
-		_ = f.Set(pdufield.DestAddrTON, sm.DestAddrTON)
-		_ = f.Set(pdufield.DestAddrNPI, sm.DestAddrNPI)
-		_ = f.Set(pdufield.ESMClass, pdufield.ESMClassUDHIndicator)
-		_ = f.Set(pdufield.ProtocolID, sm.ProtocolID)
-		_ = f.Set(pdufield.PriorityFlag, sm.PriorityFlag)
-		_ = f.Set(pdufield.ScheduleDeliveryTime, sm.ScheduleDeliveryTime)
-		_ = f.Set(pdufield.ReplaceIfPresentFlag, sm.ReplaceIfPresentFlag)
-		_ = f.Set(pdufield.SMDefaultMsgID, sm.SMDefaultMsgID)
-		_ = f.Set(pdufield.DataCoding, uint8(sm.Text.Type()))
-		_ = f.Set(pdufield.UDHLength, uint8(udh.Len()))
-		_ = f.Set(pdufield.GSMUserData, &udh)
-		_ = f.Set(pdufield.SMLength, uint8(f[pdufield.ShortMessage].Len()+udh.Len()+1)) // +1 for UDHLength octet
-		wire := bytes.NewBuffer(nil)
-		err := p.SerializeTo(wire)
-		if err != nil {
-			t.Fatalf("error marshalling pdu: %v", err)
-		}
-		encoded, err := pdu.Decode(wire)
+	if len(pdus) != MaxConcatenatedParts {
+		t.Fatalf("unexpected parts: want %d, have %d", MaxConcatenatedParts, len(pdus))
+	}
+	sm.Text = pdutext.Raw(bytes.Repeat([]byte{0x42}, MaxConcatenatedParts*133+1))
+	_, err = sm.BuildLongPDUs()
+	var perr *PartsError
+	if !errors.As(err, &perr) {
+		t.Fatalf("unexpected error: want *PartsError, have %v", err)
+	}
+	if perr.Parts != MaxConcatenatedParts+1 || perr.Max != MaxConcatenatedParts {
+		t.Fatalf("unexpected parts error: want %d parts max %d, have %d parts max %d",
+			MaxConcatenatedParts+1, MaxConcatenatedParts, perr.Parts, perr.Max)
+	}
+	// The reference is random, and always 16-bit.
+	sm.Text = pdutext.Raw(bytes.Repeat([]byte{0x42}, 200))
+	for range 100 {
+		pdus, err := sm.BuildLongPDUs()
 		if err != nil {
-			t.Fatalf("error unmarshalling pdu: %v", err)
-		}
-		encodedSM := encoded.Fields()[pdufield.ShortMessage]
-		if encodedSM.String() != f[pdufield.ShortMessage].String() {
-			t.Fatalf("part %d: expected short message %q, got %q", i+1, f[pdufield.ShortMessage].String(), encodedSM.String())
-		}
-		// encoded.UDH()
-		udhField := encoded.UDH()
-		if udhField == nil {
-			t.Fatalf("part %d: missing UDH field", i+1)
-		}
-		concatenated, expectedRef, total, part := udhField.IsConcatenated()
-		if !concatenated {
-			t.Fatalf("part %d: UDH IsConcatenated = %v, want %v", i+1, concatenated, true)
-		}
-		if total != countParts {
-			t.Fatalf("part %d: UDH IsConcatenated total = %d, want %d", i+1, total, countParts)
+			t.Fatal(err)
 		}
-		if part != i+1 {
-			t.Fatalf("part %d: UDH IsConcatenated part = %d, want %d", i+1, part, i+1)
-		}
-		if expectedRef != int(ref) {
-			t.Fatalf("part %d: UDH IsConcatenated ref = %d, want %d", i+1, expectedRef, ref)
+		udh := pdus[0].UDH()
+		if iei := udh.IE[0].IEI; iei != pdufield.UDHIEIConcatenatedShortMessage16Bit {
+			t.Fatalf("unexpected concatenation IE: want %#02x, have %#02x", pdufield.UDHIEIConcatenatedShortMessage16Bit, iei)
 		}
 	}
 }
 
-func TestLongMessageAsUCS2(t *testing.T) {
+func TestSubmitFlash(t *testing.T) {
+	type result struct {
+		dc   uint8
+		text string
+	}
+	received := make(chan result, 1)
 	s := smpptest.NewUnstartedServer()
-	var receivedMsg string
-	shortMsg := "Lorem ipsum dolor sit amet, consectetur adipiscing elit. Nam consequat nisl enim, vel finibus neque aliquet sit amet. Interdum et malesuada fames ac ante ipsum primis in faucibus. ✓"
-	count := 0
 	s.Handler = func(c smpptest.Conn, p pdu.Body) {
-		switch p.Header().ID {
-		case pdu.SubmitSMID:
-			r := pdu.NewSubmitSMResp()
-			r.Header().Seq = p.Header().Seq
-			_ = r.Fields().Set(pdufield.MessageID, fmt.Sprintf("foobar%d", count))
-			count++
-			receivedMsg = receivedMsg + p.Fields()[pdufield.ShortMessage].String()
-			_ = c.Write(r)
-		default:
-			smpptest.EchoHandler(c, p)
-		}
+		f := p.Fields()
+		dc, _ := f.Uint8(pdufield.DataCoding)
+		text, _ := f.String(pdufield.ShortMessage)
+		received <- result{dc, text}
+		r := pdu.NewSubmitSMResp()
+		r.Header().Seq = p.Header().Seq
+		_ = r.Fields().Set(pdufield.MessageID, "foobar")
+		_ = c.Write(r)
 	}
 	s.Start()
 	defer s.Close()
@@ -292,42 +231,42 @@ func TestLongMessageAsUCS2(t *testing.T) {
 	default:
 		t.Fatal(conn.Error())
 	}
-	parts, err := tx.SubmitLongMsg(&ShortMessage{
-		Src:      "root",
-		Dst:      "foobar",
-		Text:     pdutext.UCS2(shortMsg),
-		Validity: 10 * time.Minute,
-		Register: pdufield.NoDeliveryReceipt,
-	})
-	if err != nil {
-		t.Fatal(err)
-	}
-	if len(parts) != 3 {
-		t.Fatalf("expected %d responses, but received %d", 3, len(parts))
+	test := []struct {
+		text pdutext.Codec
+		want uint8
+	}{
+		{pdutext.GSM7("Flash"), 0x10},
+		{pdutext.UCS2("Flash"), 0x18},
 	}
-	for index := range parts {
-		msgid := parts[index].RespID()
-		if msgid == "" {
-			t.Fatalf("pdu does not contain msgid: %#v", parts[index].Resp())
+	for _, tc := range test {
+		_, err := tx.Submit(&ShortMessage{
+			Src:          "root",
+			Dst:          "foobar",
+			Text:         tc.text,
+			MessageClass: pdutext.Class0,
+		})
+		if err != nil {
+			t.Fatal(err)
 		}
-
-		if receivedMsg != shortMsg {
-			t.Fatalf("receivedMsg: %v, does not match shortMsg: %v", receivedMsg, shortMsg)
+		have := <-received
+		if have.dc != tc.want {
+			t.Fatalf("unexpected data coding: want %#x, have %#x", tc.want, have.dc)
 		}
-
-		if msgid != fmt.Sprintf("foobar%d", index) {
-			t.Fatalf("unexpected msgid: want foobar%d, have %q", index, msgid)
+		if have.text != "Flash" {
+			t.Fatalf("unexpected text: want %q, have %q", "Flash", have.text)
 		}
 	}
 }
 
-func TestQuerySM(t *testing.T) {
+func TestSubmitSetDPF(t *testing.T) {
 	s := smpptest.NewUnstartedServer()
 	s.Handler = func(c smpptest.Conn, p pdu.Body) {
-		r := pdu.NewQuerySMResp()
+		r := pdu.NewSubmitSMResp()
 		r.Header().Seq = p.Header().Seq
-		_ = r.Fields().Set(pdufield.MessageID, p.Fields()[pdufield.MessageID])
-		_ = r.Fields().Set(pdufield.MessageState, 2)
+		_ = r.Fields().Set(pdufield.MessageID, "foobar")
+		if v, ok := p.TLVFields().SetDpf(); ok && v {
+			_ = r.TLVFields().Set(pdutlv.TagDpfResult, pdutlv.DpfSet)
+		}
 		_ = c.Write(r)
 	}
 	s.Start()
@@ -344,50 +283,51 @@ func TestQuerySM(t *testing.T) {
 	default:
 		t.Fatal(conn.Error())
 	}
-	qr, err := tx.QuerySM("root", "13", uint8(5), uint8(0))
+	sm, err := tx.Submit(&ShortMessage{
+		Src:  "root",
+		Dst:  "foobar",
+		Text: pdutext.Raw("Lorem ipsum"),
+	})
 	if err != nil {
 		t.Fatal(err)
 	}
-	if qr.MsgID != "13" {
-		t.Fatalf("unexpected msgid: want 13, have %s", qr.MsgID)
+	if _, ok := sm.DpfResult(); ok {
+		t.Fatal("unexpected dpf_result without set_dpf")
 	}
-	if qr.MsgState != "DELIVERED" {
-		t.Fatalf("unexpected state: want DELIVERED, have %q", qr.MsgState)
+	dpf := true
+	sm, err = tx.Submit(&ShortMessage{
+		Src:    "root",
+		Dst:    "foobar",
+		Text:   pdutext.Raw("Lorem ipsum"),
+		SetDPF: &dpf,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := sm.DpfResult(); !ok || !v {
+		t.Fatalf("unexpected dpf_result: want true, have %t (%t)", v, ok)
 	}
 }
 
-func TestSubmitMulti(t *testing.T) {
-	//construct a byte array with the UnsuccessSme
-	var bArray []byte
-	bArray = append(bArray, byte(0x00))       // TON
-	bArray = append(bArray, byte(0x00))       // NPI
-	bArray = append(bArray, []byte("123")...) // Address
-	bArray = append(bArray, byte(0x00))       // Error
-	bArray = append(bArray, byte(0x00))       // Error
-	bArray = append(bArray, byte(0x00))       // Error
-	bArray = append(bArray, byte(0x11))       // Error
-	bArray = append(bArray, byte(0x00))       // null terminator
-
+func TestSubmitNumberOfMessages(t *testing.T) {
+	received := make(chan uint8, 1)
 	s := smpptest.NewUnstartedServer()
 	s.Handler = func(c smpptest.Conn, p pdu.Body) {
-		switch p.Header().ID {
-		case pdu.SubmitMultiID:
-			r := pdu.NewSubmitMultiResp()
-			r.Header().Seq = p.Header().Seq
-			_ = r.Fields().Set(pdufield.MessageID, "foobar")
-			_ = r.Fields().Set(pdufield.NoUnsuccess, uint8(1))
-			_ = r.Fields().Set(pdufield.UnsuccessSme, bArray)
-			_ = c.Write(r)
-		default:
-			smpptest.EchoHandler(c, p)
+		if v, ok := p.TLVFields().NumberOfMessages(); ok {
+			received <- v
 		}
+		r := pdu.NewSubmitSMResp()
+		r.Header().Seq = p.Header().Seq
+		_ = r.Fields().Set(pdufield.MessageID, "foobar")
+		_ = c.Write(r)
 	}
 	s.Start()
 	defer s.Close()
 	tx := &Transmitter{
-		Addr:   s.Addr(),
-		User:   smpptest.DefaultUser,
-		Passwd: smpptest.DefaultPasswd,
+		Addr:             s.Addr(),
+		User:             smpptest.DefaultUser,
+		Passwd:           smpptest.DefaultPasswd,
+		StrictValidation: true,
 	}
 	defer tx.Close()
 	conn := <-tx.Bind()
@@ -396,42 +336,2353 @@ func TestSubmitMulti(t *testing.T) {
 	default:
 		t.Fatal(conn.Error())
 	}
-	sm, err := tx.Submit(&ShortMessage{
-		Src:      "root",
-		DstList:  []string{"123", "2233", "32322", "4234234"},
-		DLs:      []string{"DistributionList1"},
-		Text:     pdutext.Raw("Lorem ipsum"),
-		Validity: 10 * time.Minute,
-		Register: pdufield.NoDeliveryReceipt,
-	})
+	n := uint8(100)
+	sm := &ShortMessage{
+		Src:              "root",
+		Dst:              "foobar",
+		Text:             pdutext.Raw("Lorem ipsum"),
+		MWI:              &pdutext.MWI{Type: pdutext.MWIVoicemail, Active: true},
+		NumberOfMessages: &n,
+	}
+	if _, err := tx.Submit(sm); err != ErrNumberOfMessages {
+		t.Fatalf("unexpected error: want %v, have %v", ErrNumberOfMessages, err)
+	}
+	n = 3
+	if _, err := tx.Submit(sm); err != nil {
+		t.Fatal(err)
+	}
+	if v := <-received; v != n {
+		t.Fatalf("unexpected number_of_messages: want %d, have %d", n, v)
+	}
+}
+
+func TestRegisteredDelivery(t *testing.T) {
+	test := []struct {
+		register pdufield.DeliverySetting
+		want     uint8
+	}{
+		{pdufield.NoDeliveryReceipt, 0x00},
+		{pdufield.FinalDeliveryReceipt | pdufield.IntermediateNotification, 0x11},
+		{pdufield.FailureDeliveryReceipt | pdufield.DeliveryAcknowledgement, 0x06},
+		{pdufield.FinalDeliveryReceipt | pdufield.DeliveryAcknowledgement |
+			pdufield.UserAcknowledgement | pdufield.IntermediateNotification, 0x1d},
+	}
+	for _, tc := range test {
+		sm := &ShortMessage{
+			Src:      "root",
+			Dst:      "foobar",
+			Text:     pdutext.GSM7(strings.Repeat("a", 200)),
+			Register: tc.register,
+		}
+		pdus, err := sm.BuildPDUs()
+		if err != nil {
+			t.Fatal(err)
+		}
+		long, err := sm.BuildLongPDUs()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, p := range append(pdus, long...) {
+			if v, _ := p.Fields().Uint8(pdufield.RegisteredDelivery); v != tc.want {
+				t.Fatalf("unexpected registered_delivery: want %#02x, have %#02x", tc.want, v)
+			}
+		}
+		if have, want := tc.register.Receipt(), pdufield.DeliverySetting(tc.want&0x03); have != want {
+			t.Fatalf("unexpected receipt setting of %#02x: want %d, have %d", tc.want, want, have)
+		}
+	}
+}
+
+func TestBuildPDUsCallbackNum(t *testing.T) {
+	cb := pdutlv.CallbackNum{DigitMode: pdutlv.DigitModeASCII, TON: 0x01, NPI: 0x01, Digits: "15551234"}
+	sm := &ShortMessage{
+		Src:                "root",
+		Dst:                "foobar",
+		Text:               pdutext.Raw("Lorem ipsum"),
+		CallbackNum:        &cb,
+		CallbackNumPresInd: &pdutlv.CallbackNumPresInd{Presentation: pdutlv.PresentationAllowed, Screening: pdutlv.ScreeningPassed},
+	}
+	pdus, err := sm.Clone().BuildPDUs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tlv := pdus[0].TLVFields()
+	if v, ok := tlv.CallbackNum(); !ok || v != cb {
+		t.Fatalf("unexpected callback_num: want %+v, have %+v", cb, v)
+	}
+	if v, ok := tlv.CallbackNumPresInd(); !ok || v != *sm.CallbackNumPresInd {
+		t.Fatalf("unexpected callback_num_pres_ind: want %+v, have %+v", *sm.CallbackNumPresInd, v)
+	}
+	if _, ok := tlv.CallbackNumAtag(); ok {
+		t.Fatal("unexpected callback_num_atag")
+	}
+}
+
+func TestBuildPDUsMoreMessagesToSend(t *testing.T) {
+	more := true
+	sm := &ShortMessage{
+		Src:                "root",
+		Dst:                "foobar",
+		Text:               pdutext.Raw("Lorem ipsum"),
+		MoreMessagesToSend: &more,
+	}
+	pdus, err := sm.Clone().BuildPDUs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b bytes.Buffer
+	if err := pdus[0].SerializeTo(&b); err != nil {
+		t.Fatal(err)
+	}
+	p, err := pdu.Decode(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := p.TLVFields().MoreMessagesToSend(); !ok || !v {
+		t.Fatalf("unexpected more_messages_to_send: want true, have %t (%t)", v, ok)
+	}
+	sm.MoreMessagesToSend = nil
+	if pdus, err = sm.BuildPDUs(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := pdus[0].TLVFields().MoreMessagesToSend(); ok {
+		t.Fatal("unexpected more_messages_to_send")
+	}
+}
+
+func TestBuildPDUsPrivacyPayloadType(t *testing.T) {
+	privacy := pdutlv.PrivacySecret
+	payload := pdutlv.PayloadWCMP
+	sm := &ShortMessage{
+		Src:              "root",
+		Dst:              "foobar",
+		Text:             pdutext.Raw("Lorem ipsum"),
+		PrivacyIndicator: &privacy,
+		PayloadType:      &payload,
+	}
+	pdus, err := sm.Clone().BuildPDUs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b bytes.Buffer
+	if err := pdus[0].SerializeTo(&b); err != nil {
+		t.Fatal(err)
+	}
+	p, err := pdu.Decode(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tlv := p.TLVFields()
+	if v, ok := tlv.PrivacyIndicator(); !ok || v != privacy {
+		t.Fatalf("unexpected privacy_indicator: want %d, have %d (%t)", privacy, v, ok)
+	}
+	if v, ok := tlv.PayloadType(); !ok || v != payload {
+		t.Fatalf("unexpected payload_type: want %d, have %d (%t)", payload, v, ok)
+	}
+}
+
+func TestBuildPDUsIts(t *testing.T) {
+	reply := pdutlv.ItsReplyMenu
+	session := pdutlv.ItsSessionInfo{Session: 0x2a, Seq: 3, End: true}
+	sm := &ShortMessage{
+		Src:            "root",
+		Dst:            "foobar",
+		Text:           pdutext.Raw("Lorem ipsum"),
+		ItsReplyType:   &reply,
+		ItsSessionInfo: &session,
+	}
+	pdus, err := sm.Clone().BuildPDUs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b bytes.Buffer
+	if err := pdus[0].SerializeTo(&b); err != nil {
+		t.Fatal(err)
+	}
+	p, err := pdu.Decode(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tlv := p.TLVFields()
+	if v, ok := tlv.ItsReplyType(); !ok || v != reply {
+		t.Fatalf("unexpected its_reply_type: want %d, have %d (%t)", reply, v, ok)
+	}
+	if v, ok := tlv.Bytes(pdutlv.TagItsSessionInfo); !ok || !bytes.Equal(v, []byte{0x2a, 0x07}) {
+		t.Fatalf("unexpected its_session_info octets: want 2a07, have %x", v)
+	}
+	if v, ok := tlv.ItsSessionInfo(); !ok || v != session {
+		t.Fatalf("unexpected its_session_info: want %+v, have %+v", session, v)
+	}
+}
+
+func TestMessageMode(t *testing.T) {
+	sm := &ShortMessage{
+		Src:         "root",
+		Dst:         "foobar",
+		Text:        pdutext.GSM7(strings.Repeat("a", 200)),
+		ESMClass:    uint8(pdufield.ESMClassReplyPath),
+		MessageMode: pdufield.DatagramMode,
+	}
+	pdus, err := sm.BuildLongPDUs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pdus) != 2 {
+		t.Fatalf("unexpected number of parts: want 2, have %d", len(pdus))
+	}
+	want := pdufield.ESMClassUDHIndicator | pdufield.ESMClassReplyPath | pdufield.ESMClassFlags(pdufield.DatagramMode)
+	for i, p := range pdus {
+		esm, _ := p.Fields().Uint8(pdufield.ESMClass)
+		if pdufield.ESMClassFlags(esm) != want {
+			t.Fatalf("part %d: unexpected esm_class: want %#02x, have %#02x", i+1, want, esm)
+		}
+	}
+	sm.Text = pdutext.GSM7("Lorem ipsum")
+	sm.MessageMode = pdufield.ForwardMode
+	pdus, err = sm.BuildPDUs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = pdufield.ESMClassReplyPath | pdufield.ESMClassFlags(pdufield.ForwardMode)
+	if esm, _ := pdus[0].Fields().Uint8(pdufield.ESMClass); pdufield.ESMClassFlags(esm) != want {
+		t.Fatalf("unexpected esm_class: want %#02x, have %#02x", want, esm)
+	}
+}
+
+func TestSubmitNetworkType(t *testing.T) {
+	received := make(chan pdutlv.Map, 1)
+	s := smpptest.NewUnstartedServer()
+	s.Handler = func(c smpptest.Conn, p pdu.Body) {
+		received <- p.TLVFields()
+		r := pdu.NewSubmitSMResp()
+		r.Header().Seq = p.Header().Seq
+		_ = r.Fields().Set(pdufield.MessageID, "foobar")
+		_ = c.Write(r)
+	}
+	s.Start()
+	defer s.Close()
+	tx := &Transmitter{
+		Addr:   s.Addr(),
+		User:   smpptest.DefaultUser,
+		Passwd: smpptest.DefaultPasswd,
+	}
+	defer tx.Close()
+	conn := <-tx.Bind()
+	switch conn.Status() {
+	case Connected:
+	default:
+		t.Fatal(conn.Error())
+	}
+	dst := pdutlv.NetworkCDMA
+	bearer := pdutlv.BearerUSSD
+	_, err := tx.Submit(&ShortMessage{
+		Src:             "root",
+		Dst:             "foobar",
+		Text:            pdutext.Raw("Lorem ipsum"),
+		DestNetworkType: &dst,
+		DestBearerType:  &bearer,
+		TLVFields: pdutlv.Fields{
+			pdutlv.TagSourceNetworkType: pdutlv.NetworkGSM,
+			pdutlv.TagSourceBearerType:  pdutlv.BearerSMS,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tlv := <-received
+	if v, ok := tlv.DestNetworkType(); !ok || v != dst {
+		t.Fatalf("unexpected dest_network_type: want %d, have %d (%t)", dst, v, ok)
+	}
+	if v, ok := tlv.SourceNetworkType(); !ok || v != pdutlv.NetworkGSM {
+		t.Fatalf("unexpected source_network_type: want %d, have %d (%t)", pdutlv.NetworkGSM, v, ok)
+	}
+	if v, ok := tlv.DestBearerType(); !ok || v != bearer {
+		t.Fatalf("unexpected dest_bearer_type: want %d, have %d (%t)", bearer, v, ok)
+	}
+	if v, ok := tlv.SourceBearerType(); !ok || v != pdutlv.BearerSMS {
+		t.Fatalf("unexpected source_bearer_type: want %d, have %d (%t)", pdutlv.BearerSMS, v, ok)
+	}
+}
+
+func TestMsValidity(t *testing.T) {
+	v := pdutlv.MsValidity{Behavior: pdutlv.MsValidityRelative, Unit: pdutlv.MsValidityDays, Count: 2}
+	sm := &ShortMessage{
+		Src:        "root",
+		Dst:        "foobar",
+		Text:       pdutext.Raw("Lorem ipsum"),
+		MsValidity: &v,
+	}
+	p, err := pdu.DecodeBytes(serializeSubmit(t, sm.Clone()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have, ok := p.TLVFields().MsValidity(); !ok || have != v {
+		t.Fatalf("unexpected ms_validity: want %+v, have %+v (%t)", v, have, ok)
+	}
+}
+
+func TestNeedsConcatenation(t *testing.T) {
+	port := []pdufield.UDHIE{pdufield.NewIEApplicationPort16Bit(2948, 9200)}
+	test := []struct {
+		sm   *ShortMessage
+		want bool
+	}{
+		{&ShortMessage{Text: pdutext.GSM7(strings.Repeat("a", 160))}, false},
+		{&ShortMessage{Text: pdutext.GSM7(strings.Repeat("a", 161))}, true},
+		{&ShortMessage{Text: pdutext.GSM7(strings.Repeat("a", 158) + "€")}, false},
+		{&ShortMessage{Text: pdutext.GSM7(strings.Repeat("a", 159) + "€")}, true},
+		{&ShortMessage{Text: pdutext.GSM7(strings.Repeat("a", 160)), MessageClass: pdutext.Class0}, false},
+		{&ShortMessage{Text: pdutext.GSM7(strings.Repeat("a", 155)), ShiftTables: pdutext.ShiftTables{Single: pdutext.Turkish}}, false},
+		{&ShortMessage{Text: pdutext.GSM7(strings.Repeat("a", 156)), ShiftTables: pdutext.ShiftTables{Single: pdutext.Turkish}}, true},
+		{&ShortMessage{Text: pdutext.GSM7(strings.Repeat("a", 152)), UDH: port}, false},
+		{&ShortMessage{Text: pdutext.GSM7(strings.Repeat("a", 153)), UDH: port}, true},
+		{&ShortMessage{Text: pdutext.UCS2(strings.Repeat("é", 70))}, false},
+		{&ShortMessage{Text: pdutext.UCS2(strings.Repeat("é", 71))}, true},
+		{&ShortMessage{Text: pdutext.UCS2(strings.Repeat("é", 66)), UDH: port}, false},
+		{&ShortMessage{Text: pdutext.UCS2(strings.Repeat("é", 67)), UDH: port}, true},
+		{&ShortMessage{Text: pdutext.Latin1(strings.Repeat("é", 140))}, false},
+		{&ShortMessage{Text: pdutext.Latin1(strings.Repeat("é", 141))}, true},
+		{&ShortMessage{Text: pdutext.Latin1(strings.Repeat("é", 141)), ShiftTables: pdutext.ShiftTables{Single: pdutext.Turkish}}, true},
+		{&ShortMessage{}, false},
+	}
+	for i, tc := range test {
+		if have := tc.sm.NeedsConcatenation(); have != tc.want {
+			t.Fatalf("test %d: unexpected concatenation: want %t, have %t", i, tc.want, have)
+		}
+	}
+}
+
+func TestSubmitAuto(t *testing.T) {
+	submits := make(chan pdu.Body, 10)
+	s := smpptest.NewUnstartedServer()
+	s.Handlers = map[pdu.ID]smpptest.HandlerFunc{
+		pdu.SubmitSMID: func(c smpptest.Conn, p pdu.Body) {
+			submits <- p
+			r := pdu.NewSubmitSMResp()
+			r.Header().Seq = p.Header().Seq
+			_ = r.Fields().Set(pdufield.MessageID, "foobar")
+			_ = c.Write(r)
+		},
+		pdu.SubmitMultiID: func(c smpptest.Conn, p pdu.Body) {
+			submits <- p
+			r := pdu.NewSubmitMultiResp()
+			r.Header().Seq = p.Header().Seq
+			_ = r.Fields().Set(pdufield.MessageID, "foobar")
+			_ = r.Fields().Set(pdufield.NoUnsuccess, uint8(0))
+			_ = c.Write(r)
+		},
+	}
+	s.Start()
+	defer s.Close()
+	tx := &Transmitter{
+		Addr:   s.Addr(),
+		User:   smpptest.DefaultUser,
+		Passwd: smpptest.DefaultPasswd,
+	}
+	defer tx.Close()
+	conn := <-tx.Bind()
+	switch conn.Status() {
+	case Connected:
+	default:
+		t.Fatal(conn.Error())
+	}
+	port := []pdufield.UDHIE{pdufield.NewIEApplicationPort16Bit(2948, 9200)}
+	test := []struct {
+		name  string
+		sm    *ShortMessage
+		id    pdu.ID
+		parts int
+		udhi  bool
+	}{
+		{"short gsm7", &ShortMessage{Dst: "foobar", Text: pdutext.GSM7(strings.Repeat("a", 160))}, pdu.SubmitSMID, 1, false},
+		{"long gsm7", &ShortMessage{Dst: "foobar", Text: pdutext.GSM7(strings.Repeat("a", 161))}, pdu.SubmitSMID, 2, true},
+		{"short ucs2", &ShortMessage{Dst: "foobar", Text: pdutext.UCS2(strings.Repeat("é", 70))}, pdu.SubmitSMID, 1, false},
+		{"long ucs2", &ShortMessage{Dst: "foobar", Text: pdutext.UCS2(strings.Repeat("é", 140))}, pdu.SubmitSMID, 3, true},
+		{"short with udh", &ShortMessage{Dst: "foobar", Text: pdutext.GSM7("hello"), UDH: port}, pdu.SubmitSMID, 1, true},
+		{"short multi", &ShortMessage{DstList: []string{"foo", "bar"}, Text: pdutext.GSM7("hello")}, pdu.SubmitMultiID, 1, false},
+	}
+	for _, tc := range test {
+		tc.sm.Src = "root"
+		text := tc.sm.SourceText()
+		parts, err := tx.SubmitAuto(tc.sm)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if len(parts) != tc.parts {
+			t.Fatalf("%s: unexpected parts: want %d, have %d", tc.name, tc.parts, len(parts))
+		}
+		var joined string
+		for i := range parts {
+			if id := parts[i].RespID(); id != "foobar" {
+				t.Fatalf("%s: unexpected message id: %q", tc.name, id)
+			}
+			joined += parts[i].PartText()
+			p := <-submits
+			if p.Header().ID != tc.id {
+				t.Fatalf("%s: unexpected PDU: want %s, have %s", tc.name, tc.id, p.Header().ID)
+			}
+			esm, _ := p.Fields().Uint8(pdufield.ESMClass)
+			if udhi := esm&pdufield.ESMClassUDHIndicator != 0; udhi != tc.udhi {
+				t.Fatalf("%s: unexpected udhi: want %t, have %t", tc.name, tc.udhi, udhi)
+			}
+		}
+		if joined != text {
+			t.Fatalf("%s: unexpected text: want %q, have %q", tc.name, text, joined)
+		}
+	}
+}
+
+func TestEncodeFallback(t *testing.T) {
+	submits := make(chan pdu.Body, 10)
+	s := smpptest.NewUnstartedServer()
+	s.Handlers = map[pdu.ID]smpptest.HandlerFunc{
+		pdu.SubmitSMID: func(c smpptest.Conn, p pdu.Body) {
+			submits <- p
+			r := pdu.NewSubmitSMResp()
+			r.Header().Seq = p.Header().Seq
+			_ = r.Fields().Set(pdufield.MessageID, "foobar")
+			_ = c.Write(r)
+		},
+	}
+	s.Start()
+	defer s.Close()
+	tx := &Transmitter{
+		Addr:           s.Addr(),
+		User:           smpptest.DefaultUser,
+		Passwd:         smpptest.DefaultPasswd,
+		EncodeFallback: []pdutext.DataCoding{pdutext.UCS2Type},
+	}
+	defer tx.Close()
+	conn := <-tx.Bind()
+	switch conn.Status() {
+	case Connected:
+	default:
+		t.Fatal(conn.Error())
+	}
+	test := []struct {
+		text  string
+		dc    pdutext.DataCoding
+		parts int
+	}{
+		{"Hello [world] for 5€", pdutext.DefaultType, 1},
+		{"Hello world 😀", pdutext.UCS2Type, 1},
+		{strings.Repeat("a", 100) + " 😀", pdutext.UCS2Type, 2}, // 1 part in GSM7
+	}
+	for _, tc := range test {
+		sm := &ShortMessage{
+			Src:  "root",
+			Dst:  "foobar",
+			Text: pdutext.GSM7(tc.text),
+		}
+		parts, err := tx.SubmitAuto(sm)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := sm.Text.(pdutext.GSM7); !ok {
+			t.Fatalf("unexpected text codec of %q: want pdutext.GSM7, have %T", tc.text, sm.Text)
+		}
+		if len(parts) != tc.parts {
+			t.Fatalf("unexpected parts of %q: want %d, have %d", tc.text, tc.parts, len(parts))
+		}
+		var joined string
+		for i := range parts {
+			joined += parts[i].PartText()
+			p := <-submits
+			if dc, _ := p.Fields().Uint8(pdufield.DataCoding); pdutext.DataCoding(dc) != tc.dc {
+				t.Fatalf("unexpected data_coding of %q: want %#02x, have %#02x", tc.text, tc.dc, dc)
+			}
+		}
+		if joined != tc.text {
+			t.Fatalf("unexpected text: want %q, have %q", tc.text, joined)
+		}
+	}
+}
+
+func TestSubmitData(t *testing.T) {
+	payloads := make(chan string, 2)
+	s := smpptest.NewUnstartedServer()
+	s.Handlers = map[pdu.ID]smpptest.HandlerFunc{
+		pdu.DataSMID: func(c smpptest.Conn, p pdu.Body) {
+			b, _ := p.TLVFields().Bytes(pdutlv.TagMessagePayload)
+			payloads <- string(b)
+			r := pdu.NewDataSMResp()
+			r.Header().Seq = p.Header().Seq
+			_ = r.Fields().Set(pdufield.MessageID, "foobar")
+			if p.Fields()[pdufield.DestinationAddr].String() == "unreachable" {
+				r.Header().Status = pdu.StatusDeliveryFailure
+				_ = r.TLVFields().Set(pdutlv.TagDeliveryFailureReason, pdutlv.DeliveryTemporaryNetError)
+				_ = r.TLVFields().Set(pdutlv.TagNetworkErrorCode, pdutlv.NetworkErrorCode{Network: pdutlv.ErrorNetworkGSM, Code: 27})
+			}
+			_ = c.Write(r)
+		},
+	}
+	s.Start()
+	defer s.Close()
+	tx := &Transmitter{
+		Addr:   s.Addr(),
+		User:   smpptest.DefaultUser,
+		Passwd: smpptest.DefaultPasswd,
+	}
+	defer tx.Close()
+	conn := <-tx.Bind()
+	switch conn.Status() {
+	case Connected:
+	default:
+		t.Fatal(conn.Error())
+	}
+	sm := &ShortMessage{
+		Src:  "root",
+		Dst:  "foobar",
+		Text: pdutext.Raw("Lorem ipsum"),
+	}
+	dr, err := tx.SubmitData(sm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dr.MsgID != "foobar" || sm.RespID() != "foobar" {
+		t.Fatalf("unexpected message id: %q, %q", dr.MsgID, sm.RespID())
+	}
+	if dr.DeliveryFailureReason != nil || dr.NetworkErrorCode != nil {
+		t.Fatalf("unexpected failure TLVs: %+v", dr)
+	}
+	if have := <-payloads; have != "Lorem ipsum" {
+		t.Fatalf("unexpected message_payload: %q", have)
+	}
+	sm.Dst = "unreachable"
+	dr, err = tx.SubmitData(sm)
+	if !errors.Is(err, pdu.StatusDeliveryFailure) {
+		t.Fatalf("unexpected error: want %v, have %v", pdu.StatusDeliveryFailure, err)
+	}
+	<-payloads
+	if dr == nil || dr.MsgID != "foobar" {
+		t.Fatalf("unexpected response: %+v", dr)
+	}
+	if v := dr.DeliveryFailureReason; v == nil || *v != pdutlv.DeliveryTemporaryNetError {
+		t.Fatalf("unexpected delivery_failure_reason: %v", v)
+	}
+	want := pdutlv.NetworkErrorCode{Network: pdutlv.ErrorNetworkGSM, Code: 27}
+	if v := dr.NetworkErrorCode; v == nil || *v != want {
+		t.Fatalf("unexpected network_error_code: want %+v, have %v", want, v)
+	}
+}
+
+func TestQosTimeToLive(t *testing.T) {
+	for _, ttl := range []time.Duration{time.Millisecond, 90 * time.Minute, MaxQosTimeToLive} {
+		sm := &ShortMessage{
+			Src:           "root",
+			Dst:           "foobar",
+			Text:          pdutext.Raw("Lorem ipsum"),
+			QosTimeToLive: ttl,
+		}
+		p, err := pdu.DecodeBytes(serializeSubmit(t, sm.Clone()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if have, ok := p.TLVFields().QosTimeToLive(); !ok || have != ttl {
+			t.Fatalf("unexpected qos_time_to_live: want %s, have %s (%t)", ttl, have, ok)
+		}
+	}
+	for _, ttl := range []time.Duration{-time.Second, MaxQosTimeToLive + time.Millisecond} {
+		sm := &ShortMessage{Src: "root", Dst: "foobar", Text: pdutext.Raw("Lorem ipsum"), QosTimeToLive: ttl}
+		if _, err := sm.BuildPDUs(); err != ErrQosTimeToLive {
+			t.Fatalf("unexpected error for %s: want %v, have %v", ttl, ErrQosTimeToLive, err)
+		}
+	}
+}
+
+func TestTransmitterOnUnexpected(t *testing.T) {
+	resps := make(chan pdu.Body, 1)
+	s := smpptest.NewUnstartedServer()
+	s.Handlers = map[pdu.ID]smpptest.HandlerFunc{
+		pdu.DeliverSMRespID: func(c smpptest.Conn, p pdu.Body) { resps <- p },
+	}
+	s.Start()
+	defer s.Close()
+	unexpected := make(chan pdu.Body, 1)
+	tx := &Transmitter{
+		Addr:         s.Addr(),
+		User:         smpptest.DefaultUser,
+		Passwd:       smpptest.DefaultPasswd,
+		OnUnexpected: func(p pdu.Body) { unexpected <- p },
+	}
+	defer tx.Close()
+	conn := <-tx.Bind()
+	switch conn.Status() {
+	case Connected:
+	default:
+		t.Fatal(conn.Error())
+	}
+	p := pdu.NewDeliverSM()
+	_ = p.Fields().Set(pdufield.ShortMessage, pdutext.Raw("Lorem ipsum"))
+	s.BroadcastMessage(p)
+	select {
+	case have := <-unexpected:
+		if have.Header().ID != pdu.DeliverSMID || have.Header().Seq != p.Header().Seq {
+			t.Fatalf("unexpected PDU: want %s seq %d, have %s seq %d",
+				pdu.DeliverSMID, p.Header().Seq, have.Header().ID, have.Header().Seq)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for OnUnexpected")
+	}
+	select {
+	case r := <-resps:
+		if r.Header().Seq != p.Header().Seq {
+			t.Fatalf("unexpected deliver_sm_resp seq: want %d, have %d", p.Header().Seq, r.Header().Seq)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timeout waiting for deliver_sm_resp")
+	}
+}
+
+func TestSeqStart(t *testing.T) {
+	seqs := make(chan uint32, 3)
+	s := smpptest.NewUnstartedServer()
+	s.Handler = func(c smpptest.Conn, p pdu.Body) {
+		seqs <- p.Header().Seq
+		r := pdu.NewSubmitSMResp()
+		r.Header().Seq = p.Header().Seq
+		_ = r.Fields().Set(pdufield.MessageID, "foobar")
+		_ = c.Write(r)
+	}
+	s.Start()
+	defer s.Close()
+	tx := &Transmitter{
+		Addr:     s.Addr(),
+		User:     smpptest.DefaultUser,
+		Passwd:   smpptest.DefaultPasswd,
+		SeqStart: pdu.MaxSeq - 1,
+	}
+	defer tx.Close()
+	conn := <-tx.Bind()
+	switch conn.Status() {
+	case Connected:
+	default:
+		t.Fatal(conn.Error())
+	}
+	for _, want := range []uint32{pdu.MaxSeq - 1, pdu.MaxSeq, 1} {
+		_, err := tx.Submit(&ShortMessage{
+			Src:  "root",
+			Dst:  "foobar",
+			Text: pdutext.Raw("Lorem ipsum"),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if have := <-seqs; have != want {
+			t.Fatalf("unexpected seq: want %d, have %d", want, have)
+		}
+	}
+}
+
+func TestSeqWrapSkipsInflight(t *testing.T) {
+	seqs := make(chan uint32, 2)
+	s := smpptest.NewUnstartedServer()
+	s.Handlers = map[pdu.ID]smpptest.HandlerFunc{
+		pdu.SubmitSMID: func(c smpptest.Conn, p pdu.Body) {
+			seqs <- p.Header().Seq
+			r := pdu.NewSubmitSMResp()
+			r.Header().Seq = p.Header().Seq
+			_ = r.Fields().Set(pdufield.MessageID, "foobar")
+			_ = c.Write(r)
+		},
+	}
+	s.Start()
+	defer s.Close()
+	c := &Transmitter{
+		Addr:       s.Addr(),
+		User:       smpptest.DefaultUser,
+		Passwd:     smpptest.DefaultPasswd,
+		SeqStart:   pdu.MaxSeq,
+		WindowSize: 10,
+	}
+	defer c.Close()
+	conn := <-c.Bind()
+	switch conn.Status() {
+	case Connected:
+	default:
+		t.Fatal(conn.Error())
+	}
+	// Requests still pending a response with the numbers around the
+	// wrap, as after a full cycle of the sequence.
+	c.tx.Lock()
+	for _, seq := range []uint32{pdu.MaxSeq, 1, 3} {
+		h := pdu.Header{ID: pdu.SubmitSMID, Seq: seq}
+		c.tx.inflight[h.Key()] = make(chan *tx, 1)
+	}
+	c.tx.Unlock()
+	for _, want := range []uint32{2, 4} {
+		_, err := c.Submit(&ShortMessage{
+			Src:  "root",
+			Dst:  "foobar",
+			Text: pdutext.Raw("Lorem ipsum"),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if have := <-seqs; have != want {
+			t.Fatalf("unexpected seq: want %d, have %d", want, have)
+		}
+	}
+}
+
+func TestTransmitterUse(t *testing.T) {
+	received := make(chan pdu.Body, 1)
+	s := smpptest.NewUnstartedServer()
+	s.Handlers = map[pdu.ID]smpptest.HandlerFunc{
+		pdu.SubmitSMID: func(c smpptest.Conn, p pdu.Body) {
+			received <- p
+			r := pdu.NewSubmitSMResp()
+			r.Header().Seq = p.Header().Seq
+			_ = r.Fields().Set(pdufield.MessageID, "foobar")
+			_ = c.Write(r)
+		},
+	}
+	s.Start()
+	defer s.Close()
+	tx := &Transmitter{
+		Addr:   s.Addr(),
+		User:   smpptest.DefaultUser,
+		Passwd: smpptest.DefaultPasswd,
+	}
+	defer tx.Close()
+	conn := <-tx.Bind()
+	switch conn.Status() {
+	case Connected:
+	default:
+		t.Fatal(conn.Error())
+	}
+	errBlocked := errors.New("blocked destination")
+	var order []string
+	tx.Use(
+		func(p pdu.Body) (pdu.Body, error) {
+			order = append(order, "tlv")
+			_ = p.TLVFields().Set(pdutlv.TagUserMessageReference, []byte{0x00, 0x2a})
+			return p, nil
+		},
+		func(p pdu.Body) (pdu.Body, error) {
+			order = append(order, "reject")
+			if dst, _ := p.Fields().String(pdufield.DestinationAddr); dst == "blocked" {
+				return nil, errBlocked
+			}
+			return p, nil
+		},
+	)
+	sm := &ShortMessage{
+		Src:  "root",
+		Dst:  "foobar",
+		Text: pdutext.Raw("Lorem ipsum"),
+	}
+	if _, err := tx.Submit(sm); err != nil {
+		t.Fatal(err)
+	}
+	p := <-received
+	if v, ok := p.TLVFields().Bytes(pdutlv.TagUserMessageReference); !ok || !bytes.Equal(v, []byte{0x00, 0x2a}) {
+		t.Fatalf("unexpected user_message_reference: %x (%t)", v, ok)
+	}
+	if want := []string{"tlv", "reject"}; !reflect.DeepEqual(order, want) {
+		t.Fatalf("unexpected middleware order: want %v, have %v", want, order)
+	}
+	sm.Dst = "blocked"
+	if _, err := tx.Submit(sm); err != errBlocked {
+		t.Fatalf("unexpected error: want %v, have %v", errBlocked, err)
+	}
+	select {
+	case p := <-received:
+		t.Fatalf("unexpected PDU sent: %s", p.Header().ID)
+	case <-time.After(50 * time.Millisecond):
+	}
+	if n := tx.Outstanding(); n != 0 {
+		t.Fatalf("unexpected requests in flight: %d", n)
+	}
+}
+
+func TestTransmitterUseNilPDU(t *testing.T) {
+	s := smpptest.NewServer()
+	defer s.Close()
+	tx := &Transmitter{
+		Addr:   s.Addr(),
+		User:   smpptest.DefaultUser,
+		Passwd: smpptest.DefaultPasswd,
+	}
+	defer tx.Close()
+	conn := <-tx.Bind()
+	switch conn.Status() {
+	case Connected:
+	default:
+		t.Fatal(conn.Error())
+	}
+	tx.Use(func(p pdu.Body) (pdu.Body, error) {
+		return nil, nil
+	})
+	sm := &ShortMessage{
+		Src:  "root",
+		Dst:  "foobar",
+		Text: pdutext.Raw("Lorem ipsum"),
+	}
+	if _, err := tx.Submit(sm); err != ErrNilPDU {
+		t.Fatalf("unexpected error: want %v, have %v", ErrNilPDU, err)
+	}
+	if n := tx.Outstanding(); n != 0 {
+		t.Fatalf("unexpected requests in flight: %d", n)
+	}
+}
+
+func TestTransmitterUsePartRetry(t *testing.T) {
+	var rejects atomic.Int32 // rejections of part 2 left
+	s := smpptest.NewUnstartedServer()
+	s.Handlers = map[pdu.ID]smpptest.HandlerFunc{
+		pdu.SubmitSMID: func(c smpptest.Conn, p pdu.Body) {
+			r := pdu.NewSubmitSMResp()
+			r.Header().Seq = p.Header().Seq
+			if _, _, _, part := p.UDH().IsConcatenated(); part == 2 && rejects.Add(-1) >= 0 {
+				r.Header().Status = pdu.StatusThrottled
+			}
+			_ = r.Fields().Set(pdufield.MessageID, "foobar")
+			_ = c.Write(r)
+		},
+	}
+	s.Start()
+	defer s.Close()
+	tx := &Transmitter{
+		Addr:      s.Addr(),
+		User:      smpptest.DefaultUser,
+		Passwd:    smpptest.DefaultPasswd,
+		PartRetry: &RetryPolicy{Backoff: time.Millisecond},
+	}
+	defer tx.Close()
+	conn := <-tx.Bind()
+	switch conn.Status() {
+	case Connected:
+	default:
+		t.Fatal(conn.Error())
+	}
+	var calls atomic.Int32
+	tx.Use(func(p pdu.Body) (pdu.Body, error) {
+		calls.Add(1)
+		return p, nil
+	})
+	sm := &ShortMessage{
+		Src:  "root",
+		Dst:  "foobar",
+		Text: pdutext.Raw(bytes.Repeat([]byte{0x42}, 300)),
+	}
+	rejects.Store(2)
+	parts, err := tx.SubmitLongMsg(sm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parts) != 3 {
+		t.Fatalf("unexpected number of parts: want 3, have %d", len(parts))
+	}
+	if n := calls.Load(); n != 3 {
+		t.Fatalf("unexpected middleware calls: want 3, have %d", n)
+	}
+}
+
+func TestSubmitStatusText(t *testing.T) {
+	s := smpptest.NewUnstartedServer()
+	s.Handler = func(c smpptest.Conn, p pdu.Body) {
+		r := pdu.NewSubmitSMResp()
+		r.Header().Seq = p.Header().Seq
+		r.Header().Status = pdu.StatusThrottled
+		_ = r.TLVFields().Set(pdutlv.TagAdditionalStatusInfoText, pdutlv.CString("too many messages"))
+		_ = c.Write(r)
+	}
+	s.Start()
+	defer s.Close()
+	tx := &Transmitter{
+		Addr:   s.Addr(),
+		User:   smpptest.DefaultUser,
+		Passwd: smpptest.DefaultPasswd,
+	}
+	defer tx.Close()
+	conn := <-tx.Bind()
+	switch conn.Status() {
+	case Connected:
+	default:
+		t.Fatal(conn.Error())
+	}
+	_, err := tx.Submit(&ShortMessage{
+		Src:  "root",
+		Dst:  "foobar",
+		Text: pdutext.Raw("Lorem ipsum"),
+	})
+	var se *StatusError
+	if !errors.As(err, &se) {
+		t.Fatalf("unexpected error: want *StatusError, have %v", err)
+	}
+	if se.Status != pdu.StatusThrottled || se.Text != "too many messages" {
+		t.Fatalf("unexpected status error: %+v", se)
+	}
+	if !errors.Is(err, pdu.StatusThrottled) {
+		t.Fatalf("unexpected error: want %v, have %v", pdu.StatusThrottled, err)
+	}
+}
+
+func TestCongestionState(t *testing.T) {
+	states := []int{-1, 10, 50, 95, -1, 0} // -1 for no congestion_state
+	var n int
+	s := smpptest.NewUnstartedServer()
+	s.Handlers = map[pdu.ID]smpptest.HandlerFunc{pdu.SubmitSMID: func(c smpptest.Conn, p pdu.Body) {
+		r := pdu.NewSubmitSMResp()
+		r.Header().Seq = p.Header().Seq
+		if st := states[n]; st >= 0 {
+			_ = r.TLVFields().Set(pdutlv.TagCongestionState, uint8(st))
+		}
+		n++
+		_ = c.Write(r)
+	}}
+	s.Start()
+	defer s.Close()
+	var reported []uint8
+	tx := &Transmitter{
+		Addr:         s.Addr(),
+		User:         smpptest.DefaultUser,
+		Passwd:       smpptest.DefaultPasswd,
+		OnCongestion: func(state uint8) { reported = append(reported, state) },
+	}
+	defer tx.Close()
+	conn := <-tx.Bind()
+	switch conn.Status() {
+	case Connected:
+	default:
+		t.Fatal(conn.Error())
+	}
+	if _, ok := tx.CongestionState(); ok {
+		t.Fatal("unexpected congestion state before any response")
+	}
+	want := -1
+	for _, st := range states {
+		_, err := tx.Submit(&ShortMessage{
+			Src:  "root",
+			Dst:  "foobar",
+			Text: pdutext.Raw("Lorem ipsum"),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if st >= 0 {
+			want = st
+		}
+		have, ok := tx.CongestionState()
+		if want < 0 && ok || want >= 0 && (!ok || int(have) != want) {
+			t.Fatalf("unexpected congestion state: want %d, have %d (%t)", want, have, ok)
+		}
+	}
+	if want := []uint8{10, 50, 95, 0}; !bytes.Equal(reported, want) {
+		t.Fatalf("unexpected reported states: want %v, have %v", want, reported)
+	}
+}
+
+func TestSubmitPackGSM7(t *testing.T) {
+	text := "Hello {world}" // 13 characters, 15 septets
+	test := []struct {
+		pack bool
+		want []byte // sm_length and short_message
+	}{
+		{false, append([]byte{15}, pdutext.GSM7(text).Encode()...)},
+		{true, append([]byte{14}, pdutext.GSM7Packed(text).Encode()...)}, // 15 * 7 / 8 rounded up
+	}
+	s := smpptest.NewUnstartedServer()
+	s.Handlers = map[pdu.ID]smpptest.HandlerFunc{pdu.SubmitSMID: func(c smpptest.Conn, p pdu.Body) {
+		r := pdu.NewSubmitSMResp()
+		r.Header().Seq = p.Header().Seq
+		_ = c.Write(r)
+	}}
+	s.Start()
+	defer s.Close()
+	for _, tc := range test {
+		var wire []byte
+		tx := &Transmitter{
+			Addr:     s.Addr(),
+			User:     smpptest.DefaultUser,
+			Passwd:   smpptest.DefaultPasswd,
+			PackGSM7: tc.pack,
+			OnWrite: func(p pdu.Body, b []byte) {
+				if p.Header().ID == pdu.SubmitSMID {
+					wire = b
+				}
+			},
+		}
+		conn := <-tx.Bind()
+		switch conn.Status() {
+		case Connected:
+		default:
+			t.Fatal(conn.Error())
+		}
+		sm := &ShortMessage{
+			Src:  "root",
+			Dst:  "foobar",
+			Text: pdutext.GSM7(text),
+		}
+		// Submitting sm again sends the same text, sm being unchanged.
+		for range 2 {
+			if _, err := tx.Submit(sm); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.HasSuffix(wire, tc.want) {
+				t.Fatalf("unexpected submit_sm (pack %t): want suffix %x, have %x", tc.pack, tc.want, wire)
+			}
+			if _, ok := sm.Text.(pdutext.GSM7); !ok {
+				t.Fatalf("unexpected text codec (pack %t): want pdutext.GSM7, have %T", tc.pack, sm.Text)
+			}
+		}
+		tx.Close()
+		if dc := wire[len(wire)-len(tc.want)-4]; dc != 0x00 {
+			t.Fatalf("unexpected data_coding (pack %t): %#02x", tc.pack, dc)
+		}
+		if sm.SourceText() != text {
+			t.Fatalf("unexpected source text: want %q, have %q", text, sm.SourceText())
+		}
+	}
+}
+
+func TestMaxInFlight(t *testing.T) {
+	var cur, peak atomic.Int32
+	var mu sync.Mutex // serializes writes
+	s := smpptest.NewUnstartedServer()
+	s.Handler = func(c smpptest.Conn, p pdu.Body) {
+		n := cur.Add(1)
+		for {
+			v := peak.Load()
+			if n <= v || peak.CompareAndSwap(v, n) {
+				break
+			}
+		}
+		go func() {
+			time.Sleep(20 * time.Millisecond)
+			cur.Add(-1)
+			r := pdu.NewSubmitSMResp()
+			r.Header().Seq = p.Header().Seq
+			_ = r.Fields().Set(pdufield.MessageID, "foobar")
+			mu.Lock()
+			_ = c.Write(r)
+			mu.Unlock()
+		}()
+	}
+	s.Start()
+	defer s.Close()
+	tx := &Transmitter{
+		Addr:        s.Addr(),
+		User:        smpptest.DefaultUser,
+		Passwd:      smpptest.DefaultPasswd,
+		RespTimeout: 5 * time.Second,
+		MaxInFlight: 3,
+	}
+	defer tx.Close()
+	conn := <-tx.Bind()
+	switch conn.Status() {
+	case Connected:
+	default:
+		t.Fatal(conn.Error())
+	}
+	const callers = 20
+	errs := make(chan error, callers)
+	for range callers {
+		go func() {
+			_, err := tx.Submit(&ShortMessage{
+				Src:  "root",
+				Dst:  "foobar",
+				Text: pdutext.Raw("Lorem ipsum"),
+			})
+			errs <- err
+		}()
+	}
+	for range callers {
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := peak.Load(); n > 3 {
+		t.Fatalf("unexpected in-flight submits: want at most 3, have %d", n)
+	}
+
+	tx.FailOnMaxInFlight = true
+	tx.cl.FailOnMaxInFlight = true
+	for range 3 {
+		tx.cl.sem <- struct{}{}
+	}
+	_, err := tx.Submit(&ShortMessage{Src: "root", Dst: "foobar", Text: pdutext.Raw("Lorem ipsum")})
+	if err != ErrMaxInFlight {
+		t.Fatalf("unexpected error: want %v, have %v", ErrMaxInFlight, err)
+	}
+}
+
+func TestShortMessageWindowSize(t *testing.T) {
+	s := smpptest.NewUnstartedServer()
+	s.Handler = func(c smpptest.Conn, p pdu.Body) {
+		time.Sleep(200 * time.Millisecond)
+		r := pdu.NewSubmitSMResp()
+		r.Header().Seq = p.Header().Seq
+		_ = r.Fields().Set(pdufield.MessageID, "foobar")
+		_ = c.Write(r)
+	}
+	s.Start()
+	defer s.Close()
+	tx := &Transmitter{
+		Addr:        s.Addr(),
+		User:        smpptest.DefaultUser,
+		Passwd:      smpptest.DefaultPasswd,
+		WindowSize:  2,
+		RespTimeout: time.Second,
+	}
+	defer tx.Close()
+	conn := <-tx.Bind()
+	switch conn.Status() {
+	case Connected:
+	default:
+		t.Fatal(conn.Error())
+	}
+	msgc := make(chan *ShortMessage, 3)
+	defer close(msgc)
+	errc := make(chan error, 3)
+	for i := 0; i < 3; i++ {
+		go func(msgc chan *ShortMessage, errc chan error) {
+			m := <-msgc
+			if m == nil {
+				return
+			}
+			_, err := tx.Submit(m)
+			errc <- err
+		}(msgc, errc)
+		msgc <- &ShortMessage{
+			Src:      "root",
+			Dst:      "foobar",
+			Text:     pdutext.Raw("Lorem ipsum"),
+			Validity: 10 * time.Minute,
+			Register: pdufield.NoDeliveryReceipt,
+		}
+	}
+	nerr := 0
+	for i := 0; i < 3; i++ {
+		if <-errc == ErrMaxWindowSize {
+			nerr++
+		}
+	}
+	if nerr != 1 {
+		t.Fatalf("unexpected # of errors. want 1, have %d", nerr)
+	}
+}
+
+func TestLongMessage(t *testing.T) {
+	s := smpptest.NewUnstartedServer()
+	count := 0
+	s.Handler = func(c smpptest.Conn, p pdu.Body) {
+		switch p.Header().ID {
+		case pdu.SubmitSMID:
+			r := pdu.NewSubmitSMResp()
+			r.Header().Seq = p.Header().Seq
+			_ = r.Fields().Set(pdufield.MessageID, fmt.Sprintf("foobar%d", count))
+			count++
+			_ = c.Write(r)
+		default:
+			smpptest.EchoHandler(c, p)
+		}
+	}
+	s.Start()
+	defer s.Close()
+	tx := &Transmitter{
+		Addr:   s.Addr(),
+		User:   smpptest.DefaultUser,
+		Passwd: smpptest.DefaultPasswd,
+	}
+	defer tx.Close()
+	conn := <-tx.Bind()
+	switch conn.Status() {
+	case Connected:
+	default:
+		t.Fatal(conn.Error())
+	}
+	parts, err := tx.SubmitLongMsg(&ShortMessage{
+		Src:      "root",
+		Dst:      "foobar",
+		Text:     pdutext.Raw("Lorem ipsum dolor sit amet, consectetur adipiscing elit. Nam consequat nisl enim, vel finibus neque aliquet sit amet. Interdum et malesuada fames ac ante ipsum primis in faucibus."),
+		Validity: 10 * time.Minute,
+		Register: pdufield.NoDeliveryReceipt,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parts) != 2 {
+		t.Fatalf("expected %d responses, but received %d", 2, len(parts))
+	}
+	for index := range parts {
+		msgid := parts[index].RespID()
+		if msgid == "" {
+			t.Fatalf("pdu does not contain msgid: %#v", parts[index].Resp())
+		}
+		if msgid != fmt.Sprintf("foobar%d", index) {
+			t.Fatalf("unexpected msgid: want foobar%d, have %q", index, msgid)
+		}
+	}
+}
+
+func TestLongMessagePartText(t *testing.T) {
+	s := smpptest.NewUnstartedServer()
+	s.Handler = func(c smpptest.Conn, p pdu.Body) {
+		r := pdu.NewSubmitSMResp()
+		r.Header().Seq = p.Header().Seq
+		_ = r.Fields().Set(pdufield.MessageID, "foobar")
+		_ = c.Write(r)
+	}
+	s.Start()
+	defer s.Close()
+	tx := &Transmitter{
+		Addr:   s.Addr(),
+		User:   smpptest.DefaultUser,
+		Passwd: smpptest.DefaultPasswd,
+	}
+	defer tx.Close()
+	conn := <-tx.Bind()
+	switch conn.Status() {
+	case Connected:
+	default:
+		t.Fatal(conn.Error())
+	}
+	for _, text := range []string{
+		strings.Repeat("Lorem ipsum €uro ", 12),
+		strings.Repeat("Привет мир ", 12),
+	} {
+		codec := pdutext.Codec(pdutext.GSM7(text))
+		if strings.HasPrefix(text, "П") {
+			codec = pdutext.UCS2(text)
+		}
+		sm := &ShortMessage{Src: "root", Dst: "foobar", Text: codec}
+		parts, err := tx.SubmitLongMsg(sm)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(parts) < 2 {
+			t.Fatalf("unexpected parts: want at least 2, have %d", len(parts))
+		}
+		var joined string
+		for i := range parts {
+			if v := parts[i].SourceText(); v != text {
+				t.Fatalf("unexpected source text: want %q, have %q", text, v)
+			}
+			joined += parts[i].PartText()
+		}
+		if joined != text {
+			t.Fatalf("unexpected part texts: want %q, have %q", text, joined)
+		}
+		if v := sm.PartText(); v != text {
+			t.Fatalf("unexpected text: want %q, have %q", text, v)
+		}
+	}
+}
+
+type countingLimiter struct{ n atomic.Int32 }
+
+func (l *countingLimiter) Wait(ctx context.Context) error {
+	l.n.Add(1)
+	return nil
+}
+
+func TestLongMessageRateLimiter(t *testing.T) {
+	s := smpptest.NewUnstartedServer()
+	s.Handler = func(c smpptest.Conn, p pdu.Body) {
+		switch p.Header().ID {
+		case pdu.SubmitSMID:
+			r := pdu.NewSubmitSMResp()
+			r.Header().Seq = p.Header().Seq
+			_ = r.Fields().Set(pdufield.MessageID, "foobar")
+			_ = c.Write(r)
+		default:
+			smpptest.EchoHandler(c, p)
+		}
+	}
+	s.Start()
+	defer s.Close()
+	lm := &countingLimiter{}
+	tx := &Transmitter{
+		Addr:        s.Addr(),
+		User:        smpptest.DefaultUser,
+		Passwd:      smpptest.DefaultPasswd,
+		RateLimiter: lm,
+	}
+	defer tx.Close()
+	conn := <-tx.Bind()
+	switch conn.Status() {
+	case Connected:
+	default:
+		t.Fatal(conn.Error())
+	}
+	sm := &ShortMessage{
+		Src:      "root",
+		Dst:      "foobar",
+		Text:     pdutext.Raw(strings.Repeat("x", 300)),
+		Register: pdufield.NoDeliveryReceipt,
+	}
+	parts, err := tx.SubmitLongMsg(sm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parts) != 3 {
+		t.Fatalf("unexpected parts: want 3, have %d", len(parts))
+	}
+	if n := lm.n.Load(); n != 3 {
+		t.Fatalf("unexpected limiter events: want 3, have %d", n)
+	}
+	lm.n.Store(0)
+	tx.RateLimitMessages = true
+	parts, err = tx.SubmitLongMsg(sm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parts) != 3 {
+		t.Fatalf("unexpected parts: want 3, have %d", len(parts))
+	}
+	if n := lm.n.Load(); n != 1 {
+		t.Fatalf("unexpected limiter events with RateLimitMessages: want 1, have %d", n)
+	}
+}
+
+func TestLongMessageEncode(t *testing.T) {
+	sm := &ShortMessage{
+		Src:      "root",
+		Dst:      "foobar",
+		Text:     pdutext.GSM7("Lorem ipsum dolor sit amet, consectetur adipiscing elit. Nam consequat nisl enim, vel finibus neque aliquet sit amet. Interdum et malesuada fames ac ante ipsum primis in faucibus."),
+		Validity: 10 * time.Minute,
+		Register: pdufield.NoDeliveryReceipt,
+	}
+
+	ref := uint16(rand.IntN(0xFFFF))
+	parts := pdutext.Split(sm.Text.Encode(), sm.Text, ref)
+	countParts := len(parts)
+	for i, part := range parts {
+		udh := pdufield.NewUDHConcatenatedShortMessage(ref, countParts, i+1)
+		if !bytes.Equal(part.UDH, append([]byte{byte(udh.Len())}, udh.Bytes()...)) {
+			t.Fatalf("part %d: unexpected UDH: want %x, have %x", i+1, udh.Bytes(), part.UDH)
+		}
+		p := pdu.NewSubmitSM(sm.TLVFields)
+		f := p.Fields()
+		_ = f.Set(pdufield.SourceAddr, sm.Src)
+		_ = f.Set(pdufield.DestinationAddr, sm.Dst)
+		_ = f.Set(pdufield.ShortMessage, pdutext.Raw(part.Text))
+		_ = f.Set(pdufield.RegisteredDelivery, uint8(sm.Register))
+		if sm.Validity != 0 {
+			_ = f.Set(pdufield.ValidityPeriod, convertValidity(sm.Validity))
+		}
+		_ = f.Set(pdufield.ServiceType, sm.ServiceType)
+		_ = f.Set(pdufield.SourceAddrTON, sm.SourceAddrTON)
+		_ = f.Set(pdufield.SourceAddrNPI, sm.SourceAddrNPI)
+		_ = f.Set(pdufield.DestAddrTON, sm.DestAddrTON)
+		_ = f.Set(pdufield.DestAddrNPI, sm.DestAddrNPI)
+		_ = f.Set(pdufield.ESMClass, pdufield.ESMClassUDHIndicator)
+		_ = f.Set(pdufield.ProtocolID, sm.ProtocolID)
+		_ = f.Set(pdufield.PriorityFlag, sm.PriorityFlag)
+		_ = f.Set(pdufield.ScheduleDeliveryTime, sm.ScheduleDeliveryTime)
+		_ = f.Set(pdufield.ReplaceIfPresentFlag, sm.ReplaceIfPresentFlag)
+		_ = f.Set(pdufield.SMDefaultMsgID, sm.SMDefaultMsgID)
+		_ = f.Set(pdufield.DataCoding, uint8(sm.Text.Type()))
+		_ = f.Set(pdufield.UDHLength, uint8(udh.Len()))
+		_ = f.Set(pdufield.GSMUserData, &udh)
+		_ = f.Set(pdufield.SMLength, uint8(f[pdufield.ShortMessage].Len()+udh.Len()+1)) // +1 for UDHLength octet
+		wire := bytes.NewBuffer(nil)
+		err := p.SerializeTo(wire)
+		if err != nil {
+			t.Fatalf("error marshalling pdu: %v", err)
+		}
+		encoded, err := pdu.Decode(wire)
+		if err != nil {
+			t.Fatalf("error unmarshalling pdu: %v", err)
+		}
+		encodedSM := encoded.Fields()[pdufield.ShortMessage]
+		if encodedSM.String() != f[pdufield.ShortMessage].String() {
+			t.Fatalf("part %d: expected short message %q, got %q", i+1, f[pdufield.ShortMessage].String(), encodedSM.String())
+		}
+		// encoded.UDH()
+		udhField := encoded.UDH()
+		if udhField == nil {
+			t.Fatalf("part %d: missing UDH field", i+1)
+		}
+		concatenated, expectedRef, total, part := udhField.IsConcatenated()
+		if !concatenated {
+			t.Fatalf("part %d: UDH IsConcatenated = %v, want %v", i+1, concatenated, true)
+		}
+		if total != countParts {
+			t.Fatalf("part %d: UDH IsConcatenated total = %d, want %d", i+1, total, countParts)
+		}
+		if part != i+1 {
+			t.Fatalf("part %d: UDH IsConcatenated part = %d, want %d", i+1, part, i+1)
+		}
+		if expectedRef != int(ref) {
+			t.Fatalf("part %d: UDH IsConcatenated ref = %d, want %d", i+1, expectedRef, ref)
+		}
+	}
+}
+
+func TestLongMessageAsUCS2(t *testing.T) {
+	s := smpptest.NewUnstartedServer()
+	var receivedMsg string
+	shortMsg := "Lorem ipsum dolor sit amet, consectetur adipiscing elit. Nam consequat nisl enim, vel finibus neque aliquet sit amet. Interdum et malesuada fames ac ante ipsum primis in faucibus. ✓"
+	count := 0
+	s.Handler = func(c smpptest.Conn, p pdu.Body) {
+		switch p.Header().ID {
+		case pdu.SubmitSMID:
+			r := pdu.NewSubmitSMResp()
+			r.Header().Seq = p.Header().Seq
+			_ = r.Fields().Set(pdufield.MessageID, fmt.Sprintf("foobar%d", count))
+			count++
+			receivedMsg = receivedMsg + p.Fields()[pdufield.ShortMessage].String()
+			_ = c.Write(r)
+		default:
+			smpptest.EchoHandler(c, p)
+		}
+	}
+	s.Start()
+	defer s.Close()
+	tx := &Transmitter{
+		Addr:   s.Addr(),
+		User:   smpptest.DefaultUser,
+		Passwd: smpptest.DefaultPasswd,
+	}
+	defer tx.Close()
+	conn := <-tx.Bind()
+	switch conn.Status() {
+	case Connected:
+	default:
+		t.Fatal(conn.Error())
+	}
+	parts, err := tx.SubmitLongMsg(&ShortMessage{
+		Src:      "root",
+		Dst:      "foobar",
+		Text:     pdutext.UCS2(shortMsg),
+		Validity: 10 * time.Minute,
+		Register: pdufield.NoDeliveryReceipt,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parts) != 3 {
+		t.Fatalf("expected %d responses, but received %d", 3, len(parts))
+	}
+	for index := range parts {
+		msgid := parts[index].RespID()
+		if msgid == "" {
+			t.Fatalf("pdu does not contain msgid: %#v", parts[index].Resp())
+		}
+
+		if receivedMsg != shortMsg {
+			t.Fatalf("receivedMsg: %v, does not match shortMsg: %v", receivedMsg, shortMsg)
+		}
+
+		if msgid != fmt.Sprintf("foobar%d", index) {
+			t.Fatalf("unexpected msgid: want foobar%d, have %q", index, msgid)
+		}
+	}
+}
+
+func TestQuerySM(t *testing.T) {
+	s := smpptest.NewUnstartedServer()
+	s.Handler = func(c smpptest.Conn, p pdu.Body) {
+		r := pdu.NewQuerySMResp()
+		r.Header().Seq = p.Header().Seq
+		_ = r.Fields().Set(pdufield.MessageID, p.Fields()[pdufield.MessageID])
+		_ = r.Fields().Set(pdufield.MessageState, 2)
+		_ = c.Write(r)
+	}
+	s.Start()
+	defer s.Close()
+	tx := &Transmitter{
+		Addr:   s.Addr(),
+		User:   smpptest.DefaultUser,
+		Passwd: smpptest.DefaultPasswd,
+	}
+	defer tx.Close()
+	conn := <-tx.Bind()
+	switch conn.Status() {
+	case Connected:
+	default:
+		t.Fatal(conn.Error())
+	}
+	qr, err := tx.QuerySM("root", "13", uint8(5), uint8(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if qr.MsgID != "13" {
+		t.Fatalf("unexpected msgid: want 13, have %s", qr.MsgID)
+	}
+	if qr.MsgState != "DELIVERED" {
+		t.Fatalf("unexpected state: want DELIVERED, have %q", qr.MsgState)
+	}
+}
+
+func TestSubmitMulti(t *testing.T) {
+	//construct a byte array with the UnsuccessSme
+	var bArray []byte
+	bArray = append(bArray, byte(0x00))       // TON
+	bArray = append(bArray, byte(0x00))       // NPI
+	bArray = append(bArray, []byte("123")...) // Address
+	bArray = append(bArray, byte(0x00))       // null terminator
+	bArray = append(bArray, byte(0x00))       // Error
+	bArray = append(bArray, byte(0x00))       // Error
+	bArray = append(bArray, byte(0x00))       // Error
+	bArray = append(bArray, byte(0x11))       // Error
+
+	s := smpptest.NewUnstartedServer()
+	s.Handler = func(c smpptest.Conn, p pdu.Body) {
+		switch p.Header().ID {
+		case pdu.SubmitMultiID:
+			r := pdu.NewSubmitMultiResp()
+			r.Header().Seq = p.Header().Seq
+			_ = r.Fields().Set(pdufield.MessageID, "foobar")
+			_ = r.Fields().Set(pdufield.NoUnsuccess, uint8(1))
+			_ = r.Fields().Set(pdufield.UnsuccessSme, bArray)
+			_ = c.Write(r)
+		default:
+			smpptest.EchoHandler(c, p)
+		}
+	}
+	s.Start()
+	defer s.Close()
+	tx := &Transmitter{
+		Addr:   s.Addr(),
+		User:   smpptest.DefaultUser,
+		Passwd: smpptest.DefaultPasswd,
+	}
+	defer tx.Close()
+	conn := <-tx.Bind()
+	switch conn.Status() {
+	case Connected:
+	default:
+		t.Fatal(conn.Error())
+	}
+	sm, err := tx.Submit(&ShortMessage{
+		Src:      "root",
+		DstList:  []string{"123", "2233", "32322", "4234234"},
+		DLs:      []string{"DistributionList1"},
+		Text:     pdutext.Raw("Lorem ipsum"),
+		Validity: 10 * time.Minute,
+		Register: pdufield.NoDeliveryReceipt,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	msgid := sm.RespID()
+	if msgid == "" {
+		t.Fatalf("pdu does not contain msgid: %#v", sm.Resp())
+	}
+	if msgid != "foobar" {
+		t.Fatalf("unexpected msgid: want foobar, have %q", msgid)
+	}
+	noUncess, _ := sm.NumbUnsuccess()
+	if noUncess != 1 {
+		t.Fatalf("unexpected number of unsuccess %d", noUncess)
+	}
+	uncessSmes, _ := sm.UnsuccessSmes()
+	if len(uncessSmes) != 1 {
+		t.Fatalf("unsucess sme list should have a size of 1, has %d", len(uncessSmes))
+	}
+	if addr := uncessSmes[0].Address; addr != "123" {
+		t.Fatalf("unexpected unsuccess address: want %q, have %q", "123", addr)
+	}
+	if code := uint32(uncessSmes[0].Error); code != 17 {
+		t.Fatalf("unexpected unsuccess error code: want 17, have %d", code)
+	}
+}
+
+func TestCloseGracefully(t *testing.T) {
+	const n = 3
+	received := make(chan struct{}, n)
+	s := smpptest.NewUnstartedServer()
+	s.Handler = func(c smpptest.Conn, p pdu.Body) {
+		switch p.Header().ID {
+		case pdu.SubmitSMID:
+			received <- struct{}{}
+			time.Sleep(50 * time.Millisecond)
+			r := pdu.NewSubmitSMResp()
+			r.Header().Seq = p.Header().Seq
+			_ = r.Fields().Set(pdufield.MessageID, "foobar")
+			_ = c.Write(r)
+		default:
+			smpptest.EchoHandler(c, p)
+		}
+	}
+	s.Start()
+	defer s.Close()
+	tx := &Transmitter{
+		Addr:        s.Addr(),
+		User:        smpptest.DefaultUser,
+		Passwd:      smpptest.DefaultPasswd,
+		RespTimeout: 5 * time.Second,
+	}
+	conn := <-tx.Bind()
+	switch conn.Status() {
+	case Connected:
+	default:
+		t.Fatal(conn.Error())
+	}
+	errs := make(chan error, n)
+	for range n {
+		go func() {
+			_, err := tx.Submit(&ShortMessage{
+				Src:  "root",
+				Dst:  "foobar",
+				Text: pdutext.Raw("Lorem ipsum"),
+			})
+			errs <- err
+		}()
+	}
+	<-received // at least one submit in flight
+	if err := tx.CloseGracefully(5 * time.Second); err != nil {
+		t.Fatal(err)
+	}
+	for range n {
+		if err := <-errs; err != nil {
+			t.Fatalf("unexpected error for drained submit: %v", err)
+		}
+	}
+	_, err := tx.Submit(&ShortMessage{
+		Src:  "root",
+		Dst:  "foobar",
+		Text: pdutext.Raw("Lorem ipsum"),
+	})
+	if err != ErrClosing {
+		t.Fatalf("unexpected error: want %v, have %v", ErrClosing, err)
+	}
+}
+
+func TestCloseGracefullyTimeout(t *testing.T) {
+	received := make(chan struct{}, 1)
+	s := smpptest.NewUnstartedServer()
+	s.Handler = func(c smpptest.Conn, p pdu.Body) {
+		if p.Header().ID == pdu.SubmitSMID {
+			received <- struct{}{} // never answered
+			return
+		}
+		smpptest.EchoHandler(c, p)
+	}
+	s.Start()
+	defer s.Close()
+	tx := &Transmitter{
+		Addr:        s.Addr(),
+		User:        smpptest.DefaultUser,
+		Passwd:      smpptest.DefaultPasswd,
+		RespTimeout: 10 * time.Second,
+	}
+	conn := <-tx.Bind()
+	switch conn.Status() {
+	case Connected:
+	default:
+		t.Fatal(conn.Error())
+	}
+	errs := make(chan error, 1)
+	go func() {
+		_, err := tx.Submit(&ShortMessage{
+			Src:  "root",
+			Dst:  "foobar",
+			Text: pdutext.Raw("Lorem ipsum"),
+		})
+		errs <- err
+	}()
+	<-received
+	if err := tx.CloseGracefully(100 * time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-errs:
+		if err != ErrTimeout {
+			t.Fatalf("unexpected error: want %v, have %v", ErrTimeout, err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("submit still pending after CloseGracefully")
+	}
+}
+
+func TestDuplicateResp(t *testing.T) {
+	s := smpptest.NewUnstartedServer()
+	s.Handler = func(c smpptest.Conn, p pdu.Body) {
+		if p.Header().ID != pdu.SubmitSMID {
+			smpptest.EchoHandler(c, p)
+			return
+		}
+		for _, id := range []string{"first", "duplicate"} {
+			r := pdu.NewSubmitSMResp()
+			r.Header().Seq = p.Header().Seq
+			_ = r.Fields().Set(pdufield.MessageID, id)
+			_ = c.Write(r)
+		}
+	}
+	s.Start()
+	defer s.Close()
+	unmatched := make(chan pdu.Body, 3)
+	tx := &Transmitter{
+		Addr:        s.Addr(),
+		User:        smpptest.DefaultUser,
+		Passwd:      smpptest.DefaultPasswd,
+		WindowSize:  1,
+		OnUnmatched: func(p pdu.Body) { unmatched <- p },
+	}
+	defer tx.Close()
+	conn := <-tx.Bind()
+	switch conn.Status() {
+	case Connected:
+	default:
+		t.Fatal(conn.Error())
+	}
+	for range 3 {
+		sm, err := tx.Submit(&ShortMessage{
+			Src:  "root",
+			Dst:  "foobar",
+			Text: pdutext.Raw("Lorem ipsum"),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if id := sm.RespID(); id != "first" {
+			t.Fatalf("unexpected message id: want %q, have %q", "first", id)
+		}
+		select {
+		case p := <-unmatched:
+			if id := p.Fields()[pdufield.MessageID].String(); id != "duplicate" {
+				t.Fatalf("unexpected unmatched message id: want %q, have %q", "duplicate", id)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("duplicate response not discarded")
+		}
+		if n := atomic.LoadInt32(&tx.tx.count); n != 0 {
+			t.Fatalf("unexpected window count: want 0, have %d", n)
+		}
+	}
+}
+
+func TestOutstanding(t *testing.T) {
+	type req struct {
+		c smpptest.Conn
+		p pdu.Body
+	}
+	received := make(chan req, 3)
+	s := smpptest.NewUnstartedServer()
+	s.Handler = func(c smpptest.Conn, p pdu.Body) {
+		if p.Header().ID != pdu.SubmitSMID {
+			smpptest.EchoHandler(c, p)
+			return
+		}
+		received <- req{c, p} // answered by the test
+	}
+	s.Start()
+	defer s.Close()
+	tx := &Transmitter{
+		Addr:        s.Addr(),
+		User:        smpptest.DefaultUser,
+		Passwd:      smpptest.DefaultPasswd,
+		WindowSize:  5,
+		RespTimeout: 5 * time.Second,
+	}
+	defer tx.Close()
+	conn := <-tx.Bind()
+	switch conn.Status() {
+	case Connected:
+	default:
+		t.Fatal(conn.Error())
+	}
+	if n := tx.Outstanding(); n != 0 {
+		t.Fatalf("unexpected outstanding: want 0, have %d", n)
+	}
+	errs := make(chan error, 3)
+	for range 3 {
+		go func() {
+			_, err := tx.Submit(&ShortMessage{
+				Src:  "root",
+				Dst:  "foobar",
+				Text: pdutext.Raw("Lorem ipsum"),
+			})
+			errs <- err
+		}()
+	}
+	var reqs []req
+	for range 3 {
+		reqs = append(reqs, <-received)
+	}
+	if n := tx.Outstanding(); n != 3 {
+		t.Fatalf("unexpected outstanding: want 3, have %d", n)
+	}
+	for _, r := range reqs {
+		resp := pdu.NewSubmitSMResp()
+		resp.Header().Seq = r.p.Header().Seq
+		_ = resp.Fields().Set(pdufield.MessageID, "foobar")
+		_ = r.c.Write(resp)
+	}
+	for range 3 {
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := tx.Outstanding(); n != 0 {
+		t.Fatalf("unexpected outstanding: want 0, have %d", n)
+	}
+}
+
+func TestNotConnected(t *testing.T) {
+	s := smpptest.NewUnstartedServer()
+	s.Handler = func(c smpptest.Conn, p pdu.Body) {
+		switch p.Header().ID {
+		case pdu.SubmitSMID:
+			r := pdu.NewSubmitSMResp()
+			r.Header().Seq = p.Header().Seq
+			_ = r.Fields().Set(pdufield.MessageID, "foobar")
+			_ = c.Write(r)
+		default:
+			smpptest.EchoHandler(c, p)
+		}
+	}
+	s.Start()
+	defer s.Close()
+	tx := &Transmitter{
+		Addr:   s.Addr(),
+		User:   smpptest.DefaultUser,
+		Passwd: smpptest.DefaultPasswd,
+	}
+	// Open connection and then close it
+	conn := <-tx.Bind()
+	switch conn.Status() {
+	case Connected:
+	default:
+		t.Fatal(conn.Error())
+	}
+	tx.Close()
+	_, err := tx.Submit(&ShortMessage{
+		Src:      "root",
+		Dst:      "foobar",
+		Text:     pdutext.Raw("Lorem ipsum"),
+		Validity: 10 * time.Minute,
+		Register: pdufield.NoDeliveryReceipt,
+	})
+	if err != ErrNotConnected {
+		t.Fatalf("Error should be not connect, got %s", err.Error())
+	}
+
+}
+
+func TestReadTimeout(t *testing.T) {
+	s := smpptest.NewUnstartedServer()
+	stop := make(chan struct{})
+	defer close(stop)
+	s.Handler = func(c smpptest.Conn, p pdu.Body) {
+		<-stop // stop reading and never respond
+	}
+	s.Start()
+	defer s.Close()
+	tx := &Transmitter{
+		Addr:        s.Addr(),
+		User:        smpptest.DefaultUser,
+		Passwd:      smpptest.DefaultPasswd,
+		RespTimeout: 5 * time.Second,
+		ReadTimeout: 300 * time.Millisecond,
+	}
+	defer tx.Close()
+	connc := tx.Bind()
+	conn := <-connc
+	switch conn.Status() {
+	case Connected:
+	default:
+		t.Fatal(conn.Error())
+	}
+	start := time.Now()
+	_, err := tx.Submit(&ShortMessage{
+		Src:      "root",
+		Dst:      "foobar",
+		Text:     pdutext.Raw("Lorem ipsum"),
+		Register: pdufield.NoDeliveryReceipt,
+	})
+	if err != ErrNotConnected {
+		t.Fatalf("unexpected error: want %v, have %v", ErrNotConnected, err)
+	}
+	if d := time.Since(start); d >= tx.RespTimeout {
+		t.Fatalf("submit took %s, want less than RespTimeout", d)
+	}
+	conn = <-connc
+	if conn.Status() != Disconnected {
+		t.Fatalf("unexpected status: want %s, have %s", Disconnected, conn.Status())
+	}
+}
+
+func TestWriteTimeout(t *testing.T) {
+	cli, srv := net.Pipe()
+	defer srv.Close()
+	go func() {
+		// Answer the bind, then stop reading as a stalled peer would.
+		p, err := pdu.Decode(srv)
+		if err != nil {
+			return
+		}
+		r := pdu.NewBindTransmitterResp()
+		r.Header().Seq = p.Header().Seq
+		_ = r.Fields().Set(pdufield.SystemID, "pipe")
+		_ = r.SerializeTo(srv)
+	}()
+	tx := &Transmitter{
+		Addr:         "pipe",
+		User:         smpptest.DefaultUser,
+		Passwd:       smpptest.DefaultPasswd,
+		EnquireLink:  -1,
+		RespTimeout:  5 * time.Second,
+		WriteTimeout: 300 * time.Millisecond,
+		Dialer:       pipeDialer{cli},
+	}
+	defer tx.Close()
+	conn := <-tx.Bind()
+	switch conn.Status() {
+	case Connected:
+	default:
+		t.Fatal(conn.Error())
+	}
+	start := time.Now()
+	_, err := tx.Submit(&ShortMessage{
+		Src:      "root",
+		Dst:      "foobar",
+		Text:     pdutext.Raw("Lorem ipsum"),
+		Register: pdufield.NoDeliveryReceipt,
+	})
+	var ne net.Error
+	if !errors.As(err, &ne) || !ne.Timeout() {
+		t.Fatalf("unexpected error: want a timeout, have %v", err)
+	}
+	if d := time.Since(start); d >= tx.RespTimeout {
+		t.Fatalf("submit took %s, want less than RespTimeout", d)
+	}
+}
+
+func TestSubmitPDU(t *testing.T) {
+	s := smpptest.NewUnstartedServer()
+	s.Handler = func(c smpptest.Conn, p pdu.Body) {
+		switch p.Header().ID {
+		case pdu.SubmitSMID:
+			r := pdu.NewSubmitSMResp()
+			r.Header().Seq = p.Header().Seq
+			vendor := p.TLVFields()[0x1400]
+			if vendor == nil || vendor.String() != "vendor" {
+				r.Header().Status = 0xc3 // expected optional parameter missing
+			}
+			_ = r.Fields().Set(pdufield.MessageID, "foobar")
+			_ = c.Write(r)
+		default:
+			smpptest.EchoHandler(c, p)
+		}
+	}
+	s.Start()
+	defer s.Close()
+	tx := &Transmitter{
+		Addr:   s.Addr(),
+		User:   smpptest.DefaultUser,
+		Passwd: smpptest.DefaultPasswd,
+	}
+	defer tx.Close()
+	conn := <-tx.Bind()
+	switch conn.Status() {
+	case Connected:
+	default:
+		t.Fatal(conn.Error())
+	}
+	p := pdu.NewSubmitSM(pdutlv.Fields{0x1400: pdutlv.String("vendor")})
+	f := p.Fields()
+	_ = f.Set(pdufield.SourceAddr, "root")
+	_ = f.Set(pdufield.DestinationAddr, "foobar")
+	_ = f.Set(pdufield.ShortMessage, pdutext.Raw("Lorem ipsum"))
+	resp, err := tx.SubmitPDU(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Header().ID != pdu.SubmitSMRespID || resp.Header().Seq != p.Header().Seq {
+		t.Fatalf("unexpected response: %#v", resp.Header())
+	}
+	if id := resp.Fields()[pdufield.MessageID].String(); id != "foobar" {
+		t.Fatalf("unexpected msgid: want foobar, have %q", id)
+	}
+	resp, err = tx.SubmitPDU(pdu.NewSubmitSM(nil))
+	if err != pdu.Status(0xc3) {
+		t.Fatalf("unexpected error: want %v, have %v", pdu.Status(0xc3), err)
+	}
+	if resp == nil {
+		t.Fatal("missing response along with status error")
+	}
+}
+
+func TestLongMessageGSM7Escape(t *testing.T) {
+	s := smpptest.NewUnstartedServer()
+	var received []string
+	s.Handler = func(c smpptest.Conn, p pdu.Body) {
+		switch p.Header().ID {
+		case pdu.SubmitSMID:
+			received = append(received, p.Fields()[pdufield.ShortMessage].String())
+			r := pdu.NewSubmitSMResp()
+			r.Header().Seq = p.Header().Seq
+			_ = r.Fields().Set(pdufield.MessageID, "foobar")
+			_ = c.Write(r)
+		default:
+			smpptest.EchoHandler(c, p)
+		}
+	}
+	s.Start()
+	defer s.Close()
+	tx := &Transmitter{
+		Addr:   s.Addr(),
+		User:   smpptest.DefaultUser,
+		Passwd: smpptest.DefaultPasswd,
+	}
+	defer tx.Close()
+	conn := <-tx.Bind()
+	switch conn.Status() {
+	case Connected:
+	default:
+		t.Fatal(conn.Error())
+	}
+	text := "a" + strings.Repeat("{}", 100)
+	parts, err := tx.SubmitLongMsg(&ShortMessage{
+		Src:      "root",
+		Dst:      "foobar",
+		Text:     pdutext.GSM7(text),
+		Register: pdufield.NoDeliveryReceipt,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parts) != 3 {
+		t.Fatalf("expected %d responses, but received %d", 3, len(parts))
+	}
+	if have := strings.Join(received, ""); have != text {
+		t.Fatalf("unexpected text:\nwant: %q\nhave: %q", text, have)
+	}
+}
+
+func TestOnWrite(t *testing.T) {
+	s := smpptest.NewUnstartedServer()
+	s.Handler = func(c smpptest.Conn, p pdu.Body) {
+		switch p.Header().ID {
+		case pdu.SubmitSMID:
+			r := pdu.NewSubmitSMResp()
+			r.Header().Seq = p.Header().Seq
+			_ = r.Fields().Set(pdufield.MessageID, "foobar")
+			_ = c.Write(r)
+		default:
+			smpptest.EchoHandler(c, p)
+		}
+	}
+	s.Start()
+	defer s.Close()
+	type frame struct {
+		p    pdu.Body
+		wire []byte
+	}
+	sent := make(chan frame, 1)
+	tx := &Transmitter{
+		Addr:   s.Addr(),
+		User:   smpptest.DefaultUser,
+		Passwd: smpptest.DefaultPasswd,
+		OnWrite: func(p pdu.Body, wire []byte) {
+			if p.Header().ID == pdu.SubmitSMID {
+				sent <- frame{p, append([]byte(nil), wire...)}
+			}
+		},
+	}
+	defer tx.Close()
+	conn := <-tx.Bind()
+	switch conn.Status() {
+	case Connected:
+	default:
+		t.Fatal(conn.Error())
+	}
+	_, err := tx.Submit(&ShortMessage{
+		Src:  "root",
+		Dst:  "foobar",
+		Text: pdutext.Raw("Lorem ipsum"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var f frame
+	select {
+	case f = <-sent:
+	case <-time.After(time.Second):
+		t.Fatal("OnWrite not called for submit_sm")
+	}
+	var b bytes.Buffer
+	if err := f.p.SerializeTo(&b); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(f.wire, b.Bytes()) {
+		t.Fatalf("unexpected wire bytes: want %x, have %x", b.Bytes(), f.wire)
+	}
+	if l := int(f.wire[0])<<24 | int(f.wire[1])<<16 | int(f.wire[2])<<8 | int(f.wire[3]); l != len(f.wire) {
+		t.Fatalf("unexpected command_length: want %d, have %d", len(f.wire), l)
+	}
+}
+
+func TestLongMessageUDH(t *testing.T) {
+	s := smpptest.NewUnstartedServer()
+	s.Handler = func(c smpptest.Conn, p pdu.Body) {
+		switch p.Header().ID {
+		case pdu.SubmitSMID:
+			r := pdu.NewSubmitSMResp()
+			r.Header().Seq = p.Header().Seq
+			f := p.Fields()
+			udh := p.UDH()
+			switch {
+			case udh == nil || len(udh.IE) != 2:
+				r.Header().Status = 0x08
+			case udh.IE[1].IEI != pdufield.UDHIEIApplicationPort16Bit:
+				r.Header().Status = 0x08
+			case int(f[pdufield.UDHLength].Raw().(uint8)) != udh.Len():
+				r.Header().Status = 0x08
+			case 1+udh.Len()+f[pdufield.ShortMessage].Len() > 140:
+				r.Header().Status = 0x08
+			}
+			if ok, _, _, _ := udh.IsConcatenated(); !ok {
+				r.Header().Status = 0x08
+			}
+			_ = r.Fields().Set(pdufield.MessageID, "foobar")
+			_ = c.Write(r)
+		default:
+			smpptest.EchoHandler(c, p)
+		}
+	}
+	s.Start()
+	defer s.Close()
+	tx := &Transmitter{
+		Addr:   s.Addr(),
+		User:   smpptest.DefaultUser,
+		Passwd: smpptest.DefaultPasswd,
+	}
+	defer tx.Close()
+	conn := <-tx.Bind()
+	switch conn.Status() {
+	case Connected:
+	default:
+		t.Fatal(conn.Error())
+	}
+	parts, err := tx.SubmitLongMsg(&ShortMessage{
+		Src:  "root",
+		Dst:  "foobar",
+		Text: pdutext.Raw(bytes.Repeat([]byte{0x42}, 260)),
+		UDH:  []pdufield.UDHIE{pdufield.NewIEApplicationPort16Bit(2948, 9200)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 127 octets per part: 140, minus 7 for the concatenation UDH and 6 for the ports.
+	if len(parts) != 3 {
+		t.Fatalf("unexpected number of parts: want 3, have %d", len(parts))
+	}
+}
+
+func TestSubmitLongMsgMaxParts(t *testing.T) {
+	var submits atomic.Int32
+	s := smpptest.NewUnstartedServer()
+	s.Handler = func(c smpptest.Conn, p pdu.Body) {
+		if p.Header().ID != pdu.SubmitSMID {
+			smpptest.EchoHandler(c, p)
+			return
+		}
+		submits.Add(1)
+		r := pdu.NewSubmitSMResp()
+		r.Header().Seq = p.Header().Seq
+		_ = r.Fields().Set(pdufield.MessageID, "foobar")
+		_ = c.Write(r)
+	}
+	s.Start()
+	defer s.Close()
+	tx := &Transmitter{
+		Addr:     s.Addr(),
+		User:     smpptest.DefaultUser,
+		Passwd:   smpptest.DefaultPasswd,
+		MaxParts: 2,
+	}
+	defer tx.Close()
+	conn := <-tx.Bind()
+	switch conn.Status() {
+	case Connected:
+	default:
+		t.Fatal(conn.Error())
+	}
+	// 134 octets per part: 140, minus 6 for the concatenation UDH.
+	sm := &ShortMessage{
+		Src:  "root",
+		Dst:  "foobar",
+		Text: pdutext.Raw(bytes.Repeat([]byte{0x42}, 300)),
+	}
+	_, err := tx.SubmitLongMsg(sm)
+	var perr *PartsError
+	if !errors.As(err, &perr) {
+		t.Fatalf("unexpected error: want *PartsError, have %v", err)
+	}
+	if perr.Parts != 3 || perr.Max != 2 {
+		t.Fatalf("unexpected parts error: want 3 parts max 2, have %d parts max %d", perr.Parts, perr.Max)
+	}
+	if n := submits.Load(); n != 0 {
+		t.Fatalf("unexpected submits: want 0, have %d", n)
+	}
+	tx.TruncateParts = true
+	parts, err := tx.SubmitLongMsg(sm)
 	if err != nil {
 		t.Fatal(err)
 	}
-	msgid := sm.RespID()
-	if msgid == "" {
-		t.Fatalf("pdu does not contain msgid: %#v", sm.Resp())
+	if len(parts) != 2 {
+		t.Fatalf("unexpected number of parts: want 2, have %d", len(parts))
 	}
-	if msgid != "foobar" {
-		t.Fatalf("unexpected msgid: want foobar, have %q", msgid)
+	if n := submits.Load(); n != 2 {
+		t.Fatalf("unexpected submits: want 2, have %d", n)
 	}
-	noUncess, _ := sm.NumbUnsuccess()
-	if noUncess != 1 {
-		t.Fatalf("unexpected number of unsuccess %d", noUncess)
+	tx.MaxParts, tx.TruncateParts = 0, false
+	sm.Text = pdutext.Raw(bytes.Repeat([]byte{0x42}, 134*MaxConcatenatedParts+1))
+	_, err = tx.SubmitLongMsg(sm)
+	if !errors.As(err, &perr) || perr.Max != MaxConcatenatedParts {
+		t.Fatalf("unexpected error: want max %d parts, have %v", MaxConcatenatedParts, err)
 	}
-	uncessSmes, _ := sm.UnsuccessSmes()
-	if len(uncessSmes) != 1 {
-		t.Fatalf("unsucess sme list should have a size of 1, has %d", len(uncessSmes))
+}
+
+func TestSubmitLongMsgUDHTooLong(t *testing.T) {
+	// An IE with 130 octets of data leaves 1 octet of text after the
+	// concatenation IE and the UDH length octet, and 129 leave 2.
+	ie := func(n int) pdufield.UDHIE {
+		return pdufield.UDHIE{IEI: 0x70, IELength: uint8(n), IEData: make([]byte, n)}
+	}
+	test := []struct {
+		text pdutext.Codec
+		ie   pdufield.UDHIE
+		ok   bool
+	}{
+		{pdutext.Raw("Lorem ipsum"), ie(130), false},
+		{pdutext.Raw("Lorem ipsum"), ie(129), true},
+		{pdutext.GSM7("{Lorem ipsum}"), ie(130), false},
+		{pdutext.GSM7("{Lorem ipsum}"), ie(129), true},
+		{pdutext.UCS2("Lorem ipsum"), ie(129), false},
+		{pdutext.UCS2("Lorem ipsum"), ie(127), true},
+	}
+	for _, tc := range test {
+		sm := &ShortMessage{
+			Src:  "root",
+			Dst:  "foobar",
+			Text: tc.text,
+			UDH:  []pdufield.UDHIE{tc.ie},
+		}
+		pdus, err := sm.BuildLongPDUs()
+		if !tc.ok {
+			if err != pdutext.ErrUDHTooLong {
+				t.Fatalf("unexpected error for %T with a %d octets IE: %v", tc.text, tc.ie.IELength, err)
+			}
+			if _, err := new(Transmitter).SubmitLongMsg(sm); err != pdutext.ErrUDHTooLong {
+				t.Fatalf("unexpected submit error for %T with a %d octets IE: %v", tc.text, tc.ie.IELength, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("unexpected error for %T with a %d octets IE: %v", tc.text, tc.ie.IELength, err)
+		}
+		for i, p := range pdus {
+			var b bytes.Buffer
+			if err := p.SerializeTo(&b); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := pdu.Decode(&b); err != nil {
+				t.Fatalf("part %d: %v", i+1, err)
+			}
+		}
 	}
 }
 
-func TestNotConnected(t *testing.T) {
+func TestSubmitLongMsgPartFailure(t *testing.T) {
+	var rejects atomic.Int32 // rejections of part 2 left
+	s := smpptest.NewUnstartedServer()
+	s.Handlers = map[pdu.ID]smpptest.HandlerFunc{
+		pdu.SubmitSMID: func(c smpptest.Conn, p pdu.Body) {
+			r := pdu.NewSubmitSMResp()
+			r.Header().Seq = p.Header().Seq
+			if _, _, _, part := p.UDH().IsConcatenated(); part == 2 && rejects.Add(-1) >= 0 {
+				r.Header().Status = pdu.StatusThrottled
+			}
+			_ = r.Fields().Set(pdufield.MessageID, "foobar")
+			_ = c.Write(r)
+		},
+	}
+	s.Start()
+	defer s.Close()
+	tx := &Transmitter{
+		Addr:   s.Addr(),
+		User:   smpptest.DefaultUser,
+		Passwd: smpptest.DefaultPasswd,
+	}
+	defer tx.Close()
+	conn := <-tx.Bind()
+	switch conn.Status() {
+	case Connected:
+	default:
+		t.Fatal(conn.Error())
+	}
+	sm := &ShortMessage{
+		Src:  "root",
+		Dst:  "foobar",
+		Text: pdutext.Raw(bytes.Repeat([]byte{0x42}, 300)),
+	}
+	rejects.Store(1)
+	parts, err := tx.SubmitLongMsg(sm)
+	var lerr *LongMsgError
+	if !errors.As(err, &lerr) {
+		t.Fatalf("unexpected error: want *LongMsgError, have %v", err)
+	}
+	if lerr.Parts != 3 || len(lerr.Failed) != 1 || lerr.Failed[0].Index != 1 {
+		t.Fatalf("unexpected failed parts: want index 1 of 3, have %+v of %d", lerr.Failed, lerr.Parts)
+	}
+	if !errors.Is(err, pdu.StatusThrottled) {
+		t.Fatalf("unexpected error: want %v, have %v", pdu.StatusThrottled, err)
+	}
+	if _, _, _, part := lerr.Failed[0].PDU.UDH().IsConcatenated(); part != 2 {
+		t.Fatalf("unexpected failed PDU: want part 2, have %d", part)
+	}
+	if len(parts) != 2 {
+		t.Fatalf("unexpected number of parts: want 2, have %d", len(parts))
+	}
+	rejects.Store(2)
+	tx.PartRetry = &RetryPolicy{Backoff: time.Millisecond}
+	parts, err = tx.SubmitLongMsg(sm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parts) != 3 {
+		t.Fatalf("unexpected number of parts: want 3, have %d", len(parts))
+	}
+}
+
+func TestDestAddresses(t *testing.T) {
+	sm := &ShortMessage{
+		DstList:     []string{"123"},
+		DestAddrTON: pdufield.TONNational,
+		DestAddrNPI: pdufield.NPIISDN,
+		Dsts: []Destination{
+			{Addr: "+4412", TON: pdufield.TONInternational, NPI: pdufield.NPIISDN},
+			{Addr: "Info", TON: pdufield.TONAlphanumeric, NPI: pdufield.NPIUnknown},
+		},
+		DLs: []string{"DL1"},
+	}
+	want := []byte{
+		0x01, 0x02, 0x01, '1', '2', '3', 0x00,
+		0x01, 0x01, 0x01, '+', '4', '4', '1', '2', 0x00,
+		0x01, 0x05, 0x00, 'I', 'n', 'f', 'o', 0x00,
+		0x02, 'D', 'L', '1', 0x00,
+	}
+	if have := destAddresses(sm); !bytes.Equal(want, have) {
+		t.Fatalf("unexpected dest_addresses: want % x, have % x", want, have)
+	}
+}
+
+func TestSubmitMultiDestinations(t *testing.T) {
 	s := smpptest.NewUnstartedServer()
 	s.Handler = func(c smpptest.Conn, p pdu.Body) {
 		switch p.Header().ID {
-		case pdu.SubmitSMID:
-			r := pdu.NewSubmitSMResp()
+		case pdu.SubmitMultiID:
+			r := pdu.NewSubmitMultiResp()
 			r.Header().Seq = p.Header().Seq
+			dl, ok := p.Fields()[pdufield.DestinationList].(*pdufield.DestSmeList)
+			switch {
+			case !ok || len(dl.Data) != 2:
+				r.Header().Status = 0x33 // invalid number of destinations
+			case dl.Data[0].Ton.Data != pdufield.TONInternational || dl.Data[0].DestAddr.String() != "4412":
+				r.Header().Status = 0x50 // invalid destination TON
+			case dl.Data[1].Ton.Data != pdufield.TONNational || dl.Data[1].Npi.Data != pdufield.NPINational:
+				r.Header().Status = 0x50
+			}
 			_ = r.Fields().Set(pdufield.MessageID, "foobar")
+			_ = r.Fields().Set(pdufield.NoUnsuccess, uint8(0))
 			_ = c.Write(r)
 		default:
 			smpptest.EchoHandler(c, p)
@@ -444,23 +2695,432 @@ func TestNotConnected(t *testing.T) {
 		User:   smpptest.DefaultUser,
 		Passwd: smpptest.DefaultPasswd,
 	}
-	// Open connection and then close it
+	defer tx.Close()
 	conn := <-tx.Bind()
 	switch conn.Status() {
 	case Connected:
 	default:
 		t.Fatal(conn.Error())
 	}
-	tx.Close()
-	_, err := tx.Submit(&ShortMessage{
-		Src:      "root",
-		Dst:      "foobar",
-		Text:     pdutext.Raw("Lorem ipsum"),
-		Validity: 10 * time.Minute,
-		Register: pdufield.NoDeliveryReceipt,
+	sm, err := tx.Submit(&ShortMessage{
+		Src: "root",
+		Dsts: []Destination{
+			{Addr: "4412", TON: pdufield.TONInternational, NPI: pdufield.NPIISDN},
+			{Addr: "0612", TON: pdufield.TONNational, NPI: pdufield.NPINational},
+		},
+		Text: pdutext.Raw("Lorem ipsum"),
 	})
-	if err != ErrNotConnected {
-		t.Fatalf("Error should be not connect, got %s", err.Error())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msgid := sm.RespID(); msgid != "foobar" {
+		t.Fatalf("unexpected msgid: want foobar, have %q", msgid)
+	}
+}
+
+func TestPeerInterfaceVersion(t *testing.T) {
+	for _, want := range []uint8{0, 0x34} {
+		s := smpptest.NewUnstartedServer()
+		s.InterfaceVersion = want
+		s.Start()
+		tx := &Transmitter{
+			Addr:   s.Addr(),
+			User:   smpptest.DefaultUser,
+			Passwd: smpptest.DefaultPasswd,
+		}
+		conn := <-tx.Bind()
+		switch conn.Status() {
+		case Connected:
+		default:
+			t.Fatal(conn.Error())
+		}
+		v, ok := tx.PeerInterfaceVersion()
+		if ok != (want != 0) || v != want {
+			t.Fatalf("unexpected interface version: want %#x, have %#x (%t)", want, v, ok)
+		}
+		tx.Close()
+		s.Close()
+	}
+}
+
+func TestPeerSystemID(t *testing.T) {
+	for _, want := range []string{"", "SMSC01"} {
+		s := smpptest.NewUnstartedServer()
+		s.SystemID = want
+		s.Start()
+		tx := &Transmitter{
+			Addr:   s.Addr(),
+			User:   smpptest.DefaultUser,
+			Passwd: smpptest.DefaultPasswd,
+		}
+		if id := tx.PeerSystemID(); id != "" {
+			t.Fatalf("unexpected system_id before bind: %q", id)
+		}
+		conn := <-tx.Bind()
+		switch conn.Status() {
+		case Connected:
+		default:
+			t.Fatal(conn.Error())
+		}
+		if want == "" {
+			want = smpptest.DefaultSystemID
+		}
+		if id := tx.PeerSystemID(); id != want {
+			t.Fatalf("unexpected system_id: want %q, have %q", want, id)
+		}
+		tx.Close()
+		s.Close()
+	}
+}
+
+func TestBindStatus(t *testing.T) {
+	test := []struct {
+		passwd string
+		status pdu.Status // forced by the server
+		want   pdu.Status
+	}{
+		{smpptest.DefaultPasswd, pdu.StatusInvSysID, pdu.StatusInvSysID},
+		{"wrong", 0, pdu.StatusInvPaswd},
+		{smpptest.DefaultPasswd, pdu.StatusAlyBnd, pdu.StatusAlyBnd},
+		{smpptest.DefaultPasswd, pdu.StatusBindFail, pdu.StatusBindFail},
+		{smpptest.DefaultPasswd, pdu.StatusSysErr, pdu.StatusSysErr},
+	}
+	for _, tc := range test {
+		s := smpptest.NewUnstartedServer()
+		s.BindStatus = tc.status
+		s.Start()
+		tx := &Transmitter{
+			Addr:   s.Addr(),
+			User:   smpptest.DefaultUser,
+			Passwd: tc.passwd,
+		}
+		conn := <-tx.Bind()
+		if conn.Status() != BindFailed {
+			t.Fatalf("unexpected status: want %s, have %s", BindFailed, conn.Status())
+		}
+		var be *BindError
+		if !errors.As(conn.Error(), &be) {
+			t.Fatalf("unexpected error: want *BindError, have %#v", conn.Error())
+		}
+		if be.Status != tc.want || be.ID != pdu.BindTransmitterRespID {
+			t.Fatalf("unexpected bind error: want %s, have %s", tc.want.Name(), be.Status.Name())
+		}
+		if !errors.Is(conn.Error(), tc.want) {
+			t.Fatalf("error %v does not match %s", conn.Error(), tc.want.Name())
+		}
+		tx.Close()
+		s.Close()
+	}
+}
+
+func TestBindTLVs(t *testing.T) {
+	tlvs := pdutlv.Fields{
+		0x1401: pdutlv.CString("customer-42"), // vendor-specific
 	}
+	binds := make(chan pdu.Body, 1)
+	s := smpptest.NewUnstartedServer()
+	s.OnBind = func(p pdu.Body) { binds <- p }
+	s.Start()
+	defer s.Close()
+	test := []ClientConn{
+		&Transmitter{Addr: s.Addr(), User: smpptest.DefaultUser, Passwd: smpptest.DefaultPasswd, BindTLVs: tlvs},
+		&Receiver{Addr: s.Addr(), User: smpptest.DefaultUser, Passwd: smpptest.DefaultPasswd, BindTLVs: tlvs},
+		&Transceiver{Addr: s.Addr(), User: smpptest.DefaultUser, Passwd: smpptest.DefaultPasswd, BindTLVs: tlvs},
+	}
+	for _, cc := range test {
+		conn := <-cc.Bind()
+		switch conn.Status() {
+		case Connected:
+		default:
+			t.Fatal(conn.Error())
+		}
+		p := <-binds
+		if v, ok := p.TLVFields().String(0x1401); !ok || v != "customer-42" {
+			t.Fatalf("unexpected bind TLV of %s: %q (%t)", p.Header().ID, v, ok)
+		}
+		var b bytes.Buffer
+		if err := p.SerializeTo(&b); err != nil {
+			t.Fatal(err)
+		}
+		want := []byte("client\x00secret\x00\x00\x34\x00\x00\x00" + // address_range last
+			"\x14\x01\x00\x0ccustomer-42\x00")
+		if body := b.Bytes()[16:]; !bytes.Equal(body, want) {
+			t.Fatalf("unexpected %s body:\nwant %q\nhave %q", p.Header().ID, want, body)
+		}
+		cc.Close()
+	}
+}
+
+func TestBindTimeout(t *testing.T) {
+	// Accept the TCP connection but never answer the bind.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			defer c.Close()
+		}
+	}()
+	tx := &Transmitter{
+		Addr:         l.Addr().String(),
+		User:         smpptest.DefaultUser,
+		Passwd:       smpptest.DefaultPasswd,
+		BindTimeout:  200 * time.Millisecond,
+		BindInterval: time.Hour,
+	}
+	defer tx.Close()
+	start := time.Now()
+	select {
+	case conn := <-tx.Bind():
+		if conn.Status() != BindFailed || conn.Error() != ErrBindTimeout {
+			t.Fatalf("unexpected status: want %s (%v), have %s (%v)",
+				BindFailed, ErrBindTimeout, conn.Status(), conn.Error())
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("bind timeout did not fire")
+	}
+	if d := time.Since(start); d < tx.BindTimeout {
+		t.Fatalf("bind failed after %s, want at least %s", d, tx.BindTimeout)
+	}
+}
+
+func TestConnectTimeout(t *testing.T) {
+	// Grab a free local port and close it, so nothing listens there.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+	tx := &Transmitter{
+		Addr:           addr,
+		User:           smpptest.DefaultUser,
+		Passwd:         smpptest.DefaultPasswd,
+		ConnectTimeout: 200 * time.Millisecond,
+		BindInterval:   time.Hour,
+	}
+	defer tx.Close()
+	select {
+	case conn := <-tx.Bind():
+		if conn.Status() != ConnectionFailed || conn.Error() == nil {
+			t.Fatalf("unexpected status: want %s, have %s (%v)",
+				ConnectionFailed, conn.Status(), conn.Error())
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("connect timeout did not fire")
+	}
+}
+
+func TestFailover(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dead := l.Addr().String()
+	l.Close()
+	s := smpptest.NewServer()
+	defer s.Close()
+	tx := &Transmitter{
+		Addr:   dead,
+		Addrs:  []string{s.Addr()},
+		User:   smpptest.DefaultUser,
+		Passwd: smpptest.DefaultPasswd,
+	}
+	defer tx.Close()
+	conn := <-tx.Bind()
+	if conn.Status() != Connected {
+		t.Fatalf("unexpected status: want %s, have %s (%v)", Connected, conn.Status(), conn.Error())
+	}
+	if conn.Addr() != s.Addr() {
+		t.Fatalf("unexpected address: want %s, have %s", s.Addr(), conn.Addr())
+	}
+}
+
+func TestOutOfOrderResponses(t *testing.T) {
+	const window = 5
+	s := smpptest.NewUnstartedServer()
+	var pending []pdu.Body
+	s.Handler = func(c smpptest.Conn, p pdu.Body) {
+		switch p.Header().ID {
+		case pdu.SubmitSMID:
+			pending = append(pending, p)
+			if len(pending) < window {
+				return
+			}
+			// Answer in reverse order, with a duplicate and a response
+			// for an unknown sequence number in between.
+			for i := len(pending) - 1; i >= 0; i-- {
+				r := pdu.NewSubmitSMResp()
+				r.Header().Seq = pending[i].Header().Seq
+				_ = r.Fields().Set(pdufield.MessageID, pending[i].Fields()[pdufield.ShortMessage].String())
+				_ = c.Write(r)
+				if i == window/2 {
+					_ = c.Write(r)
+					u := pdu.NewSubmitSMResp()
+					u.Header().Seq = 0xFFFFFF
+					_ = c.Write(u)
+				}
+			}
+			pending = nil
+		default:
+			smpptest.EchoHandler(c, p)
+		}
+	}
+	s.Start()
+	defer s.Close()
+	unmatched := make(chan pdu.Body, window)
+	tx := &Transmitter{
+		Addr:        s.Addr(),
+		User:        smpptest.DefaultUser,
+		Passwd:      smpptest.DefaultPasswd,
+		WindowSize:  window,
+		RespTimeout: 2 * time.Second,
+		OnUnmatched: func(p pdu.Body) { unmatched <- p },
+	}
+	defer tx.Close()
+	conn := <-tx.Bind()
+	switch conn.Status() {
+	case Connected:
+	default:
+		t.Fatal(conn.Error())
+	}
+	errc := make(chan error, window)
+	for i := range window {
+		go func(text string) {
+			sm, err := tx.Submit(&ShortMessage{
+				Src:  "root",
+				Dst:  "foobar",
+				Text: pdutext.Raw(text),
+			})
+			if err == nil && sm.RespID() != text {
+				err = fmt.Errorf("unexpected msgid: want %q, have %q", text, sm.RespID())
+			}
+			errc <- err
+		}(fmt.Sprintf("msg%d", i))
+	}
+	for range window {
+		if err := <-errc; err != nil {
+			t.Fatal(err)
+		}
+	}
+	for range 2 {
+		select {
+		case p := <-unmatched:
+			if p.Header().ID != pdu.SubmitSMRespID {
+				t.Fatalf("unexpected unmatched PDU: %s", p.Header().ID)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("unmatched responses not reported")
+		}
+	}
+}
+
+func TestRelativeValidity(t *testing.T) {
+	test := []struct {
+		d    time.Duration
+		want string
+	}{
+		{30 * time.Second, "000000000030000R"},
+		{90 * time.Minute, "000000013000000R"},
+		{48 * time.Hour, "000002000000000R"},
+		{400*24*time.Hour + 1500*time.Millisecond, "010105000001000R"},
+		{45 * time.Second, "000000000045000R"},
+		{2 * time.Hour, "000000020000000R"},
+		{3 * 24 * time.Hour, "000003000000000R"},
+		{400 * 24 * time.Hour, "010105000000000R"},
+		{364 * 24 * time.Hour, "001134000000000R"},
+		{maxRelativeValidity, "991134235959000R"},
+		{200 * 365 * 24 * time.Hour, "991134235959000R"},
+		{-time.Second, "000000000000000R"},
+	}
+	for _, tc := range test {
+		if have := relativeValidity(tc.d); have != tc.want {
+			t.Fatalf("unexpected validity for %s: want %q, have %q", tc.d, tc.want, have)
+		}
+	}
+}
+
+func TestValidityMode(t *testing.T) {
+	test := []struct {
+		mode     ValidityMode
+		validity time.Duration
+		want     string
+	}{
+		{ValidityRelative, 90 * time.Minute, "000000013000000R"},
+		{ValiditySMSCDefault, 90 * time.Minute, ""},
+		{ValidityAbsolute, 0, ""},
+		{ValidityRelative, 0, ""},
+	}
+	for _, tc := range test {
+		sm := &ShortMessage{
+			Src:          "root",
+			Dst:          "foobar",
+			Text:         pdutext.Raw("Lorem ipsum"),
+			Validity:     tc.validity,
+			ValidityMode: tc.mode,
+		}
+		pdus, err := sm.BuildPDUs()
+		if err != nil {
+			t.Fatal(err)
+		}
+		long, err := sm.BuildLongPDUs()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, p := range append(pdus, long...) {
+			if have, _ := p.Fields().String(pdufield.ValidityPeriod); have != tc.want {
+				t.Fatalf("unexpected validity_period for mode %d: want %q, have %q", tc.mode, tc.want, have)
+			}
+		}
+	}
+	sm := &ShortMessage{Text: pdutext.Raw("Lorem ipsum"), Validity: 48 * time.Hour}
+	pdus, err := sm.BuildLongPDUs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, _ := pdus[0].Fields().String(pdufield.ValidityPeriod)
+	if !strings.HasSuffix(v, "000+") || len(v) != 16 {
+		t.Fatalf("unexpected absolute validity_period: %q", v)
+	}
+}
 
+func BenchmarkSubmit(b *testing.B) {
+	s := smpptest.NewUnstartedServer()
+	s.Handler = func(c smpptest.Conn, p pdu.Body) {
+		r := pdu.NewSubmitSMResp()
+		r.Header().Seq = p.Header().Seq
+		_ = r.Fields().Set(pdufield.MessageID, "foobar")
+		_ = c.Write(r)
+	}
+	s.Start()
+	defer s.Close()
+	tx := &Transmitter{
+		Addr:   s.Addr(),
+		User:   smpptest.DefaultUser,
+		Passwd: smpptest.DefaultPasswd,
+	}
+	defer tx.Close()
+	conn := <-tx.Bind()
+	switch conn.Status() {
+	case Connected:
+	default:
+		b.Fatal(conn.Error())
+	}
+	sm := &ShortMessage{
+		Src:  "root",
+		Dst:  "foobar",
+		Text: pdutext.Raw("Lorem ipsum dolor sit amet"),
+	}
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, err := tx.Submit(sm); err != nil {
+			b.Fatal(err)
+		}
+	}
 }
@@ -0,0 +1,218 @@
+// Copyright 2015 go-smpp authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+// Package wappush builds WAP push Service Indication (SI) and Service
+// Loading (SL) messages to be sent as binary short messages.
+//
+// The payload is a WSP push PDU carrying the WBXML encoded document,
+// and is addressed to the WAP push port using the UDH.
+//
+// See WAP-167-ServiceInd and WAP-168-ServiceLoad for details.
+package wappush
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/florentchauveau/go-smpp/smpp"
+	"github.com/florentchauveau/go-smpp/smpp/pdu/pdufield"
+	"github.com/florentchauveau/go-smpp/smpp/pdu/pdutext"
+)
+
+// WDP ports used for WAP push over SMS.
+const (
+	PushPort = 2948 // WAP push connectionless session service.
+	WSPPort  = 9200 // WAP connectionless session service.
+)
+
+// SIAction is the action attribute of a Service Indication. The zero
+// value omits the attribute, which means signal-medium.
+type SIAction uint8
+
+// Supported Service Indication actions.
+const (
+	SignalNone   SIAction = 0x05
+	SignalLow    SIAction = 0x06
+	SignalMedium SIAction = 0x07
+	SignalHigh   SIAction = 0x08
+	Delete       SIAction = 0x09
+)
+
+// SLAction is the action attribute of a Service Loading. The zero
+// value omits the attribute, which means execute-low.
+type SLAction uint8
+
+// Supported Service Loading actions.
+const (
+	ExecuteLow  SLAction = 0x05
+	ExecuteHigh SLAction = 0x06
+	Cache       SLAction = 0x07
+)
+
+// Push is a WAP push message.
+type Push interface {
+	// Bytes returns the WSP push PDU.
+	Bytes() []byte
+}
+
+// SI is a WAP push Service Indication.
+type SI struct {
+	URL    string
+	Text   string
+	Action SIAction
+}
+
+// Bytes implements the Push interface.
+func (si *SI) Bytes() []byte {
+	var b bytes.Buffer
+	b.Write(wspHeader(0xae)) // application/vnd.wap.sic
+	b.Write([]byte{
+		0x02, // WBXML 1.2
+		0x05, // SI 1.0 public identifier
+		0x6a, // UTF-8
+		0x00, // string table length
+		0x45, // <si>
+		0xc6, // <indication>
+	})
+	if si.Action != 0 {
+		b.WriteByte(byte(si.Action))
+	}
+	writeHref(&b, si.URL, siHrefPrefixes)
+	b.WriteByte(end) // end of <indication> attributes
+	if si.Text != "" {
+		writeString(&b, si.Text)
+	}
+	b.WriteByte(end) // </indication>
+	b.WriteByte(end) // </si>
+	return b.Bytes()
+}
+
+// SL is a WAP push Service Loading.
+type SL struct {
+	URL    string
+	Action SLAction
+}
+
+// Bytes implements the Push interface.
+func (sl *SL) Bytes() []byte {
+	var b bytes.Buffer
+	b.Write(wspHeader(0xb0)) // application/vnd.wap.slc
+	b.Write([]byte{
+		0x02, // WBXML 1.2
+		0x06, // SL 1.0 public identifier
+		0x6a, // UTF-8
+		0x00, // string table length
+		0x85, // <sl>
+	})
+	if sl.Action != 0 {
+		b.WriteByte(byte(sl.Action))
+	}
+	writeHref(&b, sl.URL, slHrefPrefixes)
+	b.WriteByte(end) // end of <sl> attributes
+	return b.Bytes()
+}
+
+// UDH returns the IEs addressing a short message to the WAP push port.
+func UDH() []pdufield.UDHIE {
+	return []pdufield.UDHIE{
+		pdufield.NewIEApplicationPort16Bit(PushPort, WSPPort),
+	}
+}
+
+// NewShortMessage creates a ShortMessage carrying the given push, with
+// 8-bit binary data coding and the WAP push port UDH. It must be sent
+// with SubmitLongMsg.
+func NewShortMessage(src, dst string, p Push) *smpp.ShortMessage {
+	return &smpp.ShortMessage{
+		Src:  src,
+		Dst:  dst,
+		Text: pdutext.Binary(p.Bytes()),
+		UDH:  UDH(),
+	}
+}
+
+const (
+	end          = 0x01
+	inlineString = 0x03
+)
+
+// hrefPrefix is an attribute start token for href with a URL prefix.
+type hrefPrefix struct {
+	prefix string
+	token  byte
+}
+
+// Longest prefixes first, the last one matches any URL.
+var siHrefPrefixes = []hrefPrefix{
+	{"https://www.", 0x0f},
+	{"https://", 0x0e},
+	{"http://www.", 0x0d},
+	{"http://", 0x0c},
+	{"", 0x0b},
+}
+
+var slHrefPrefixes = []hrefPrefix{
+	{"https://www.", 0x0c},
+	{"https://", 0x0b},
+	{"http://www.", 0x0a},
+	{"http://", 0x09},
+	{"", 0x08},
+}
+
+// Attribute value tokens, shared by SI and SL.
+var hrefTokens = []struct {
+	s     string
+	token byte
+}{
+	{".com/", 0x85},
+	{".edu/", 0x86},
+	{".net/", 0x87},
+	{".org/", 0x88},
+}
+
+// wspHeader returns the header of a WSP push PDU with the given
+// well-known content type.
+func wspHeader(contentType byte) []byte {
+	return []byte{
+		0x01, // transaction ID
+		0x06, // PDU type: push
+		0x01, // headers length
+		contentType,
+	}
+}
+
+// writeHref writes the href attribute, tokenizing the URL prefix and
+// well-known domain suffixes.
+func writeHref(b *bytes.Buffer, url string, prefixes []hrefPrefix) {
+	for _, p := range prefixes {
+		if strings.HasPrefix(url, p.prefix) {
+			b.WriteByte(p.token)
+			url = url[len(p.prefix):]
+			break
+		}
+	}
+	for url != "" {
+		i, tok := len(url), -1
+		for j, t := range hrefTokens {
+			if n := strings.Index(url, t.s); n >= 0 && n < i {
+				i, tok = n, j
+			}
+		}
+		if i > 0 {
+			writeString(b, url[:i])
+		}
+		if tok < 0 {
+			break
+		}
+		b.WriteByte(hrefTokens[tok].token)
+		url = url[i+len(hrefTokens[tok].s):]
+	}
+}
+
+// writeString writes s as a WBXML inline string.
+func writeString(b *bytes.Buffer, s string) {
+	b.WriteByte(inlineString)
+	b.WriteString(s)
+	b.WriteByte(0x00)
+}
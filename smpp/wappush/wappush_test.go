@@ -0,0 +1,62 @@
+// Copyright 2015 go-smpp authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package wappush
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/florentchauveau/go-smpp/smpp/pdu/pdufield"
+	"github.com/florentchauveau/go-smpp/smpp/pdu/pdutext"
+)
+
+func TestSI(t *testing.T) {
+	si := &SI{
+		URL:    "http://www.xyz.com/ppaid/123/abc.wml",
+		Text:   "You have 4 new emails",
+		Action: SignalHigh,
+	}
+	want := []byte{
+		0x01, 0x06, 0x01, 0xae, // WSP push, application/vnd.wap.sic
+		0x02, 0x05, 0x6a, 0x00, 0x45, 0xc6, 0x08, 0x0d,
+		0x03, 'x', 'y', 'z', 0x00, 0x85,
+		0x03, 'p', 'p', 'a', 'i', 'd', '/', '1', '2', '3', '/', 'a', 'b', 'c', '.', 'w', 'm', 'l', 0x00,
+		0x01,
+		0x03, 'Y', 'o', 'u', ' ', 'h', 'a', 'v', 'e', ' ', '4', ' ', 'n', 'e', 'w', ' ', 'e', 'm', 'a', 'i', 'l', 's', 0x00,
+		0x01, 0x01,
+	}
+	if have := si.Bytes(); !bytes.Equal(want, have) {
+		t.Fatalf("unexpected SI: want % x, have % x", want, have)
+	}
+}
+
+func TestSL(t *testing.T) {
+	sl := &SL{URL: "https://example.org/", Action: ExecuteHigh}
+	want := []byte{
+		0x01, 0x06, 0x01, 0xb0, // WSP push, application/vnd.wap.slc
+		0x02, 0x06, 0x6a, 0x00, 0x85, 0x06, 0x0b,
+		0x03, 'e', 'x', 'a', 'm', 'p', 'l', 'e', 0x00, 0x88,
+		0x01,
+	}
+	if have := sl.Bytes(); !bytes.Equal(want, have) {
+		t.Fatalf("unexpected SL: want % x, have % x", want, have)
+	}
+}
+
+func TestNewShortMessage(t *testing.T) {
+	si := &SI{URL: "http://example.net", Text: "hello"}
+	sm := NewShortMessage("root", "foobar", si)
+	if v := sm.Text.Type(); v != pdutext.Binary2Type {
+		t.Fatalf("unexpected data coding: want %#x, have %#x", pdutext.Binary2Type, v)
+	}
+	if !bytes.Equal(sm.Text.Encode(), si.Bytes()) {
+		t.Fatalf("unexpected payload: want % x, have % x", si.Bytes(), sm.Text.Encode())
+	}
+	udh := pdufield.NewUDH(sm.UDH...)
+	want := []byte{0x05, 0x04, 0x0b, 0x84, 0x23, 0xf0}
+	if v := udh.Bytes(); !bytes.Equal(want, v) {
+		t.Fatalf("unexpected UDH: want % x, have % x", want, v)
+	}
+}